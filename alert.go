@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// alertFlags returns the flags shared by all count commands to configure alert rules.
+// Rates are per interval, so they are used together with countFlags.
+func alertFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "alert",
+			Usage: "alert rule evaluated on every interval, e.g. 'fsync > 1000/s' or 'tcp_connect rate +300%'",
+		},
+		&cli.StringFlag{
+			Name:  "alert-exec",
+			Usage: "shell command to run when an alert fires",
+		},
+		&cli.StringFlag{
+			Name:  "alert-webhook",
+			Usage: "URL to POST a JSON payload to when an alert fires",
+		},
+		&cli.IntFlag{
+			Name:  "alert-exit-code",
+			Usage: "exit with this code when an alert fires (0 keeps running)",
+		},
+	}
+}
+
+type alertKind int
+
+const (
+	alertThreshold alertKind = iota
+	alertRate
+)
+
+// alertRule is a single parsed --alert expression.
+type alertRule struct {
+	expr      string
+	key       string
+	kind      alertKind
+	op        string
+	threshold float64
+	perSecond bool
+	change    float64
+}
+
+var (
+	alertThresholdRe = regexp.MustCompile(`^\s*([\w.:-]+)\s*(>=|<=|==|!=|>|<)\s*([0-9]+(?:\.[0-9]+)?)\s*(/s)?\s*$`)
+	alertRateRe      = regexp.MustCompile(`^\s*([\w.:-]+)\s+rate\s+([+-])([0-9]+(?:\.[0-9]+)?)%\s*$`)
+)
+
+// parseAlertRule parses rules of the form "key op value[/s]" or "key rate +N%".
+func parseAlertRule(expr string) (*alertRule, error) {
+	if m := alertThresholdRe.FindStringSubmatch(expr); m != nil {
+		threshold, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert threshold %q: %w", m[3], err)
+		}
+		return &alertRule{
+			expr:      expr,
+			key:       m[1],
+			kind:      alertThreshold,
+			op:        m[2],
+			threshold: threshold,
+			perSecond: m[4] != "",
+		}, nil
+	}
+	if m := alertRateRe.FindStringSubmatch(expr); m != nil {
+		change, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rate %q: %w", m[3], err)
+		}
+		if m[2] == "-" {
+			change = -change
+		}
+		return &alertRule{
+			expr:   expr,
+			key:    m[1],
+			kind:   alertRate,
+			change: change,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid alert rule: %q", expr)
+}
+
+// check evaluates the rule against the current and previous interval values.
+// It returns the observed value (a rate or a percentage change) and whether the rule fired.
+// Rate rules never fire without a non-zero previous interval to compare against.
+func (r *alertRule) check(cur, prev int64, hasPrev bool, interval time.Duration) (float64, bool) {
+	switch r.kind {
+	case alertRate:
+		if !hasPrev || prev == 0 {
+			return 0, false
+		}
+		pct := float64(cur-prev) / float64(prev) * 100
+		if r.change >= 0 {
+			return pct, pct >= r.change
+		}
+		return pct, pct <= r.change
+	default:
+		value := float64(cur)
+		if r.perSecond && interval > 0 {
+			value = value / interval.Seconds()
+		}
+		switch r.op {
+		case ">":
+			return value, value > r.threshold
+		case ">=":
+			return value, value >= r.threshold
+		case "<":
+			return value, value < r.threshold
+		case "<=":
+			return value, value <= r.threshold
+		case "==":
+			return value, value == r.threshold
+		case "!=":
+			return value, value != r.threshold
+		}
+	}
+	return 0, false
+}
+
+// alertPayload is the JSON body posted to the alert webhook.
+type alertPayload struct {
+	Rule     string  `json:"rule"`
+	Key      string  `json:"key"`
+	Value    float64 `json:"value"`
+	Interval int     `json:"interval"`
+	Time     string  `json:"time"`
+}
+
+// AlertEvaluator checks alert rules against the counts of each interval.
+type AlertEvaluator struct {
+	rules    []*alertRule
+	interval time.Duration
+	hook     string
+	webhook  string
+	exitCode int
+	client   *http.Client
+
+	prev    map[string]int64
+	hasPrev bool
+	// warned holds the rule keys already reported as missing from the counts.
+	warned map[string]bool
+}
+
+// NewAlertEvaluator creates an AlertEvaluator from the flags added by alertFlags.
+// It returns nil when no rules are configured.
+func NewAlertEvaluator(command *cli.Command) (*AlertEvaluator, error) {
+	exprs := command.StringSlice("alert")
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	a := &AlertEvaluator{
+		interval: command.Duration("interval"),
+		hook:     command.String("alert-exec"),
+		webhook:  command.String("alert-webhook"),
+		exitCode: command.Int("alert-exit-code"),
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+	for _, expr := range exprs {
+		rule, err := parseAlertRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		a.rules = append(a.rules, rule)
+	}
+	return a, nil
+}
+
+// Evaluate checks all rules against the counts of one interval.
+// The key "total" refers to the sum of all counts.
+// It returns a cli.ExitCoder when a rule fires and an exit code is configured.
+func (a *AlertEvaluator) Evaluate(ctx context.Context, intervalCount int, values map[string]int64) error {
+	if a == nil {
+		return nil
+	}
+
+	lookup := func(m map[string]int64, key string) int64 {
+		if key == "total" {
+			if _, ok := m[key]; !ok {
+				var sum int64
+				for _, v := range m {
+					sum += v
+				}
+				return sum
+			}
+		}
+		return m[key]
+	}
+
+	var fired *alertRule
+	for _, rule := range a.rules {
+		a.checkKey(rule, values)
+		cur := lookup(values, rule.key)
+		prev := lookup(a.prev, rule.key)
+		value, ok := rule.check(cur, prev, a.hasPrev, a.interval)
+		if !ok {
+			continue
+		}
+		log.Warn().Str("rule", rule.expr).Str("key", rule.key).
+			Float64("value", value).Int("interval", intervalCount).Msg("Alert fired")
+		a.notify(ctx, rule, value, intervalCount)
+		if fired == nil {
+			fired = rule
+		}
+	}
+
+	a.prev = values
+	a.hasPrev = true
+
+	if fired != nil && a.exitCode != 0 {
+		return cli.Exit(fmt.Sprintf("alert fired: %s", fired.expr), a.exitCode)
+	}
+	return nil
+}
+
+// checkKey warns once when a rule refers to a key missing from the counts,
+// which usually is a typo: the rule then sees 0 on every interval.
+func (a *AlertEvaluator) checkKey(rule *alertRule, values map[string]int64) {
+	if rule.key == "total" || a.warned[rule.key] {
+		return
+	}
+	if _, ok := values[rule.key]; ok {
+		return
+	}
+	if a.warned == nil {
+		a.warned = make(map[string]bool)
+	}
+	a.warned[rule.key] = true
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	log.Warn().Str("rule", rule.expr).Str("key", rule.key).Strs("known", keys).
+		Msg("Alert rule key not found in the counts, evaluating it as 0")
+}
+
+func (a *AlertEvaluator) notify(ctx context.Context, rule *alertRule, value float64, intervalCount int) {
+	payload := alertPayload{
+		Rule:     rule.expr,
+		Key:      rule.key,
+		Value:    value,
+		Interval: intervalCount,
+		Time:     time.Now().Format(time.RFC3339),
+	}
+
+	if a.hook != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", a.hook)
+		cmd.Env = append(os.Environ(),
+			"BPFSTREAM_ALERT_RULE="+payload.Rule,
+			"BPFSTREAM_ALERT_KEY="+payload.Key,
+			"BPFSTREAM_ALERT_VALUE="+strconv.FormatFloat(payload.Value, 'f', -1, 64),
+			"BPFSTREAM_ALERT_INTERVAL="+strconv.Itoa(payload.Interval),
+		)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Error().Err(err).Str("hook", a.hook).Msg("Alert hook failed")
+		}
+	}
+
+	if a.webhook != "" {
+		body, _ := json.Marshal(payload)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhook, bytes.NewReader(body))
+		if err != nil {
+			log.Error().Err(err).Str("webhook", a.webhook).Msg("Failed to create alert webhook request")
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := a.client.Do(req)
+		if err != nil {
+			log.Error().Err(err).Str("webhook", a.webhook).Msg("Alert webhook failed")
+			return
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Error().Int("status", resp.StatusCode).Str("webhook", a.webhook).Msg("Alert webhook returned error status")
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// TestParseAlertRule tests parsing of threshold and rate rules
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+		key     string
+		kind    alertKind
+	}{
+		{expr: "fsync > 1000/s", key: "fsync", kind: alertThreshold},
+		{expr: "read>=5", key: "read", kind: alertThreshold},
+		{expr: "tcp_connect rate +300%", key: "tcp_connect", kind: alertRate},
+		{expr: "exec rate -50%", key: "exec", kind: alertRate},
+		{expr: "fsync >", wantErr: true},
+		{expr: "fsync rate 300%", wantErr: true},
+		{expr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule, err := parseAlertRule(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAlertRule(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if rule.key != tt.key || rule.kind != tt.kind {
+				t.Errorf("parseAlertRule(%q) = key %q kind %d, want key %q kind %d",
+					tt.expr, rule.key, rule.kind, tt.key, tt.kind)
+			}
+		})
+	}
+}
+
+// TestAlertRuleCheck tests threshold and rate evaluation
+func TestAlertRuleCheck(t *testing.T) {
+	tests := []struct {
+		expr     string
+		cur      int64
+		prev     int64
+		hasPrev  bool
+		interval time.Duration
+		want     bool
+	}{
+		{expr: "fsync > 1000/s", cur: 3000, interval: 2 * time.Second, want: true},
+		{expr: "fsync > 1000/s", cur: 1500, interval: 2 * time.Second, want: false},
+		{expr: "fsync > 1000", cur: 1500, interval: 2 * time.Second, want: true},
+		{expr: "read < 10", cur: 5, want: true},
+		{expr: "tcp_connect rate +300%", cur: 400, prev: 100, hasPrev: true, want: true},
+		{expr: "tcp_connect rate +300%", cur: 399, prev: 100, hasPrev: true, want: false},
+		{expr: "tcp_connect rate +300%", cur: 400, prev: 0, hasPrev: true, want: false},
+		{expr: "tcp_connect rate +300%", cur: 400, want: false},
+		{expr: "exec rate -50%", cur: 40, prev: 100, hasPrev: true, want: true},
+		{expr: "exec rate -50%", cur: 60, prev: 100, hasPrev: true, want: false},
+	}
+
+	for _, tt := range tests {
+		rule, err := parseAlertRule(tt.expr)
+		if err != nil {
+			t.Fatalf("parseAlertRule(%q) error = %v", tt.expr, err)
+		}
+		_, got := rule.check(tt.cur, tt.prev, tt.hasPrev, tt.interval)
+		if got != tt.want {
+			t.Errorf("%q check(cur=%d, prev=%d) = %v, want %v", tt.expr, tt.cur, tt.prev, got, tt.want)
+		}
+	}
+}
+
+// TestAlertEvaluatorWebhookAndExit tests that a fired rule posts to the webhook and returns an exit error
+func TestAlertEvaluatorWebhookAndExit(t *testing.T) {
+	var payloads []alertPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p alertPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		payloads = append(payloads, p)
+	}))
+	defer srv.Close()
+
+	rule, err := parseAlertRule("total > 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &AlertEvaluator{
+		rules:    []*alertRule{rule},
+		interval: time.Second,
+		webhook:  srv.URL,
+		exitCode: 3,
+		client:   srv.Client(),
+	}
+
+	err = a.Evaluate(context.Background(), 1, map[string]int64{"read": 40, "write": 50})
+	if err != nil {
+		t.Fatalf("Evaluate() below threshold error = %v", err)
+	}
+
+	err = a.Evaluate(context.Background(), 2, map[string]int64{"read": 60, "write": 50})
+	exitErr, ok := err.(cli.ExitCoder)
+	if !ok {
+		t.Fatalf("Evaluate() error = %v, want cli.ExitCoder", err)
+	}
+	if exitErr.ExitCode() != 3 {
+		t.Errorf("exit code = %d, want 3", exitErr.ExitCode())
+	}
+
+	if len(payloads) != 1 {
+		t.Fatalf("webhook received %d payloads, want 1", len(payloads))
+	}
+	if payloads[0].Value != 110 || payloads[0].Interval != 2 {
+		t.Errorf("webhook payload = %+v, want value 110 at interval 2", payloads[0])
+	}
+}
+
+// TestAlertEvaluatorNil tests that a nil evaluator is a no-op
+func TestAlertEvaluatorNil(t *testing.T) {
+	var a *AlertEvaluator
+	if err := a.Evaluate(context.Background(), 1, map[string]int64{"read": 1}); err != nil {
+		t.Errorf("nil Evaluate() error = %v", err)
+	}
+}
+
+// TestAlertEvaluatorUnknownKey tests that a rule key missing from the counts is reported once
+func TestAlertEvaluatorUnknownKey(t *testing.T) {
+	var rules []*alertRule
+	for _, expr := range []string{"fsnyc > 10/s", "fsync > 10/s", "total > 1000"} {
+		rule, err := parseAlertRule(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	a := &AlertEvaluator{rules: rules, interval: time.Second}
+	values := map[string]int64{"fsync": 1, "read": 2}
+	for i := 1; i <= 2; i++ {
+		if err := a.Evaluate(context.Background(), i, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.warned) != 1 || !a.warned["fsnyc"] {
+		t.Errorf("warned = %v, want only fsnyc", a.warned)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/pterm/pterm"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// heatmapAddFn receives one sample: a timestamp in nanoseconds and its value.
+type heatmapAddFn = func(ts, value uint64)
+
+// vfsCallSample returns the value of a paired vfs call for the metric.
+// Latencies need both the entry and the return.
+func vfsCallSample(c *vfsCall, metric string) (uint64, bool) {
+	if metric == "latency" {
+		return uint64(c.Duration()), c.Timed()
+	}
+	switch c.Op {
+	case "vfs_read", "vfs_readv", "vfs_write", "vfs_writev":
+	default:
+		return 0, false
+	}
+	if c.Length != 0 {
+		return c.Length, true
+	}
+	if c.RC > 0 {
+		return uint64(c.RC), true
+	}
+	return 0, false
+}
+
+// syscallSizeCalls are the syscalls whose positive return value is a byte count.
+var syscallSizeCalls = []string{
+	"read", "write", "pread64", "pwrite64", "readv", "writev",
+	"preadv", "pwritev", "preadv2", "pwritev2",
+}
+
+// heatmapFromStream buckets samples from bpftrace NDJSON output. syscalls
+// resolves syscall numbers before calls are paired or filtered by name.
+func heatmapFromStream(r io.Reader, source, metric string, syscalls syscallTable, add heatmapAddFn) error {
+	switch source {
+	case "vfs":
+		return vfsCallsFromStream(r, newInodeResolver(), nil, func(c *vfsCall) error {
+			if v, ok := vfsCallSample(c, metric); ok {
+				add(c.Start, v)
+			}
+			return nil
+		})
+	case "net":
+		return netJSONParseThenAppend(r, func(e *netRawEvent) error {
+			add(e.Timestamp, e.Bytes)
+			return nil
+		})
+	case "syscall":
+		if metric == "latency" {
+			pairer := newSyscallPairer()
+			return syscallJSONParseThenAppend(r, func(e *syscallRawEvent) error {
+				e.resolve(syscalls)
+				if c, _ := pairer.Handle(e); c != nil && c.Timed() {
+					add(c.Start, uint64(c.Duration()))
+				}
+				return nil
+			})
+		}
+		return syscallJSONParseThenAppend(r, func(e *syscallRawEvent) error {
+			e.resolve(syscalls)
+			if e.ReturnValue > 0 && slices.Contains(syscallSizeCalls, e.SyscallName) {
+				add(e.Timestamp, uint64(e.ReturnValue))
+			}
+			return nil
+		})
+	}
+	return fmt.Errorf("invalid source: %s", source)
+}
+
+// heatmapFromDB buckets samples from a table written by one of the raw commands.
+// vfs rows are paired again in timestamp order to recover latencies.
+func heatmapFromDB(ctx context.Context, db *sql.DB, table, source, metric string, add heatmapAddFn) error {
+	switch source {
+	case "vfs":
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(
+			`SELECT Ts, Probe, Tid, RC, Path, Inode, "Offset", Length FROM %s ORDER BY Ts`, table))
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+		pairer := newVfsPairer()
+		for rows.Next() {
+			var e vfsEvent
+			var path sql.NullString
+			err := rows.Scan(&e.Timestamp, &e.Probe, &e.Tid, &e.ReturnValue, &path, &e.Inode, &e.Offset, &e.Length)
+			if err != nil {
+				return err
+			}
+			e.Path = path.String
+			if c := pairer.Handle(&e); c != nil {
+				if v, ok := vfsCallSample(c, metric); ok {
+					add(c.Start, v)
+				}
+			}
+		}
+		return rows.Err()
+
+	case "net", "syscall":
+		query := fmt.Sprintf(`SELECT Ts, Bytes FROM %s ORDER BY Ts`, table)
+		if source == "syscall" && metric == "latency" {
+			query = fmt.Sprintf(`SELECT Ts - LatencyNs, LatencyNs FROM %s WHERE LatencyNs IS NOT NULL ORDER BY 1`, table)
+		} else if source == "syscall" {
+			query = fmt.Sprintf(`SELECT Ts, ReturnValue FROM %s WHERE ReturnValue > 0 AND SyscallName IN ('%s') ORDER BY Ts`,
+				table, strings.Join(syscallSizeCalls, "', '"))
+		}
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var ts, v uint64
+			if err := rows.Scan(&ts, &v); err != nil {
+				return err
+			}
+			add(ts, v)
+		}
+		return rows.Err()
+	}
+	return fmt.Errorf("invalid source: %s", source)
+}
+
+// validateHeatmapMetric checks that the source records the metric.
+func validateHeatmapMetric(source, metric string) error {
+	switch source {
+	case "vfs", "net", "syscall":
+	default:
+		return fmt.Errorf("invalid source: %s (must be vfs, net, or syscall)", source)
+	}
+	switch metric {
+	case "size":
+		return nil
+	case "latency":
+		if source == "net" {
+			return fmt.Errorf("%s events carry no latency, use --metric size", source)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid metric: %s (must be latency or size)", metric)
+	}
+}
+
+var heatmapCmd = &cli.Command{
+	Name:  "heatmap",
+	Usage: "Render a time x latency or time x size heatmap of raw events",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "source",
+			Required: true,
+			Usage:    "event source: vfs, net, syscall",
+		},
+		&cli.StringFlag{
+			Name:  "metric",
+			Value: "latency",
+			Usage: "y axis: latency (vfs, syscall) or size (request bytes for vfs, bytes for net, return value of reads and writes for syscall)",
+		},
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin), ignored when --dsn is set",
+		},
+		&cli.StringFlag{
+			Name:  "dsn",
+			Usage: "read events from a DuckDB table instead of the stream",
+		},
+		&cli.StringFlag{
+			Name:  "table",
+			Usage: "table written by the raw command of the source",
+		},
+		&cli.DurationFlag{
+			Name:  "bin",
+			Value: time.Second,
+			Usage: "width of a time column",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "also write the heatmap to an .svg or .html file",
+		},
+		&cli.IntFlag{
+			Name:  "width",
+			Usage: "terminal width in characters (0 detects it)",
+		},
+		syscallArchFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		source := command.String("source")
+		metric := command.String("metric")
+		if err := validateHeatmapMetric(source, metric); err != nil {
+			return err
+		}
+		bin := command.Duration("bin")
+		if bin <= 0 {
+			return fmt.Errorf("invalid bin width: %s", bin)
+		}
+		output := command.String("output")
+		ext := strings.ToLower(filepath.Ext(output))
+		if output != "" && ext != ".svg" && ext != ".html" && ext != ".htm" {
+			return fmt.Errorf("invalid output file: %s (must end in .svg or .html)", output)
+		}
+
+		unit := "bytes"
+		if metric == "latency" {
+			unit = "ns"
+		}
+		h := newHeatmap(fmt.Sprintf("%s %s", source, metric), unit, bin)
+
+		dsn := command.String("dsn")
+		if dsn != "" {
+			table := command.String("table")
+			if table == "" {
+				return fmt.Errorf("--table is required with --dsn")
+			}
+			connector, err := duckdb.NewConnector(dsn, nil)
+			if err != nil {
+				return err
+			}
+			db := sql.OpenDB(connector)
+			defer func() { _ = db.Close() }()
+			if err := heatmapFromDB(ctx, db, table, source, metric, h.Add); err != nil {
+				return err
+			}
+		} else {
+			var r io.Reader
+			input := command.String("input")
+			if input == "-" {
+				r = os.Stdin
+			} else {
+				f, err := os.Open(input)
+				if err != nil {
+					return fmt.Errorf("open input: %w", err)
+				}
+				defer func() { _ = f.Close() }()
+				r = f
+			}
+			var syscalls syscallTable
+			if source == "syscall" {
+				var err error
+				syscalls, err = syscallTableFromFlags(command)
+				if err != nil {
+					return err
+				}
+			}
+			if err := heatmapFromStream(r, source, metric, syscalls, h.Add); err != nil {
+				return err
+			}
+		}
+		if h.Dropped > 0 {
+			log.Warn().Int64("samples", h.Dropped).Int("columns", heatmapMaxColumns).
+				Msg("Dropped samples beyond the last heatmap column, use a wider --bin")
+		}
+
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("create output: %w", err)
+			}
+			if ext == ".svg" {
+				err = h.WriteSVG(f)
+			} else {
+				err = h.WriteHTML(f)
+			}
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("write heatmap: %w", err)
+			}
+		}
+
+		width := command.Int("width")
+		if width <= 0 {
+			width = pterm.GetTerminalWidth()
+		}
+		h.RenderTerminal(os.Stdout, width)
+		return nil
+	},
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,8 @@ var memCmd = &cli.Command{
 	Commands: []*cli.Command{
 		memCountCmd,
 		memRawCmd,
+		memLeaksCmd,
+		memFaultsCmd,
 	},
 }
 
@@ -35,6 +38,9 @@ type MemCountEvent struct {
 	Munmap    int64 `json:"munmap"`
 	Brk       int64 `json:"brk"`
 	PageFault int64 `json:"page_fault"`
+
+	// Other holds map keys beyond the known operations, e.g. comm,probe,path keys.
+	Other otherCounts `json:"other,omitempty"`
 }
 
 // Add accumulates counts from another MemCountEvent.
@@ -43,13 +49,28 @@ func (e *MemCountEvent) Add(other *MemCountEvent) {
 	e.Munmap += other.Munmap
 	e.Brk += other.Brk
 	e.PageFault += other.PageFault
+	e.Other.Add(other.Other)
 }
 
-// Total returns the sum of all operation counts.
+// Total returns the sum of the known operation counts; Other keys are left out.
 func (e *MemCountEvent) Total() int64 {
 	return e.Mmap + e.Munmap + e.Brk + e.PageFault
 }
 
+// Values returns the counts keyed by operation name.
+func (e *MemCountEvent) Values() map[string]int64 {
+	values := map[string]int64{
+		"mmap":       e.Mmap,
+		"munmap":     e.Munmap,
+		"brk":        e.Brk,
+		"page_fault": e.PageFault,
+	}
+	for k, v := range e.Other {
+		values[k] = v
+	}
+	return values
+}
+
 // Fill populates the event from simdjson data.
 func (e *MemCountEvent) Fill(el *simdjson.Element) error {
 	var err error
@@ -84,7 +105,7 @@ func (e *MemCountEvent) Fill(el *simdjson.Element) error {
 		case "page_fault", "handle_mm_fault":
 			e.PageFault = value
 		default:
-			log.Debug().Str("field", m.Name).Int64("value", value).Msg("Unknown field in map data")
+			e.Other.Set(m.Name, value)
 		}
 	}
 	return nil
@@ -105,6 +126,9 @@ func printMemEvent(e *MemCountEvent, format string, intervalCount int) {
 		data, _ := json.Marshal(output)
 		fmt.Println(string(data))
 
+	case "folded":
+		NewCountOutput().PrintFolded(e.Values())
+
 	case "csv":
 		w := csv.NewWriter(os.Stdout)
 		_ = w.Write([]string{"Operation", "Count"})
@@ -113,6 +137,7 @@ func printMemEvent(e *MemCountEvent, format string, intervalCount int) {
 		_ = w.Write([]string{"brk", fmt.Sprintf("%d", e.Brk)})
 		_ = w.Write([]string{"page_fault", fmt.Sprintf("%d", e.PageFault)})
 		_ = w.Write([]string{"total", fmt.Sprintf("%d", e.Total())})
+		e.Other.writeCSV(w)
 		w.Flush()
 
 	default: // table
@@ -126,6 +151,7 @@ func printMemEvent(e *MemCountEvent, format string, intervalCount int) {
 		_, _ = fmt.Fprintln(tw, "---------\t-----")
 		_, _ = fmt.Fprintf(tw, "Total\t%d\n", e.Total())
 		_, _ = fmt.Fprintf(tw, "Intervals\t%d\n", intervalCount)
+		e.Other.writeTable(tw)
 		_ = tw.Flush()
 	}
 }
@@ -133,7 +159,7 @@ func printMemEvent(e *MemCountEvent, format string, intervalCount int) {
 var memCountCmd = &cli.Command{
 	Name:  "count",
 	Usage: "Aggregate memory operation counts",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -143,13 +169,13 @@ var memCountCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "format",
 			Value: "table",
-			Usage: "output format: table, json, csv",
+			Usage: "output format: table, json, csv, folded, influx",
 		},
 		&cli.BoolFlag{
 			Name:  "live",
 			Usage: "live mode: print each interval as it arrives",
 		},
-	},
+	}, countFlags(), alertFlags(), otlpFlags(), statsdFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		var r io.Reader
 		input := command.String("input")
@@ -167,15 +193,37 @@ var memCountCmd = &cli.Command{
 		format := command.String("format")
 		live := command.Bool("live")
 
-		if err := ValidateFormat(format); err != nil {
+		if err := ValidateCountFormat(format); err != nil {
+			return err
+		}
+
+		alerts, err := NewAlertEvaluator(command)
+		if err != nil {
+			return err
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		influx, err := NewInfluxOutput(command, "mem")
+		if err != nil {
+			return err
+		}
+
+		statsd, err := NewStatsDSink(command)
+		if err != nil {
 			return err
 		}
+		defer statsd.Close()
 
 		var totalEvent MemCountEvent
 		var intervalCount int
 
 		parser := &NDJSONParser{}
-		err := parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
+		err = parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 			switch msgType {
 			case "map":
 				var event MemCountEvent
@@ -185,9 +233,17 @@ var memCountCmd = &cli.Command{
 				intervalCount++
 				totalEvent.Add(&event)
 
-				if live {
+				if influx != nil {
+					influx.Print(parser.StartTime, intervalCount, event.Values())
+				} else if live {
 					printMemEvent(&event, format, intervalCount)
 				}
+
+				otlp.Counters("mem", parser.StartTime, intervalCount, event.Values())
+				statsd.Send("mem", event.Values())
+				if err := alerts.Evaluate(ctx, intervalCount, event.Values()); err != nil {
+					return err
+				}
 			default:
 				log.Warn().Str("type", msgType).Msg("Unknown message type, skipping")
 			}
@@ -197,9 +253,12 @@ var memCountCmd = &cli.Command{
 			return err
 		}
 
+		if influx != nil {
+			return nil
+		}
 		if !live {
 			printMemEvent(&totalEvent, format, intervalCount)
-		} else if intervalCount > 1 {
+		} else if intervalCount > 1 && format != string(FormatFolded) {
 			fmt.Println("\n--- Total ---")
 			printMemEvent(&totalEvent, format, intervalCount)
 		}
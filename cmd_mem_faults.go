@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+)
+
+type memFaultKey struct {
+	pid   uint64
+	start uint64
+}
+
+// memFaultRegion counts faults that hit one mapping.
+type memFaultRegion struct {
+	Pid      uint64  `json:"pid"`
+	Comm     string  `json:"comm"`
+	Start    uint64  `json:"start"`
+	End      uint64  `json:"end"`
+	Class    string  `json:"class"`
+	Path     string  `json:"path,omitempty"`
+	Faults   int64   `json:"faults"`
+	Rate     float64 `json:"rate"` // faults per second since the region was mapped or the capture started
+	mappedTs uint64
+}
+
+// memFaultClass is the number of faults per region class.
+type memFaultClass struct {
+	Class   string  `json:"class"`
+	Faults  int64   `json:"faults"`
+	Percent float64 `json:"percent"`
+}
+
+// memFaultReport attributes page fault addresses to the mappings of their process.
+// Mappings are learned from mmap, munmap and brk events and optional /proc/<pid>/maps snapshots.
+type memFaultReport struct {
+	tracker *memLeakTracker
+	regions map[memFaultKey]*memFaultRegion
+	classes map[string]int64
+	total   int64
+
+	started bool
+	startTs uint64
+	lastTs  uint64
+}
+
+func newMemFaultReport() *memFaultReport {
+	return &memFaultReport{
+		tracker: newMemLeakTracker(0),
+		regions: make(map[memFaultKey]*memFaultRegion),
+		classes: make(map[string]int64),
+	}
+}
+
+// LoadProcMaps adds a /proc/<pid>/maps snapshot of pid.
+func (r *memFaultReport) LoadProcMaps(pid uint64, rd io.Reader) error {
+	m := r.tracker.proc(&memRawEvent{Pid: pid})
+	return m.LoadProcMaps(rd, 0)
+}
+
+// Handle attributes fault events and feeds mapping events to the region maps.
+func (r *memFaultReport) Handle(e *memRawEvent) {
+	if !r.started {
+		r.started, r.startTs = true, e.Timestamp
+	}
+	r.lastTs = max(r.lastTs, e.Timestamp)
+
+	op := classifyMemProbe(e.Probe)
+	if op == memOpOther {
+		op = classifyMemProbe(e.Type)
+	}
+	if op != memOpFault {
+		r.tracker.Handle(e)
+		return
+	}
+
+	m := r.tracker.proc(e)
+	key := memFaultKey{pid: e.Pid}
+	region := &memFaultRegion{Pid: e.Pid, Comm: m.Comm, Class: "unknown"}
+	var brkHeap bool
+	if mapped := m.Lookup(e.Address); mapped != nil {
+		key.start = mapped.Start
+		region.Start, region.End, region.Class, region.Path = mapped.Start, mapped.End, mapped.Class(), mapped.Path
+		region.mappedTs = mapped.MappedTs
+	} else if m.InHeap(e.Address) {
+		key.start = m.HeapStart
+		region.Start, region.End, region.Class = m.HeapStart, m.HeapEnd, "heap"
+		brkHeap = true
+	}
+
+	if existing, ok := r.regions[key]; ok {
+		region = existing
+		if brkHeap {
+			// the heap keeps its start but grows with the break
+			region.End = max(region.End, m.HeapEnd)
+		}
+	} else {
+		r.regions[key] = region
+	}
+	if region.Comm == "" {
+		region.Comm = m.Comm
+	}
+	region.Faults++
+	r.classes[region.Class]++
+	r.total++
+}
+
+// memFaultSortKeys maps --sort values to descending comparisons.
+var memFaultSortKeys = map[string]func(a, b *memFaultRegion) bool{
+	"count": func(a, b *memFaultRegion) bool { return a.Faults > b.Faults },
+	"rate":  func(a, b *memFaultRegion) bool { return a.Rate > b.Rate },
+}
+
+// Regions returns the regions ranked by the sort key, the top n only when n > 0.
+func (r *memFaultReport) Regions(sortBy string, n int) ([]*memFaultRegion, error) {
+	less, ok := memFaultSortKeys[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort column: %s", sortBy)
+	}
+	regions := make([]*memFaultRegion, 0, len(r.regions))
+	for _, region := range r.regions {
+		since := max(region.mappedTs, r.startTs)
+		if r.lastTs > since {
+			region.Rate = float64(region.Faults) / (float64(r.lastTs-since) / 1e9)
+		}
+		regions = append(regions, region)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if less(regions[i], regions[j]) != less(regions[j], regions[i]) {
+			return less(regions[i], regions[j])
+		}
+		if regions[i].Pid != regions[j].Pid {
+			return regions[i].Pid < regions[j].Pid
+		}
+		return regions[i].Start < regions[j].Start
+	})
+	if n > 0 && len(regions) > n {
+		regions = regions[:n]
+	}
+	return regions, nil
+}
+
+// Classes returns fault counts per region class, most faults first.
+func (r *memFaultReport) Classes() []memFaultClass {
+	classes := make([]memFaultClass, 0, len(r.classes))
+	for class, faults := range r.classes {
+		classes = append(classes, memFaultClass{Class: class, Faults: faults,
+			Percent: float64(faults) * 100 / float64(r.total)})
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Faults != classes[j].Faults {
+			return classes[i].Faults > classes[j].Faults
+		}
+		return classes[i].Class < classes[j].Class
+	})
+	return classes
+}
+
+func printMemFaults(w io.Writer, classes []memFaultClass, regions []*memFaultRegion, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(struct {
+			Classes []memFaultClass   `json:"classes"`
+			Regions []*memFaultRegion `json:"regions"`
+		}{classes, regions})
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Pid", "Comm", "Start", "End", "Class", "Path", "Faults", "Rate"})
+		for _, r := range regions {
+			_ = cw.Write([]string{strconv.FormatUint(r.Pid, 10), r.Comm,
+				"0x" + strconv.FormatUint(r.Start, 16), "0x" + strconv.FormatUint(r.End, 16),
+				r.Class, r.Path, strconv.FormatInt(r.Faults, 10), strconv.FormatFloat(r.Rate, 'f', 2, 64)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Class\tFaults\tPercent")
+		_, _ = fmt.Fprintln(tw, "-----\t------\t-------")
+		for _, c := range classes {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%.1f%%\n", c.Class, c.Faults, c.Percent)
+		}
+		_ = tw.Flush()
+
+		_, _ = fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Pid\tComm\tRegion\tSize\tClass\tPath\tFaults\tRate/s")
+		_, _ = fmt.Fprintln(tw, "---\t----\t------\t----\t-----\t----\t------\t------")
+		for _, r := range regions {
+			region := "-"
+			if r.Class != "unknown" {
+				region = fmt.Sprintf("%#x-%#x", r.Start, r.End)
+			}
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%d\t%.2f\n", r.Pid, r.Comm, region,
+				r.End-r.Start, r.Class, r.Path, r.Faults, r.Rate)
+		}
+		_ = tw.Flush()
+	}
+}
+
+// parseMapsFlag splits a --maps value of the form "<pid>:<path>".
+func parseMapsFlag(v string) (uint64, string, error) {
+	pidStr, path, ok := strings.Cut(v, ":")
+	if !ok || path == "" {
+		return 0, "", fmt.Errorf("invalid --maps value %q (want <pid>:<path>)", v)
+	}
+	pid, err := strconv.ParseUint(pidStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid --maps pid %q: %w", pidStr, err)
+	}
+	return pid, path, nil
+}
+
+var memFaultsCmd = &cli.Command{
+	Name:  "faults",
+	Usage: "Attribute page faults to heap, stack, file and anonymous mappings",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv (csv prints the regions)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "maps",
+			Usage: "<pid>:<path> of a /proc/<pid>/maps snapshot taken before the capture (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Value: "count",
+			Usage: "rank regions by: count, rate",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Value: 20,
+			Usage: "number of regions to print (0 prints all)",
+		},
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+		sortBy := command.String("sort")
+		if _, ok := memFaultSortKeys[sortBy]; !ok {
+			return fmt.Errorf("invalid sort column: %s", sortBy)
+		}
+
+		report := newMemFaultReport()
+		for _, v := range command.StringSlice("maps") {
+			pid, path, err := parseMapsFlag(v)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("open maps: %w", err)
+			}
+			err = report.LoadProcMaps(pid, f)
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("load maps %s: %w", path, err)
+			}
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		err := memJSONParseThenAppend(r, func(e *memRawEvent) error {
+			report.Handle(e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		regions, err := report.Regions(sortBy, command.Int("top"))
+		if err != nil {
+			return err
+		}
+		printMemFaults(os.Stdout, report.Classes(), regions, format)
+		return nil
+	},
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testProcMaps = `55d4c2a00000-55d4c2a21000 r--p 00000000 fd:01 1312 /usr/bin/svc
+55d4c3000000-55d4c3100000 rw-p 00000000 00:00 0                          [heap]
+7f1000000000-7f1000100000 rw-p 00000000 00:00 0
+7ffd10000000-7ffd10021000 rw-p 00000000 00:00 0                          [stack]
+7ffd101f0000-7ffd101f2000 r-xp 00000000 00:00 0                          [vdso]
+`
+
+const memFaultsTestData = `{"type": "printf", "data": "ts=1000000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x55d4c3000010 size=0"}
+{"type": "printf", "data": "ts=1500000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x55d4c3000020 size=0"}
+{"type": "printf", "data": "ts=2000000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x7ffd10000100 size=0"}
+{"type": "printf", "data": "ts=2500000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x7f1000000008 size=0"}
+{"type": "printf", "data": "ts=2600000000 fn=tracepoint:syscalls:sys_exit_mmap pid=1 tid=1 comm=svc addr=0x7f2000000000 size=8192 type=file"}
+{"type": "printf", "data": "ts=2700000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x7f2000001000 size=0"}
+{"type": "printf", "data": "ts=3000000000 fn=kprobe:handle_mm_fault pid=1 tid=1 comm=svc addr=0x1234 size=0"}
+{"type": "printf", "data": "ts=3000000000 fn=tracepoint:syscalls:sys_exit_brk pid=2 tid=2 comm=web addr=0x1000000 size=0"}
+{"type": "printf", "data": "ts=3000000000 fn=tracepoint:syscalls:sys_exit_brk pid=2 tid=2 comm=web addr=0x1100000 size=0"}
+{"type": "printf", "data": "ts=3000000000 fn=kprobe:handle_mm_fault pid=2 tid=2 comm=web addr=0x1000100 size=0"}
+`
+
+// TestMemFaultReport tests attributing faults to mappings and classes
+func TestMemFaultReport(t *testing.T) {
+	report := newMemFaultReport()
+	if err := report.LoadProcMaps(1, strings.NewReader(testProcMaps)); err != nil {
+		t.Fatal(err)
+	}
+	err := memJSONParseThenAppend(strings.NewReader(memFaultsTestData), func(e *memRawEvent) error {
+		report.Handle(e)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	classes := map[string]int64{}
+	for _, c := range report.Classes() {
+		classes[c.Class] = c.Faults
+	}
+	want := map[string]int64{"heap": 3, "stack": 1, "file": 1, "anon": 1, "unknown": 1}
+	for class, n := range want {
+		if classes[class] != n {
+			t.Errorf("class %s faults = %d, want %d (got %v)", class, classes[class], n, classes)
+		}
+	}
+
+	regions, err := report.Regions("count", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := regions[0]
+	if top.Class != "heap" || top.Path != "[heap]" || top.Faults != 2 || top.Comm != "svc" {
+		t.Errorf("unexpected top region: %+v", top)
+	}
+	if top.Rate != 1 {
+		t.Errorf("heap fault rate = %f, want 1/s over the 2s capture", top.Rate)
+	}
+
+	regions, _ = report.Regions("rate", 0)
+	if regions[0].Class != "file" || regions[0].Path != "" {
+		t.Errorf("expected the mmapped file region to have the highest rate, got %+v", regions[0])
+	}
+	if _, err := report.Regions("nope", 0); err == nil {
+		t.Error("expected error for invalid sort column")
+	}
+}
+
+// TestParseMapsFlag tests splitting --maps values
+func TestParseMapsFlag(t *testing.T) {
+	pid, path, err := parseMapsFlag("42:/tmp/maps:1")
+	if err != nil || pid != 42 || path != "/tmp/maps:1" {
+		t.Errorf("parseMapsFlag = %d, %q, %v", pid, path, err)
+	}
+	if _, _, err := parseMapsFlag("/proc/42/maps"); err == nil {
+		t.Error("expected error without a pid")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// memSample is the outstanding mapped bytes of all processes at the end of a window.
+type memSample struct {
+	Offset    time.Duration `json:"offset_ns"`
+	LiveBytes uint64        `json:"live_bytes"`
+}
+
+// memLeakTracker replays mmap, munmap and brk events into per-process region maps.
+type memLeakTracker struct {
+	procs  map[uint64]*memRegionMap
+	window uint64
+
+	started bool
+	startTs uint64
+	lastTs  uint64
+	bin     uint64
+	live    uint64
+
+	Timeline []memSample
+	Skipped  int64 // failed or unsupported calls
+}
+
+func newMemLeakTracker(window time.Duration) *memLeakTracker {
+	return &memLeakTracker{procs: make(map[uint64]*memRegionMap), window: uint64(window)}
+}
+
+func (t *memLeakTracker) proc(e *memRawEvent) *memRegionMap {
+	m, ok := t.procs[e.Pid]
+	if !ok {
+		m = newMemRegionMap(e.Pid)
+		t.procs[e.Pid] = m
+	}
+	if e.Comm != "" {
+		m.Comm = e.Comm
+	}
+	return m
+}
+
+// Handle applies an event to its process.
+func (t *memLeakTracker) Handle(e *memRawEvent) {
+	op := classifyMemProbe(e.Probe)
+	if op == memOpOther {
+		op = classifyMemProbe(e.Type)
+	}
+	if op != memOpMmap && op != memOpMunmap && op != memOpBrk {
+		if op == memOpOther {
+			t.Skipped++
+		}
+		return
+	}
+	t.advance(e.Timestamp)
+
+	m := t.proc(e)
+	before := m.LiveBytes()
+	switch op {
+	case memOpMmap:
+		if memMapFailed(e.Address) {
+			t.Skipped++
+			return
+		}
+		typ := e.Type
+		if typ == "" || classifyMemProbe(typ) != memOpOther {
+			typ = "anon"
+		}
+		m.Map(e.Address, e.Size, e.Timestamp, typ)
+	case memOpMunmap:
+		m.Unmap(e.Address, e.Size)
+	case memOpBrk:
+		m.Brk(e.Address)
+	}
+	t.live = t.live - before + m.LiveBytes()
+}
+
+// advance closes the windows that ended before ts, one sample per window.
+// Windows without events carry the live bytes forward.
+func (t *memLeakTracker) advance(ts uint64) {
+	if !t.started {
+		t.started, t.startTs = true, ts
+	}
+	t.lastTs = max(t.lastTs, ts)
+	if t.window == 0 || ts < t.startTs {
+		return
+	}
+	for bin := (ts - t.startTs) / t.window; t.bin < bin; t.bin++ {
+		t.Timeline = append(t.Timeline, memSample{Offset: time.Duration((t.bin + 1) * t.window), LiveBytes: t.live})
+	}
+}
+
+// Finish closes the last window.
+func (t *memLeakTracker) Finish() {
+	if t.started && t.window > 0 {
+		t.Timeline = append(t.Timeline, memSample{Offset: time.Duration((t.bin + 1) * t.window), LiveBytes: t.live})
+	}
+}
+
+// memProcSummary is the mapping balance of one process at the end of the capture.
+type memProcSummary struct {
+	Pid           uint64 `json:"pid"`
+	Comm          string `json:"comm"`
+	Maps          int64  `json:"maps"`
+	Unmaps        int64  `json:"unmaps"`
+	MappedBytes   uint64 `json:"mapped_bytes"`
+	UnmappedBytes uint64 `json:"unmapped_bytes"`
+	LiveRegions   int    `json:"live_regions"`
+	LiveBytes     uint64 `json:"live_bytes"`
+	HeapGrowth    uint64 `json:"heap_growth"`
+}
+
+// memLiveRegion is a region never unmapped by the end of the capture.
+type memLiveRegion struct {
+	*memRegion
+	Size uint64        `json:"size"`
+	Age  time.Duration `json:"age_ns"`
+}
+
+// memLeakReport is the result of a mem leaks run.
+type memLeakReport struct {
+	Processes []memProcSummary `json:"processes"`
+	Timeline  []memSample      `json:"timeline"`
+	Regions   []memLiveRegion  `json:"regions"`
+}
+
+// Report summarises processes and returns the top largest regions older than minAge (0 returns all).
+func (t *memLeakTracker) Report(minAge time.Duration, top int) *memLeakReport {
+	report := &memLeakReport{Timeline: t.Timeline}
+	for _, m := range t.procs {
+		report.Processes = append(report.Processes, memProcSummary{
+			Pid: m.Pid, Comm: m.Comm, Maps: m.Maps, Unmaps: m.Unmaps,
+			MappedBytes: m.MappedBytes, UnmappedBytes: m.UnmappedBytes,
+			LiveRegions: len(m.Regions()), LiveBytes: m.LiveBytes(), HeapGrowth: m.HeapGrowth(),
+		})
+		for _, r := range m.Regions() {
+			age := time.Duration(t.lastTs - r.MappedTs)
+			if age < minAge {
+				continue
+			}
+			report.Regions = append(report.Regions, memLiveRegion{memRegion: r, Size: r.Size(), Age: age})
+		}
+	}
+	sort.Slice(report.Processes, func(i, j int) bool {
+		if report.Processes[i].LiveBytes != report.Processes[j].LiveBytes {
+			return report.Processes[i].LiveBytes > report.Processes[j].LiveBytes
+		}
+		return report.Processes[i].Pid < report.Processes[j].Pid
+	})
+	sort.Slice(report.Regions, func(i, j int) bool {
+		if report.Regions[i].Size != report.Regions[j].Size {
+			return report.Regions[i].Size > report.Regions[j].Size
+		}
+		return report.Regions[i].MappedTs < report.Regions[j].MappedTs
+	})
+	if top > 0 && len(report.Regions) > top {
+		report.Regions = report.Regions[:top]
+	}
+	return report
+}
+
+func printMemLeaks(w io.Writer, report *memLeakReport, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(report)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Pid", "Comm", "Start", "End", "Size", "Type", "MappedTs", "AgeNs"})
+		for _, r := range report.Regions {
+			_ = cw.Write([]string{strconv.FormatUint(r.Pid, 10), r.Comm,
+				"0x" + strconv.FormatUint(r.Start, 16), "0x" + strconv.FormatUint(r.End, 16),
+				strconv.FormatUint(r.Size, 10), r.Type,
+				strconv.FormatUint(r.MappedTs, 10), strconv.FormatInt(int64(r.Age), 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Pid\tComm\tMaps\tUnmaps\tMapped\tUnmapped\tLive regions\tLive bytes\tHeap growth")
+		_, _ = fmt.Fprintln(tw, "---\t----\t----\t------\t------\t--------\t------------\t----------\t-----------")
+		for _, p := range report.Processes {
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", p.Pid, p.Comm, p.Maps, p.Unmaps,
+				p.MappedBytes, p.UnmappedBytes, p.LiveRegions, p.LiveBytes, p.HeapGrowth)
+		}
+		_ = tw.Flush()
+
+		_, _ = fmt.Fprintln(w, "\nOutstanding bytes:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Time\tLive bytes\tDelta")
+		_, _ = fmt.Fprintln(tw, "----\t----------\t-----")
+		var prev uint64
+		for _, s := range report.Timeline {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%+d\n", s.Offset, s.LiveBytes, int64(s.LiveBytes-prev))
+			prev = s.LiveBytes
+		}
+		_ = tw.Flush()
+
+		_, _ = fmt.Fprintln(w, "\nLargest live regions:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Pid\tComm\tStart\tSize\tType\tAge")
+		_, _ = fmt.Fprintln(tw, "---\t----\t-----\t----\t----\t---")
+		for _, r := range report.Regions {
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%#x\t%d\t%s\t%s\n", r.Pid, r.Comm, r.Start, r.Size, r.Type, r.Age)
+		}
+		_ = tw.Flush()
+	}
+}
+
+var memLeaksCmd = &cli.Command{
+	Name:  "leaks",
+	Usage: "Track live mappings from raw mmap, munmap and brk events",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv (csv prints the live regions)",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Value: 10 * time.Second,
+			Usage: "width of a window in the outstanding bytes timeline",
+		},
+		&cli.DurationFlag{
+			Name:  "min-age",
+			Usage: "only report live regions mapped at least this long before the end of the capture",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Value: 20,
+			Usage: "number of live regions to print (0 prints all)",
+		},
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+		interval := command.Duration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("invalid interval: %s", interval)
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		tracker := newMemLeakTracker(interval)
+		err := memJSONParseThenAppend(r, func(e *memRawEvent) error {
+			tracker.Handle(e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		tracker.Finish()
+
+		printMemLeaks(os.Stdout, tracker.Report(command.Duration("min-age"), command.Int("top")), format)
+		return nil
+	},
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +28,8 @@ var netCmd = &cli.Command{
 	Commands: []*cli.Command{
 		netCountCmd,
 		netRawCmd,
+		netConnsCmd,
+		netFlowsCmd,
 	},
 }
 
@@ -38,6 +42,9 @@ type NetCountEvent struct {
 	UDPRecv    int64 `json:"udp_recv"`
 	SockCreate int64 `json:"sock_create"`
 	SockClose  int64 `json:"sock_close"`
+
+	// Other holds map keys beyond the known operations, e.g. per-address counts.
+	Other otherCounts `json:"other,omitempty"`
 }
 
 // Add accumulates counts from another NetCountEvent.
@@ -49,13 +56,45 @@ func (e *NetCountEvent) Add(other *NetCountEvent) {
 	e.UDPRecv += other.UDPRecv
 	e.SockCreate += other.SockCreate
 	e.SockClose += other.SockClose
+	e.Other.Add(other.Other)
+}
+
+// RenameOther rewrites the keys of Other with the enricher, merging keys that
+// end up with the same name.
+func (e *NetCountEvent) RenameOther(names *netEnricher) {
+	if names == nil || len(e.Other) == 0 {
+		return
+	}
+	renamed := make(otherCounts, len(e.Other))
+	for k, v := range e.Other {
+		renamed[names.RewriteKey(k)] += v
+	}
+	e.Other = renamed
 }
 
-// Total returns the sum of all operation counts.
+// Total returns the sum of the known operation counts. Other keys may count the
+// same operations again, e.g. per address, so they are reported separately.
 func (e *NetCountEvent) Total() int64 {
 	return e.TCPConnect + e.TCPAccept + e.TCPClose + e.UDPSend + e.UDPRecv + e.SockCreate + e.SockClose
 }
 
+// Values returns the counts keyed by operation name.
+func (e *NetCountEvent) Values() map[string]int64 {
+	values := map[string]int64{
+		"tcp_connect": e.TCPConnect,
+		"tcp_accept":  e.TCPAccept,
+		"tcp_close":   e.TCPClose,
+		"udp_send":    e.UDPSend,
+		"udp_recv":    e.UDPRecv,
+		"sock_create": e.SockCreate,
+		"sock_close":  e.SockClose,
+	}
+	for k, v := range e.Other {
+		values[k] = v
+	}
+	return values
+}
+
 // Fill populates the event from simdjson data.
 func (e *NetCountEvent) Fill(el *simdjson.Element) error {
 	var err error
@@ -96,7 +135,7 @@ func (e *NetCountEvent) Fill(el *simdjson.Element) error {
 		case "sock_close":
 			e.SockClose = value
 		default:
-			log.Debug().Str("field", m.Name).Int64("value", value).Msg("Unknown field in map data")
+			e.Other.Set(m.Name, value)
 		}
 	}
 	return nil
@@ -117,6 +156,9 @@ func printNetEvent(e *NetCountEvent, format string, intervalCount int) {
 		data, _ := json.Marshal(output)
 		fmt.Println(string(data))
 
+	case "folded":
+		NewCountOutput().PrintFolded(e.Values())
+
 	case "csv":
 		w := csv.NewWriter(os.Stdout)
 		_ = w.Write([]string{"Operation", "Count"})
@@ -128,6 +170,7 @@ func printNetEvent(e *NetCountEvent, format string, intervalCount int) {
 		_ = w.Write([]string{"sock_create", fmt.Sprintf("%d", e.SockCreate)})
 		_ = w.Write([]string{"sock_close", fmt.Sprintf("%d", e.SockClose)})
 		_ = w.Write([]string{"total", fmt.Sprintf("%d", e.Total())})
+		e.Other.writeCSV(w)
 		w.Flush()
 
 	default: // table
@@ -144,6 +187,7 @@ func printNetEvent(e *NetCountEvent, format string, intervalCount int) {
 		_, _ = fmt.Fprintln(tw, "---------\t-----")
 		_, _ = fmt.Fprintf(tw, "Total\t%d\n", e.Total())
 		_, _ = fmt.Fprintf(tw, "Intervals\t%d\n", intervalCount)
+		e.Other.writeTable(tw)
 		_ = tw.Flush()
 	}
 }
@@ -151,7 +195,7 @@ func printNetEvent(e *NetCountEvent, format string, intervalCount int) {
 var netCountCmd = &cli.Command{
 	Name:  "count",
 	Usage: "Aggregate network operation counts",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -161,13 +205,13 @@ var netCountCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "format",
 			Value: "table",
-			Usage: "output format: table, json, csv",
+			Usage: "output format: table, json, csv, folded, influx",
 		},
 		&cli.BoolFlag{
 			Name:  "live",
 			Usage: "live mode: print each interval as it arrives",
 		},
-	},
+	}, countFlags(), alertFlags(), otlpFlags(), statsdFlags(), netEnrichFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		var r io.Reader
 		input := command.String("input")
@@ -185,7 +229,34 @@ var netCountCmd = &cli.Command{
 		format := command.String("format")
 		live := command.Bool("live")
 
-		if err := ValidateFormat(format); err != nil {
+		if err := ValidateCountFormat(format); err != nil {
+			return err
+		}
+
+		alerts, err := NewAlertEvaluator(command)
+		if err != nil {
+			return err
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		influx, err := NewInfluxOutput(command, "net")
+		if err != nil {
+			return err
+		}
+
+		statsd, err := NewStatsDSink(command)
+		if err != nil {
+			return err
+		}
+		defer statsd.Close()
+
+		names, err := newNetEnricherFromFlags(command)
+		if err != nil {
 			return err
 		}
 
@@ -193,19 +264,28 @@ var netCountCmd = &cli.Command{
 		var intervalCount int
 
 		parser := &NDJSONParser{}
-		err := parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
+		err = parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 			switch msgType {
 			case "map":
 				var event NetCountEvent
 				if err := event.Fill(data); err != nil {
 					return fmt.Errorf("failed to fill event from map data: %w", err)
 				}
+				event.RenameOther(names)
 				intervalCount++
 				totalEvent.Add(&event)
 
-				if live {
+				if influx != nil {
+					influx.Print(parser.StartTime, intervalCount, event.Values())
+				} else if live {
 					printNetEvent(&event, format, intervalCount)
 				}
+
+				otlp.Counters("net", parser.StartTime, intervalCount, event.Values())
+				statsd.Send("net", event.Values())
+				if err := alerts.Evaluate(ctx, intervalCount, event.Values()); err != nil {
+					return err
+				}
 			default:
 				log.Warn().Str("type", msgType).Msg("Unknown message type, skipping")
 			}
@@ -215,9 +295,12 @@ var netCountCmd = &cli.Command{
 			return err
 		}
 
+		if influx != nil {
+			return nil
+		}
 		if !live {
 			printNetEvent(&totalEvent, format, intervalCount)
-		} else if intervalCount > 1 {
+		} else if intervalCount > 1 && format != string(FormatFolded) {
 			fmt.Println("\n--- Total ---")
 			printNetEvent(&totalEvent, format, intervalCount)
 		}
@@ -231,13 +314,25 @@ type netRawEvent struct {
 	Timestamp uint64
 	Probe     string
 	Tid       uint64
+	Pid       uint64
 	Comm      string
-	SrcAddr   string
+	SrcAddr   netip.Addr
 	SrcPort   uint16
-	DstAddr   string
+	DstAddr   netip.Addr
 	DstPort   uint16
 	Bytes     uint64
 	Protocol  string
+
+	// Names filled in by a netEnricher
+	SrcHost    string
+	DstHost    string
+	SrcPod     string
+	DstPod     string
+	SrcService string
+	DstService string
+
+	// InvalidAddrs counts saddr/daddr values that failed to parse; they are left unset.
+	InvalidAddrs int
 }
 
 var netRawEventPool = sync.Pool{
@@ -258,16 +353,24 @@ func (e *netRawEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 		e.Probe = v
 	case "tid":
 		e.Tid, err = strconv.ParseUint(v, 10, 64)
+	case "pid":
+		e.Pid, err = strconv.ParseUint(v, 10, 64)
 	case "comm":
 		e.Comm = strings.Trim(v, "'\"")
 	case "saddr":
-		e.SrcAddr = strings.Trim(v, "'\"")
+		if e.SrcAddr, err = parseNetAddr(v); err != nil {
+			e.InvalidAddrs++
+			err = nil
+		}
 	case "sport":
 		var port uint64
 		port, err = strconv.ParseUint(v, 10, 16)
 		e.SrcPort = uint16(port)
 	case "daddr":
-		e.DstAddr = strings.Trim(v, "'\"")
+		if e.DstAddr, err = parseNetAddr(v); err != nil {
+			e.InvalidAddrs++
+			err = nil
+		}
 	case "dport":
 		var port uint64
 		port, err = strconv.ParseUint(v, 10, 16)
@@ -286,19 +389,71 @@ const createNetTableSQL = `CREATE TABLE IF NOT EXISTS %s (
 	Ts UBIGINT,
 	Probe STRING,
 	Tid UBIGINT,
+	Pid UBIGINT,
 	Comm STRING,
+	Family UTINYINT,
 	SrcAddr STRING,
+	SrcAddrHi UBIGINT,
+	SrcAddrLo UBIGINT,
 	SrcPort USMALLINT,
 	DstAddr STRING,
+	DstAddrHi UBIGINT,
+	DstAddrLo UBIGINT,
 	DstPort USMALLINT,
 	Bytes UBIGINT,
-	Protocol STRING)`
+	Protocol STRING,
+	SrcHost STRING,
+	DstHost STRING,
+	SrcPod STRING,
+	DstPod STRING,
+	SrcService STRING,
+	DstService STRING)`
 
 const dropNetTableSQL = `DROP TABLE IF EXISTS `
 
 type netAppendRowFn = func(e *netRawEvent) error
 
-func netJSONParseThenAppend(r io.Reader, appendRow netAppendRowFn) error {
+// family returns the address family of the event, preferring the source address.
+func (e *netRawEvent) family() any {
+	if f := netAddrFamily(e.SrcAddr); f != nil {
+		return f
+	}
+	return netAddrFamily(e.DstAddr)
+}
+
+// appendNetRow appends an event in the column order of createNetTableSQL.
+func appendNetRow(appender *duckdb.Appender, e *netRawEvent) error {
+	srcText, srcHi, srcLo := netAddrColumns(e.SrcAddr)
+	dstText, dstHi, dstLo := netAddrColumns(e.DstAddr)
+	return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.Pid, e.Comm, e.family(),
+		srcText, srcHi, srcLo, e.SrcPort, dstText, dstHi, dstLo, e.DstPort, e.Bytes, e.Protocol,
+		nullString(e.SrcHost), nullString(e.DstHost), nullString(e.SrcPod), nullString(e.DstPod),
+		nullString(e.SrcService), nullString(e.DstService))
+}
+
+// nullString returns nil for an empty string so that it is stored as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// enrich fills in the pid and comm of the event's thread from the task table.
+func (e *netRawEvent) enrich(tasks *taskTable) {
+	info, ok := tasks.LookupAt(e.Tid, e.Timestamp)
+	if !ok {
+		return
+	}
+	if e.Pid == 0 {
+		e.Pid = info.Pid
+	}
+	if e.Comm == "" {
+		e.Comm = info.Comm
+	}
+}
+
+func netJSONParseThenAppend(r io.Reader, appendRow netAppendRowFn, hooks ...printfHook) error {
 	parser := &NDJSONParser{}
 	return parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 		switch msgType {
@@ -307,6 +462,10 @@ func netJSONParseThenAppend(r io.Reader, appendRow netAppendRowFn) error {
 			if err != nil {
 				return fmt.Errorf("failed to get 'printf' data as string: %w", err)
 			}
+			handled, err := runPrintfHooks(hooks, buf)
+			if err != nil || handled {
+				return err
+			}
 			e := netRawEventPool.Get().(*netRawEvent)
 			*e = netRawEvent{}
 			err = logfmt.Unmarshal(buf, e)
@@ -327,7 +486,7 @@ func netJSONParseThenAppend(r io.Reader, appendRow netAppendRowFn) error {
 var netRawCmd = &cli.Command{
 	Name:  "raw",
 	Usage: "Write raw network events to DuckDB",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -344,11 +503,27 @@ var netRawCmd = &cli.Command{
 			Required: true,
 			Usage:    "target table name",
 		},
-	},
+		netWhereFlag(),
+	}, taskFlags(), netEnrichFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		dsn := command.String("dsn")
 		tableName := command.String("table")
 
+		filter, err := parseNetFilter(command.String("where"))
+		if err != nil {
+			return err
+		}
+
+		names, err := newNetEnricherFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := newTaskTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
 		connector, err := duckdb.NewConnector(dsn, nil)
 		if err != nil {
 			return err
@@ -391,9 +566,25 @@ var netRawCmd = &cli.Command{
 			r = f
 		}
 
-		return netJSONParseThenAppend(r, func(e *netRawEvent) error {
-			return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.Comm,
-				e.SrcAddr, e.SrcPort, e.DstAddr, e.DstPort, e.Bytes, e.Protocol)
-		})
+		var invalid, skipped int64
+		err = netJSONParseThenAppend(r, func(e *netRawEvent) error {
+			if e.InvalidAddrs > 0 {
+				invalid++
+			}
+			e.enrich(tasks)
+			if !filter.Match(e) {
+				skipped++
+				return nil
+			}
+			names.Apply(e)
+			return appendNetRow(appender, e)
+		}, tasks.Intercept)
+		if invalid > 0 {
+			log.Warn().Int64("events", invalid).Msg("Events with invalid addresses, stored as NULL")
+		}
+		if skipped > 0 {
+			log.Info().Int64("events", skipped).Msg("Events skipped by --where")
+		}
+		return err
 	},
 }
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// netEventKind classifies a network probe for connection tracking.
+type netEventKind int
+
+const (
+	netEventOther netEventKind = iota
+	netEventConnect
+	netEventAccept
+	netEventClose
+	netEventSend
+	netEventRecv
+)
+
+// classifyNetProbe maps probe names such as "kprobe:tcp_connect" or
+// "kretprobe:inet_csk_accept" to the lifecycle step they represent.
+func classifyNetProbe(probe string) netEventKind {
+	name := probe
+	if i := strings.LastIndexByte(probe, ':'); i >= 0 {
+		name = probe[i+1:]
+	}
+	switch {
+	case strings.Contains(name, "disconnect"):
+		return netEventClose
+	case strings.Contains(name, "connect"):
+		return netEventConnect
+	case strings.Contains(name, "accept"):
+		return netEventAccept
+	case strings.Contains(name, "close"):
+		return netEventClose
+	case strings.Contains(name, "send"), strings.Contains(name, "xmit"):
+		return netEventSend
+	case strings.Contains(name, "recv"), strings.Contains(name, "rbuf"):
+		return netEventRecv
+	default:
+		return netEventOther
+	}
+}
+
+type netTuple struct {
+	proto   string
+	srcAddr netip.Addr
+	srcPort uint16
+	dstAddr netip.Addr
+	dstPort uint16
+}
+
+func (t netTuple) reverse() netTuple {
+	return netTuple{proto: t.proto, srcAddr: t.dstAddr, srcPort: t.dstPort, dstAddr: t.srcAddr, dstPort: t.srcPort}
+}
+
+// netConn is a connection stitched together from the events sharing its 4-tuple.
+// Src is the local side as seen by the first event of the connection.
+type netConn struct {
+	Protocol  string     `json:"protocol"`
+	SrcAddr   netip.Addr `json:"saddr"`
+	SrcPort   uint16     `json:"sport"`
+	DstAddr   netip.Addr `json:"daddr"`
+	DstPort   uint16     `json:"dport"`
+	Direction string     `json:"direction"` // connect, accept or empty when the open was not captured
+	Pid       uint64     `json:"pid"`
+	Comm      string     `json:"comm"`
+	OpenTs    uint64     `json:"open_ts"`
+	CloseTs   uint64     `json:"close_ts,omitempty"`
+	BytesOut  uint64     `json:"bytes_out"`
+	BytesIn   uint64     `json:"bytes_in"`
+	Events    int64      `json:"events"`
+	Closed    bool       `json:"closed"`
+	LastTs    uint64     `json:"last_ts"`
+}
+
+// DurationNs returns the time from open to close, or to the last event of an unclosed connection.
+func (c *netConn) DurationNs() uint64 {
+	end := c.LastTs
+	if c.Closed {
+		end = c.CloseTs
+	}
+	if end < c.OpenTs {
+		return 0
+	}
+	return end - c.OpenTs
+}
+
+// netConnTracker stitches raw network events into connections.
+type netConnTracker struct {
+	live   map[netTuple]*netConn
+	closed []*netConn
+}
+
+func newNetConnTracker() *netConnTracker {
+	return &netConnTracker{live: make(map[netTuple]*netConn)}
+}
+
+// lookup finds a live connection by tuple, trying the reversed tuple for events
+// reported from the peer's point of view. On loopback both ends are tracked, and
+// the exact tuple wins.
+func (t *netConnTracker) lookup(tuple netTuple) *netConn {
+	if c, ok := t.live[tuple]; ok {
+		return c
+	}
+	return t.live[tuple.reverse()]
+}
+
+// Handle consumes an event and returns the connection it belongs to, or nil for
+// events without a 4-tuple or unrelated probes.
+func (t *netConnTracker) Handle(e *netRawEvent) *netConn {
+	kind := classifyNetProbe(e.Probe)
+	if kind == netEventOther || (e.SrcPort == 0 && e.DstPort == 0) {
+		return nil
+	}
+	tuple := netTuple{proto: e.Protocol, srcAddr: e.SrcAddr, srcPort: e.SrcPort, dstAddr: e.DstAddr, dstPort: e.DstPort}
+	var c *netConn
+	if kind == netEventConnect || kind == netEventAccept {
+		if c = t.live[tuple]; c != nil {
+			// the tuple was reused before we saw it close
+			t.finish(c, c.LastTs, false)
+		}
+		c = &netConn{Direction: "connect", OpenTs: e.Timestamp}
+		if kind == netEventAccept {
+			c.Direction = "accept"
+		}
+		c.setTuple(tuple)
+		t.live[tuple] = c
+	} else if c = t.lookup(tuple); c == nil {
+		if kind == netEventClose {
+			return nil
+		}
+		c = &netConn{OpenTs: e.Timestamp}
+		c.setTuple(tuple)
+		t.live[tuple] = c
+	}
+
+	if c.Comm == "" || kind == netEventConnect || kind == netEventAccept {
+		c.Pid, c.Comm = e.Pid, e.Comm
+	}
+	c.Events++
+	c.LastTs = max(c.LastTs, e.Timestamp)
+	switch kind {
+	case netEventSend:
+		c.BytesOut += e.Bytes
+	case netEventRecv:
+		c.BytesIn += e.Bytes
+	}
+	if kind == netEventClose {
+		t.finish(c, e.Timestamp, true)
+	}
+	return c
+}
+
+func (c *netConn) setTuple(t netTuple) {
+	c.Protocol, c.SrcAddr, c.SrcPort, c.DstAddr, c.DstPort = t.proto, t.srcAddr, t.srcPort, t.dstAddr, t.dstPort
+}
+
+func (t *netConnTracker) finish(c *netConn, ts uint64, closed bool) {
+	delete(t.live, netTuple{proto: c.Protocol, srcAddr: c.SrcAddr, srcPort: c.SrcPort, dstAddr: c.DstAddr, dstPort: c.DstPort})
+	c.Closed = closed
+	if closed {
+		c.CloseTs = ts
+	}
+	t.closed = append(t.closed, c)
+}
+
+// Conns returns all connections ordered by open time. Connections still live
+// at the end of the capture are reported with Closed unset.
+func (t *netConnTracker) Conns() []*netConn {
+	conns := make([]*netConn, 0, len(t.closed)+len(t.live))
+	conns = append(conns, t.closed...)
+	for _, c := range t.live {
+		conns = append(conns, c)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].OpenTs != conns[j].OpenTs {
+			return conns[i].OpenTs < conns[j].OpenTs
+		}
+		return conns[i].SrcPort < conns[j].SrcPort
+	})
+	return conns
+}
+
+func printNetConns(w io.Writer, conns []*netConn, format string) {
+	switch format {
+	case "json":
+		type jsonConn struct {
+			*netConn
+			DurationNs uint64 `json:"duration_ns"`
+		}
+		out := make([]jsonConn, 0, len(conns))
+		for _, c := range conns {
+			out = append(out, jsonConn{netConn: c, DurationNs: c.DurationNs()})
+		}
+		data, _ := json.Marshal(out)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Protocol", "SrcAddr", "SrcPort", "DstAddr", "DstPort", "Direction", "Pid", "Comm",
+			"OpenTs", "CloseTs", "DurationNs", "BytesOut", "BytesIn", "Events", "Closed"})
+		for _, c := range conns {
+			_ = cw.Write([]string{c.Protocol, netAddrString(c.SrcAddr), strconv.FormatUint(uint64(c.SrcPort), 10),
+				netAddrString(c.DstAddr), strconv.FormatUint(uint64(c.DstPort), 10), c.Direction,
+				strconv.FormatUint(c.Pid, 10), c.Comm,
+				strconv.FormatUint(c.OpenTs, 10), strconv.FormatUint(c.CloseTs, 10),
+				strconv.FormatUint(c.DurationNs(), 10),
+				strconv.FormatUint(c.BytesOut, 10), strconv.FormatUint(c.BytesIn, 10),
+				strconv.FormatInt(c.Events, 10), strconv.FormatBool(c.Closed)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Proto\tLocal\tRemote\tDir\tComm\tDuration\tOut\tIn\tState")
+		_, _ = fmt.Fprintln(tw, "-----\t-----\t------\t---\t----\t--------\t---\t--\t-----")
+		var unclosed int
+		for _, c := range conns {
+			state := "closed"
+			if !c.Closed {
+				state = "UNCLOSED"
+				unclosed++
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", c.Protocol,
+				netEndpoint(c.SrcAddr, c.SrcPort), netEndpoint(c.DstAddr, c.DstPort),
+				c.Direction, c.Comm, time.Duration(c.DurationNs()), c.BytesOut, c.BytesIn, state)
+		}
+		_, _ = fmt.Fprintln(tw, "-----\t-----\t------\t---\t----\t--------\t---\t--\t-----")
+		_, _ = fmt.Fprintf(tw, "Total\t%d\t\t\t\t\t\t\t%d unclosed\n", len(conns), unclosed)
+		_ = tw.Flush()
+	}
+}
+
+const createConnectionsTableSQL = `CREATE TABLE IF NOT EXISTS %s (
+	Protocol STRING,
+	Family UTINYINT,
+	SrcAddr STRING,
+	SrcAddrHi UBIGINT,
+	SrcAddrLo UBIGINT,
+	SrcPort USMALLINT,
+	DstAddr STRING,
+	DstAddrHi UBIGINT,
+	DstAddrLo UBIGINT,
+	DstPort USMALLINT,
+	Direction STRING,
+	Pid UBIGINT,
+	Comm STRING,
+	OpenTs UBIGINT,
+	CloseTs UBIGINT,
+	DurationNs UBIGINT,
+	BytesOut UBIGINT,
+	BytesIn UBIGINT,
+	Events UBIGINT,
+	Closed BOOLEAN)`
+
+func writeConnectionsTable(ctx context.Context, dsn, tableName string, conns []*netConn) error {
+	connector, err := duckdb.NewConnector(dsn, nil)
+	if err != nil {
+		return err
+	}
+
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(dropNetTableSQL + tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(createConnectionsTableSQL, tableName))
+	if err != nil {
+		return err
+	}
+
+	appender, err := duckdb.NewAppenderFromConn(conn, "", tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range conns {
+		var closeTs any
+		if c.Closed {
+			closeTs = c.CloseTs
+		}
+		family := netAddrFamily(c.SrcAddr)
+		if family == nil {
+			family = netAddrFamily(c.DstAddr)
+		}
+		srcText, srcHi, srcLo := netAddrColumns(c.SrcAddr)
+		dstText, dstHi, dstLo := netAddrColumns(c.DstAddr)
+		err = appender.AppendRow(c.Protocol, family, srcText, srcHi, srcLo, c.SrcPort,
+			dstText, dstHi, dstLo, c.DstPort, c.Direction,
+			c.Pid, c.Comm, c.OpenTs, closeTs, c.DurationNs(), c.BytesOut, c.BytesIn, uint64(c.Events), c.Closed)
+		if err != nil {
+			_ = appender.Close()
+			return err
+		}
+	}
+	return appender.Close()
+}
+
+var netConnsCmd = &cli.Command{
+	Name:  "conns",
+	Usage: "Stitch raw network events into connections",
+	Flags: slices.Concat([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.StringFlag{
+			Name:  "dsn",
+			Usage: "DuckDB connection string, write the connections table instead of printing",
+		},
+		&cli.StringFlag{
+			Name:  "table",
+			Value: "connections",
+			Usage: "target table name",
+		},
+		netWhereFlag(),
+	}, taskFlags()),
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+
+		filter, err := parseNetFilter(command.String("where"))
+		if err != nil {
+			return err
+		}
+
+		tasks, err := newTaskTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		tracker := newNetConnTracker()
+		err = netJSONParseThenAppend(r, func(e *netRawEvent) error {
+			e.enrich(tasks)
+			if filter.Match(e) {
+				tracker.Handle(e)
+			}
+			return nil
+		}, tasks.Intercept)
+		if err != nil {
+			return err
+		}
+
+		conns := tracker.Conns()
+		var unclosed int
+		for _, c := range conns {
+			if !c.Closed {
+				unclosed++
+			}
+		}
+		if unclosed > 0 {
+			log.Warn().Int("connections", unclosed).Msg("Connections never closed by the end of the capture")
+		}
+
+		if dsn := command.String("dsn"); dsn != "" {
+			return writeConnectionsTable(ctx, dsn, command.String("table"), conns)
+		}
+		printNetConns(os.Stdout, conns, format)
+		return nil
+	},
+}
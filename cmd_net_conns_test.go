@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const netConnsTestData = `{"type": "attached_probes", "data": {"probes": 4}}
+{"type": "printf", "data": "ts=100 fn=kprobe:tcp_connect tid=10 pid=10 comm=curl saddr=10.0.0.1 sport=40000 daddr=10.0.0.2 dport=443 bytes=0 proto=tcp"}
+{"type": "printf", "data": "ts=200 fn=kprobe:tcp_sendmsg tid=10 pid=10 comm=curl saddr=10.0.0.1 sport=40000 daddr=10.0.0.2 dport=443 bytes=100 proto=tcp"}
+{"type": "printf", "data": "ts=300 fn=kprobe:tcp_recvmsg tid=10 pid=10 comm=curl saddr=10.0.0.2 sport=443 daddr=10.0.0.1 dport=40000 bytes=2000 proto=tcp"}
+{"type": "printf", "data": "ts=400 fn=kprobe:tcp_close tid=10 pid=10 comm=curl saddr=10.0.0.1 sport=40000 daddr=10.0.0.2 dport=443 bytes=0 proto=tcp"}
+{"type": "printf", "data": "ts=500 fn=kretprobe:inet_csk_accept tid=20 pid=20 comm=nginx saddr=10.0.0.1 sport=80 daddr=10.0.0.3 dport=5000 bytes=0 proto=tcp"}
+{"type": "printf", "data": "ts=600 fn=kprobe:tcp_recvmsg tid=21 pid=20 comm=nginx saddr=10.0.0.1 sport=80 daddr=10.0.0.3 dport=5000 bytes=50 proto=tcp"}
+{"type": "printf", "data": "ts=700 fn=kprobe:tcp_close tid=30 pid=30 comm=x saddr=1.1.1.1 sport=1 daddr=2.2.2.2 dport=2 bytes=0 proto=tcp"}
+`
+
+// TestClassifyNetProbe tests mapping probe names to connection lifecycle steps
+func TestClassifyNetProbe(t *testing.T) {
+	tests := map[string]netEventKind{
+		"kprobe:tcp_v4_connect":     netEventConnect,
+		"kretprobe:inet_csk_accept": netEventAccept,
+		"kprobe:tcp_disconnect":     netEventClose,
+		"tcp_close":                 netEventClose,
+		"kprobe:udp_sendmsg":        netEventSend,
+		"kprobe:tcp_cleanup_rbuf":   netEventRecv,
+		"kprobe:tcp_set_state":      netEventOther,
+	}
+	for probe, want := range tests {
+		if got := classifyNetProbe(probe); got != want {
+			t.Errorf("classifyNetProbe(%q) = %d, want %d", probe, got, want)
+		}
+	}
+}
+
+// TestNetConnTracker tests stitching events into connections
+func TestNetConnTracker(t *testing.T) {
+	tracker := newNetConnTracker()
+	err := netJSONParseThenAppend(strings.NewReader(netConnsTestData), func(e *netRawEvent) error {
+		tracker.Handle(e)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := tracker.Conns()
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+
+	c := conns[0]
+	if c.Direction != "connect" || c.Comm != "curl" || !c.Closed {
+		t.Errorf("unexpected client connection: %+v", c)
+	}
+	if c.BytesOut != 100 || c.BytesIn != 2000 {
+		t.Errorf("client bytes out/in = %d/%d, want 100/2000", c.BytesOut, c.BytesIn)
+	}
+	if c.DurationNs() != 300 || c.Events != 4 {
+		t.Errorf("client duration = %d events = %d, want 300 and 4", c.DurationNs(), c.Events)
+	}
+
+	s := conns[1]
+	if s.Direction != "accept" || s.Comm != "nginx" || s.Closed {
+		t.Errorf("unexpected server connection: %+v", s)
+	}
+	if s.BytesIn != 50 || s.DurationNs() != 100 {
+		t.Errorf("server bytes in = %d duration = %d, want 50 and 100", s.BytesIn, s.DurationNs())
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/urfave/cli/v3"
+)
+
+type netFlowKey struct {
+	proto   string
+	srcAddr netip.Addr
+	dstAddr netip.Addr
+	dstPort uint16
+	comm    string
+}
+
+// netFlow is the traffic of one flow key within one window, or across the whole
+// capture for top talkers.
+type netFlow struct {
+	WindowStart uint64     `json:"window_start"`
+	Protocol    string     `json:"protocol"`
+	SrcAddr     netip.Addr `json:"saddr"`
+	DstAddr     netip.Addr `json:"daddr"`
+	DstPort     uint16     `json:"dport"`
+	Comm        string     `json:"comm"`
+	Bytes       uint64     `json:"bytes"`
+	Events      int64      `json:"events"`
+	FirstTs     uint64     `json:"first_ts"`
+	LastTs      uint64     `json:"last_ts"`
+}
+
+func (f *netFlow) add(e *netRawEvent) {
+	if f.Events == 0 || e.Timestamp < f.FirstTs {
+		f.FirstTs = e.Timestamp
+	}
+	f.LastTs = max(f.LastTs, e.Timestamp)
+	f.Bytes += e.Bytes
+	f.Events++
+}
+
+// netFlowAggregator sums event bytes per flow key over fixed windows aligned to
+// multiples of the window length. A window is emitted once an event of a later
+// window arrives; late events are folded into the current window.
+type netFlowAggregator struct {
+	window  uint64
+	start   uint64
+	started bool
+	flows   map[netFlowKey]*netFlow
+	totals  map[netFlowKey]*netFlow
+	emit    func(f *netFlow) error
+}
+
+func newNetFlowAggregator(window time.Duration, emit func(f *netFlow) error) *netFlowAggregator {
+	return &netFlowAggregator{
+		window: uint64(window),
+		flows:  make(map[netFlowKey]*netFlow),
+		totals: make(map[netFlowKey]*netFlow),
+		emit:   emit,
+	}
+}
+
+func newNetFlow(key netFlowKey, windowStart uint64) *netFlow {
+	return &netFlow{WindowStart: windowStart, Protocol: key.proto, SrcAddr: key.srcAddr,
+		DstAddr: key.dstAddr, DstPort: key.dstPort, Comm: key.comm}
+}
+
+// Handle accounts an event to its flow, emitting the previous window when the event starts a new one.
+func (a *netFlowAggregator) Handle(e *netRawEvent) error {
+	windowStart := e.Timestamp - e.Timestamp%a.window
+	if !a.started {
+		a.start, a.started = windowStart, true
+	} else if windowStart > a.start {
+		if err := a.Flush(); err != nil {
+			return err
+		}
+		a.start = windowStart
+	}
+
+	key := netFlowKey{proto: e.Protocol, srcAddr: e.SrcAddr, dstAddr: e.DstAddr, dstPort: e.DstPort, comm: e.Comm}
+	f, ok := a.flows[key]
+	if !ok {
+		f = newNetFlow(key, a.start)
+		a.flows[key] = f
+	}
+	f.add(e)
+
+	total, ok := a.totals[key]
+	if !ok {
+		total = newNetFlow(key, 0)
+		a.totals[key] = total
+	}
+	total.add(e)
+	return nil
+}
+
+// Flush emits the flows of the current window, largest first.
+func (a *netFlowAggregator) Flush() error {
+	flows := sortedFlows(a.flows)
+	clear(a.flows)
+	if a.emit == nil {
+		return nil
+	}
+	for _, f := range flows {
+		if err := a.emit(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TopTalkers returns the n flow keys with the most bytes over the whole capture (0 returns all).
+func (a *netFlowAggregator) TopTalkers(n int) []*netFlow {
+	flows := sortedFlows(a.totals)
+	if n > 0 && len(flows) > n {
+		flows = flows[:n]
+	}
+	return flows
+}
+
+func sortedFlows(m map[netFlowKey]*netFlow) []*netFlow {
+	flows := make([]*netFlow, 0, len(m))
+	for _, f := range m {
+		flows = append(flows, f)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].Bytes != flows[j].Bytes {
+			return flows[i].Bytes > flows[j].Bytes
+		}
+		if flows[i].Events != flows[j].Events {
+			return flows[i].Events > flows[j].Events
+		}
+		return flows[i].FirstTs < flows[j].FirstTs
+	})
+	return flows
+}
+
+func printNetFlows(w io.Writer, flows []*netFlow, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(flows)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Protocol", "SrcAddr", "DstAddr", "DstPort", "Comm", "Bytes", "Events", "FirstTs", "LastTs"})
+		for _, f := range flows {
+			_ = cw.Write([]string{f.Protocol, netAddrString(f.SrcAddr), netAddrString(f.DstAddr), strconv.FormatUint(uint64(f.DstPort), 10),
+				f.Comm, strconv.FormatUint(f.Bytes, 10), strconv.FormatInt(f.Events, 10),
+				strconv.FormatUint(f.FirstTs, 10), strconv.FormatUint(f.LastTs, 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Proto\tSource\tDestination\tComm\tBytes\tEvents")
+		_, _ = fmt.Fprintln(tw, "-----\t------\t-----------\t----\t-----\t------")
+		var bytes uint64
+		var events int64
+		for _, f := range flows {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\n", f.Protocol, netAddrString(f.SrcAddr),
+				netEndpoint(f.DstAddr, f.DstPort), f.Comm, f.Bytes, f.Events)
+			bytes += f.Bytes
+			events += f.Events
+		}
+		_, _ = fmt.Fprintln(tw, "-----\t------\t-----------\t----\t-----\t------")
+		_, _ = fmt.Fprintf(tw, "Total\t\t\t\t%d\t%d\n", bytes, events)
+		_ = tw.Flush()
+	}
+}
+
+const createFlowsTableSQL = `CREATE TABLE IF NOT EXISTS %s (
+	WindowStart UBIGINT,
+	WindowNs UBIGINT,
+	Protocol STRING,
+	Family UTINYINT,
+	SrcAddr STRING,
+	SrcAddrHi UBIGINT,
+	SrcAddrLo UBIGINT,
+	DstAddr STRING,
+	DstAddrHi UBIGINT,
+	DstAddrLo UBIGINT,
+	DstPort USMALLINT,
+	Comm STRING,
+	Bytes UBIGINT,
+	Events UBIGINT,
+	FirstTs UBIGINT,
+	LastTs UBIGINT)`
+
+var netFlowsCmd = &cli.Command{
+	Name:  "flows",
+	Usage: "Aggregate raw network events into per-window flows and print top talkers",
+	Flags: slices.Concat([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.DurationFlag{
+			Name:  "window",
+			Value: 10 * time.Second,
+			Usage: "flow aggregation window",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Value: 10,
+			Usage: "number of top talkers to print (0 prints all)",
+		},
+		&cli.StringFlag{
+			Name:  "dsn",
+			Usage: "DuckDB connection string, also write flow records",
+		},
+		&cli.StringFlag{
+			Name:  "table",
+			Value: "flows",
+			Usage: "target table name",
+		},
+		netWhereFlag(),
+	}, taskFlags()),
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+		window := command.Duration("window")
+		if window <= 0 {
+			return fmt.Errorf("invalid window: %s", window)
+		}
+
+		filter, err := parseNetFilter(command.String("where"))
+		if err != nil {
+			return err
+		}
+
+		tasks, err := newTaskTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var emit func(f *netFlow) error
+		if dsn := command.String("dsn"); dsn != "" {
+			tableName := command.String("table")
+
+			connector, err := duckdb.NewConnector(dsn, nil)
+			if err != nil {
+				return err
+			}
+
+			conn, err := connector.Connect(ctx)
+			if err != nil {
+				return err
+			}
+
+			db := sql.OpenDB(connector)
+			defer func() { _ = db.Close() }()
+
+			_, err = db.Exec(dropNetTableSQL + tableName)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.Exec(fmt.Sprintf(createFlowsTableSQL, tableName))
+			if err != nil {
+				return err
+			}
+
+			appender, err := duckdb.NewAppenderFromConn(conn, "", tableName)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = appender.Close() }()
+
+			emit = func(f *netFlow) error {
+				family := netAddrFamily(f.SrcAddr)
+				if family == nil {
+					family = netAddrFamily(f.DstAddr)
+				}
+				srcText, srcHi, srcLo := netAddrColumns(f.SrcAddr)
+				dstText, dstHi, dstLo := netAddrColumns(f.DstAddr)
+				return appender.AppendRow(f.WindowStart, uint64(window), f.Protocol, family,
+					srcText, srcHi, srcLo, dstText, dstHi, dstLo,
+					f.DstPort, f.Comm, f.Bytes, uint64(f.Events), f.FirstTs, f.LastTs)
+			}
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		agg := newNetFlowAggregator(window, emit)
+		err = netJSONParseThenAppend(r, func(e *netRawEvent) error {
+			e.enrich(tasks)
+			if !filter.Match(e) {
+				return nil
+			}
+			return agg.Handle(e)
+		}, tasks.Intercept)
+		if err != nil {
+			return err
+		}
+		if err := agg.Flush(); err != nil {
+			return err
+		}
+
+		printNetFlows(os.Stdout, agg.TopTalkers(command.Int("top")), format)
+		return nil
+	},
+}
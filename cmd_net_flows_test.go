@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestNetFlowAggregator tests per-window flow records and top talkers
+func TestNetFlowAggregator(t *testing.T) {
+	var emitted []netFlow
+	agg := newNetFlowAggregator(10*time.Second, func(f *netFlow) error {
+		emitted = append(emitted, *f)
+		return nil
+	})
+
+	sec := uint64(time.Second)
+	events := []netRawEvent{
+		{Timestamp: 1 * sec, Protocol: "tcp", SrcAddr: netip.MustParseAddr("10.0.0.1"), DstAddr: netip.MustParseAddr("10.0.0.2"), DstPort: 443, Comm: "curl", Bytes: 100},
+		{Timestamp: 2 * sec, Protocol: "tcp", SrcAddr: netip.MustParseAddr("10.0.0.1"), DstAddr: netip.MustParseAddr("10.0.0.2"), DstPort: 443, Comm: "curl", Bytes: 200},
+		{Timestamp: 3 * sec, Protocol: "udp", SrcAddr: netip.MustParseAddr("10.0.0.1"), DstAddr: netip.MustParseAddr("10.0.0.53"), DstPort: 53, Comm: "dig", Bytes: 40},
+		{Timestamp: 12 * sec, Protocol: "tcp", SrcAddr: netip.MustParseAddr("10.0.0.1"), DstAddr: netip.MustParseAddr("10.0.0.2"), DstPort: 443, Comm: "curl", Bytes: 50},
+		{Timestamp: 9 * sec, Protocol: "udp", SrcAddr: netip.MustParseAddr("10.0.0.1"), DstAddr: netip.MustParseAddr("10.0.0.53"), DstPort: 53, Comm: "dig", Bytes: 1000},
+	}
+	for i := range events {
+		if err := agg.Handle(&events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(emitted) != 2 {
+		t.Fatalf("expected first window to emit 2 flows, got %d", len(emitted))
+	}
+	if emitted[0].Bytes != 300 || emitted[0].Events != 2 || emitted[0].WindowStart != 0 {
+		t.Errorf("unexpected first flow: %+v", emitted[0])
+	}
+	if err := agg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitted) != 4 || emitted[2].WindowStart != 10*sec {
+		t.Fatalf("unexpected second window: %+v", emitted[2:])
+	}
+
+	top := agg.TopTalkers(1)
+	if len(top) != 1 || top[0].Comm != "dig" || top[0].Bytes != 1040 {
+		t.Errorf("unexpected top talker: %+v", top)
+	}
+}
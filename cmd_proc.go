@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,8 @@ var procCmd = &cli.Command{
 	Commands: []*cli.Command{
 		procCountCmd,
 		procRawCmd,
+		procTreeCmd,
+		procShortCmd,
 	},
 }
 
@@ -35,6 +38,9 @@ type ProcCountEvent struct {
 	Fork  int64 `json:"fork"`
 	Exit  int64 `json:"exit"`
 	Clone int64 `json:"clone"`
+
+	// Other holds map keys beyond the known operations, e.g. comm,probe,path keys.
+	Other otherCounts `json:"other,omitempty"`
 }
 
 // Add accumulates counts from another ProcCountEvent.
@@ -43,13 +49,28 @@ func (e *ProcCountEvent) Add(other *ProcCountEvent) {
 	e.Fork += other.Fork
 	e.Exit += other.Exit
 	e.Clone += other.Clone
+	e.Other.Add(other.Other)
 }
 
-// Total returns the sum of all operation counts.
+// Total returns the sum of the known operation counts; Other keys are left out.
 func (e *ProcCountEvent) Total() int64 {
 	return e.Exec + e.Fork + e.Exit + e.Clone
 }
 
+// Values returns the counts keyed by operation name.
+func (e *ProcCountEvent) Values() map[string]int64 {
+	values := map[string]int64{
+		"exec":  e.Exec,
+		"fork":  e.Fork,
+		"exit":  e.Exit,
+		"clone": e.Clone,
+	}
+	for k, v := range e.Other {
+		values[k] = v
+	}
+	return values
+}
+
 // Fill populates the event from simdjson data.
 func (e *ProcCountEvent) Fill(el *simdjson.Element) error {
 	var err error
@@ -84,7 +105,7 @@ func (e *ProcCountEvent) Fill(el *simdjson.Element) error {
 		case "clone":
 			e.Clone = value
 		default:
-			log.Debug().Str("field", m.Name).Int64("value", value).Msg("Unknown field in map data")
+			e.Other.Set(m.Name, value)
 		}
 	}
 	return nil
@@ -105,6 +126,9 @@ func printProcEvent(e *ProcCountEvent, format string, intervalCount int) {
 		data, _ := json.Marshal(output)
 		fmt.Println(string(data))
 
+	case "folded":
+		NewCountOutput().PrintFolded(e.Values())
+
 	case "csv":
 		w := csv.NewWriter(os.Stdout)
 		_ = w.Write([]string{"Operation", "Count"})
@@ -113,6 +137,7 @@ func printProcEvent(e *ProcCountEvent, format string, intervalCount int) {
 		_ = w.Write([]string{"exit", fmt.Sprintf("%d", e.Exit)})
 		_ = w.Write([]string{"clone", fmt.Sprintf("%d", e.Clone)})
 		_ = w.Write([]string{"total", fmt.Sprintf("%d", e.Total())})
+		e.Other.writeCSV(w)
 		w.Flush()
 
 	default: // table
@@ -126,6 +151,7 @@ func printProcEvent(e *ProcCountEvent, format string, intervalCount int) {
 		_, _ = fmt.Fprintln(tw, "---------\t-----")
 		_, _ = fmt.Fprintf(tw, "Total\t%d\n", e.Total())
 		_, _ = fmt.Fprintf(tw, "Intervals\t%d\n", intervalCount)
+		e.Other.writeTable(tw)
 		_ = tw.Flush()
 	}
 }
@@ -133,7 +159,7 @@ func printProcEvent(e *ProcCountEvent, format string, intervalCount int) {
 var procCountCmd = &cli.Command{
 	Name:  "count",
 	Usage: "Aggregate process operation counts",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -143,13 +169,13 @@ var procCountCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "format",
 			Value: "table",
-			Usage: "output format: table, json, csv",
+			Usage: "output format: table, json, csv, folded, influx",
 		},
 		&cli.BoolFlag{
 			Name:  "live",
 			Usage: "live mode: print each interval as it arrives",
 		},
-	},
+	}, countFlags(), alertFlags(), otlpFlags(), statsdFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		var r io.Reader
 		input := command.String("input")
@@ -167,15 +193,37 @@ var procCountCmd = &cli.Command{
 		format := command.String("format")
 		live := command.Bool("live")
 
-		if err := ValidateFormat(format); err != nil {
+		if err := ValidateCountFormat(format); err != nil {
+			return err
+		}
+
+		alerts, err := NewAlertEvaluator(command)
+		if err != nil {
+			return err
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		influx, err := NewInfluxOutput(command, "proc")
+		if err != nil {
+			return err
+		}
+
+		statsd, err := NewStatsDSink(command)
+		if err != nil {
 			return err
 		}
+		defer statsd.Close()
 
 		var totalEvent ProcCountEvent
 		var intervalCount int
 
 		parser := &NDJSONParser{}
-		err := parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
+		err = parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 			switch msgType {
 			case "map":
 				var event ProcCountEvent
@@ -185,9 +233,17 @@ var procCountCmd = &cli.Command{
 				intervalCount++
 				totalEvent.Add(&event)
 
-				if live {
+				if influx != nil {
+					influx.Print(parser.StartTime, intervalCount, event.Values())
+				} else if live {
 					printProcEvent(&event, format, intervalCount)
 				}
+
+				otlp.Counters("proc", parser.StartTime, intervalCount, event.Values())
+				statsd.Send("proc", event.Values())
+				if err := alerts.Evaluate(ctx, intervalCount, event.Values()); err != nil {
+					return err
+				}
 			default:
 				log.Warn().Str("type", msgType).Msg("Unknown message type, skipping")
 			}
@@ -197,9 +253,12 @@ var procCountCmd = &cli.Command{
 			return err
 		}
 
+		if influx != nil {
+			return nil
+		}
 		if !live {
 			printProcEvent(&totalEvent, format, intervalCount)
-		} else if intervalCount > 1 {
+		} else if intervalCount > 1 && format != string(FormatFolded) {
 			fmt.Println("\n--- Total ---")
 			printProcEvent(&totalEvent, format, intervalCount)
 		}
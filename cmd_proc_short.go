@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// shortLivedGroup aggregates short-lived processes sharing a cmdline and parent comm.
+type shortLivedGroup struct {
+	Cmdline    string        `json:"cmdline"`
+	ParentComm string        `json:"parent_comm"`
+	Count      int64         `json:"count"`
+	Failed     int64         `json:"failed"`
+	Total      time.Duration `json:"total_ns"`
+	Max        time.Duration `json:"max_ns"`
+}
+
+// Avg returns the average lifetime of the group.
+func (g *shortLivedGroup) Avg() time.Duration {
+	if g.Count == 0 {
+		return 0
+	}
+	return g.Total / time.Duration(g.Count)
+}
+
+type shortLivedKey struct {
+	cmdline    string
+	parentComm string
+}
+
+// shortLivedReport collects processes whose fork-to-exit lifetime is below a threshold.
+type shortLivedReport struct {
+	threshold time.Duration
+	groups    map[shortLivedKey]*shortLivedGroup
+}
+
+func newShortLivedReport(threshold time.Duration) *shortLivedReport {
+	return &shortLivedReport{
+		threshold: threshold,
+		groups:    make(map[shortLivedKey]*shortLivedGroup),
+	}
+}
+
+// Observe records an exited process if it lived under the threshold.
+// It reports whether the process was short-lived.
+func (r *shortLivedReport) Observe(n *procNode) bool {
+	lifetime, ok := n.Lifetime()
+	if !ok || lifetime >= r.threshold {
+		return false
+	}
+	cmdline := n.Cmdline
+	if cmdline == "" {
+		cmdline = n.Comm
+	}
+	var parentComm string
+	if n.parent != nil {
+		parentComm = n.parent.Comm
+	}
+	key := shortLivedKey{cmdline: cmdline, parentComm: parentComm}
+	g, ok := r.groups[key]
+	if !ok {
+		g = &shortLivedGroup{Cmdline: cmdline, ParentComm: parentComm}
+		r.groups[key] = g
+	}
+	g.Count++
+	if n.ExitCode != 0 {
+		g.Failed++
+	}
+	g.Total += lifetime
+	if lifetime > g.Max {
+		g.Max = lifetime
+	}
+	return true
+}
+
+// Groups returns the groups sorted by count descending.
+func (r *shortLivedReport) Groups() []*shortLivedGroup {
+	groups := make([]*shortLivedGroup, 0, len(r.groups))
+	for _, g := range r.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Total > groups[j].Total
+	})
+	return groups
+}
+
+func printShortLived(w io.Writer, groups []*shortLivedGroup, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(groups)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Cmdline", "ParentComm", "Count", "Failed", "TotalNs", "AvgNs", "MaxNs"})
+		for _, g := range groups {
+			_ = cw.Write([]string{g.Cmdline, g.ParentComm,
+				strconv.FormatInt(g.Count, 10), strconv.FormatInt(g.Failed, 10),
+				strconv.FormatInt(int64(g.Total), 10), strconv.FormatInt(int64(g.Avg()), 10),
+				strconv.FormatInt(int64(g.Max), 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Cmdline\tParent\tCount\tFailed\tTotal\tAvg\tMax")
+		_, _ = fmt.Fprintln(tw, "-------\t------\t-----\t------\t-----\t---\t---")
+		var count int64
+		var total time.Duration
+		for _, g := range groups {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", g.Cmdline, g.ParentComm,
+				g.Count, g.Failed, g.Total, g.Avg(), g.Max)
+			count += g.Count
+			total += g.Total
+		}
+		_, _ = fmt.Fprintln(tw, "-------\t------\t-----\t------\t-----\t---\t---")
+		_, _ = fmt.Fprintf(tw, "Total\t\t%d\t\t%s\t\t\n", count, total)
+		_ = tw.Flush()
+	}
+}
+
+var procShortCmd = &cli.Command{
+	Name:  "short",
+	Usage: "Report processes that lived shorter than a threshold",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.DurationFlag{
+			Name:  "threshold",
+			Value: 100 * time.Millisecond,
+			Usage: "report processes with a fork-to-exit lifetime below this",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "only print the N largest groups (0 prints all)",
+		},
+		&cli.BoolFlag{
+			Name:  "live",
+			Usage: "live mode: log each short-lived process as it exits",
+		},
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+		live := command.Bool("live")
+
+		tree := newProcTree()
+		tree.keepExited = false
+		report := newShortLivedReport(command.Duration("threshold"))
+
+		err := procJSONParseThenAppend(r, func(e *procRawEvent) error {
+			n := tree.Handle(e)
+			if n == nil || !n.Exited {
+				return nil
+			}
+			if report.Observe(n) && live {
+				lifetime, _ := n.Lifetime()
+				var parentComm string
+				if n.parent != nil {
+					parentComm = n.parent.Comm
+				}
+				log.Info().Uint64("pid", n.Pid).Str("comm", n.Comm).Str("cmdline", n.Cmdline).
+					Str("parent", parentComm).Dur("lifetime", lifetime).Int64("exit_code", n.ExitCode).
+					Msg("Short-lived process")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		groups := report.Groups()
+		if top := command.Int("top"); top > 0 && len(groups) > top {
+			groups = groups[:top]
+		}
+		printShortLived(os.Stdout, groups, format)
+		return nil
+	},
+}
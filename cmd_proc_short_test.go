@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestShortLivedReport tests grouping of short-lived processes
+func TestShortLivedReport(t *testing.T) {
+	tree := newProcTree()
+	tree.keepExited = false
+	report := newShortLivedReport(5000)
+
+	var short int
+	err := procJSONParseThenAppend(strings.NewReader(procTreeTestData), func(e *procRawEvent) error {
+		if n := tree.Handle(e); n != nil && n.Exited && report.Observe(n) {
+			short++
+		}
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != 0 {
+		t.Errorf("got %d short-lived processes below 5us, want 0", short)
+	}
+
+	report = newShortLivedReport(10000)
+	tree = newProcTree()
+	tree.keepExited = false
+	err = procJSONParseThenAppend(strings.NewReader(procTreeTestData), func(e *procRawEvent) error {
+		if n := tree.Handle(e); n != nil && n.Exited {
+			report.Observe(n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := report.Groups()
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	g := groups[0]
+	if g.Cmdline != "cc -c a.c" || g.ParentComm != "make" || g.Count != 1 || g.Failed != 1 || g.Total != 6000 {
+		t.Errorf("unexpected group: %+v", g)
+	}
+	if len(tree.live[200].Children) != 1 {
+		t.Errorf("exited child should be detached, pid 200 has %d children", len(tree.live[200].Children))
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/urfave/cli/v3"
+)
+
+// procEventKind classifies a proc raw event by its probe.
+type procEventKind int
+
+const (
+	procEventOther procEventKind = iota
+	procEventFork
+	procEventExec
+	procEventExit
+)
+
+// classifyProcProbe maps probe names such as "tracepoint:sched:sched_process_fork"
+// or "exec" to the kind of process event they represent.
+func classifyProcProbe(probe string) procEventKind {
+	if i := strings.LastIndexByte(probe, ':'); i >= 0 {
+		probe = probe[i+1:]
+	}
+	switch {
+	case strings.Contains(probe, "fork"), strings.Contains(probe, "clone"):
+		return procEventFork
+	case strings.Contains(probe, "exec"):
+		return procEventExec
+	case strings.Contains(probe, "exit"):
+		return procEventExit
+	default:
+		return procEventOther
+	}
+}
+
+// procNode is a single process reconstructed from proc raw events.
+type procNode struct {
+	Pid         uint64      `json:"pid"`
+	Ppid        uint64      `json:"ppid"`
+	Comm        string      `json:"comm"`
+	Cmdline     string      `json:"cmdline,omitempty"`
+	Execs       []string    `json:"execs,omitempty"`
+	Start       uint64      `json:"start,omitempty"`
+	End         uint64      `json:"end,omitempty"`
+	Exited      bool        `json:"exited"`
+	ExitCode    int64       `json:"exit_code"`
+	Descendants int         `json:"descendants"`
+	Children    []*procNode `json:"children,omitempty"`
+
+	parent *procNode
+}
+
+// Lifetime returns the time between fork and exit, if both were captured.
+func (n *procNode) Lifetime() (time.Duration, bool) {
+	if n.Start == 0 || !n.Exited || n.End < n.Start {
+		return 0, false
+	}
+	return time.Duration(n.End - n.Start), true
+}
+
+// procTree rebuilds the process hierarchy from fork, exec and exit events.
+// Fork events are expected to carry the child in pid and the parent in ppid.
+type procTree struct {
+	live  map[uint64]*procNode
+	nodes []*procNode
+
+	// keepExited retains exited processes so the full tree can be rendered.
+	// Streaming consumers disable it to bound memory on long captures.
+	keepExited bool
+}
+
+func newProcTree() *procTree {
+	return &procTree{live: make(map[uint64]*procNode), keepExited: true}
+}
+
+func (t *procTree) newNode(pid, ppid uint64) *procNode {
+	n := &procNode{Pid: pid}
+	if t.keepExited {
+		t.nodes = append(t.nodes, n)
+	}
+	t.live[pid] = n
+	t.setParent(n, ppid)
+	return n
+}
+
+func (t *procTree) setParent(n *procNode, ppid uint64) {
+	if ppid == 0 || n.parent != nil || ppid == n.Pid {
+		return
+	}
+	n.Ppid = ppid
+	parent, ok := t.live[ppid]
+	if !ok {
+		// The parent was running before the capture started
+		parent = t.newNode(ppid, 0)
+	}
+	n.parent = parent
+	parent.Children = append(parent.Children, n)
+}
+
+func (t *procTree) lookup(pid, ppid uint64) *procNode {
+	n, ok := t.live[pid]
+	if !ok {
+		return t.newNode(pid, ppid)
+	}
+	t.setParent(n, ppid)
+	return n
+}
+
+// Handle applies a single proc raw event to the tree and returns the affected node.
+// It returns nil for events that do not change the tree, such as thread exits.
+func (t *procTree) Handle(e *procRawEvent) *procNode {
+	switch classifyProcProbe(e.Probe) {
+	case procEventFork:
+		n := t.newNode(e.Pid, e.Ppid)
+		n.Comm = e.Comm
+		n.Start = e.Timestamp
+		if n.parent != nil {
+			// A forked child starts with the parent's comm
+			if n.parent.Comm == "" {
+				n.parent.Comm = e.Comm
+			}
+			if n.Comm == "" {
+				n.Comm = n.parent.Comm
+			}
+			n.Cmdline = n.parent.Cmdline
+		}
+		return n
+
+	case procEventExec:
+		n := t.lookup(e.Pid, e.Ppid)
+		if e.Comm != "" {
+			n.Comm = e.Comm
+		}
+		cmdline := e.Cmdline
+		if cmdline == "" {
+			cmdline = e.Comm
+		}
+		n.Cmdline = cmdline
+		n.Execs = append(n.Execs, cmdline)
+		return n
+
+	case procEventExit:
+		if e.Tid != 0 && e.Tid != e.Pid {
+			return nil
+		}
+		n := t.lookup(e.Pid, e.Ppid)
+		if n.Comm == "" {
+			n.Comm = e.Comm
+		}
+		n.End = e.Timestamp
+		n.Exited = true
+		n.ExitCode = e.ExitCode
+		delete(t.live, e.Pid)
+		if !t.keepExited {
+			t.detach(n)
+		}
+		return n
+	}
+	return nil
+}
+
+// detach drops an exited, childless process from its parent, and then any
+// exited ancestors left without children.
+func (t *procTree) detach(n *procNode) {
+	for n != nil && n.Exited && len(n.Children) == 0 {
+		parent := n.parent
+		if parent == nil {
+			return
+		}
+		for i, c := range parent.Children {
+			if c == n {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+		n = parent
+	}
+}
+
+// Roots returns the processes without a known parent, with children sorted by start time
+// and descendant counts filled in.
+func (t *procTree) Roots() []*procNode {
+	var roots []*procNode
+	for _, n := range t.nodes {
+		if n.parent == nil {
+			roots = append(roots, n)
+		}
+	}
+	var finish func(n *procNode) int
+	finish = func(n *procNode) int {
+		sortProcNodes(n.Children)
+		n.Descendants = 0
+		for _, c := range n.Children {
+			n.Descendants += 1 + finish(c)
+		}
+		return n.Descendants
+	}
+	for _, r := range roots {
+		finish(r)
+	}
+	sortProcNodes(roots)
+	return roots
+}
+
+// Nodes returns every process seen in the capture, in order of appearance.
+func (t *procTree) Nodes() []*procNode {
+	return t.nodes
+}
+
+func sortProcNodes(nodes []*procNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Start != nodes[j].Start {
+			return nodes[i].Start < nodes[j].Start
+		}
+		return nodes[i].Pid < nodes[j].Pid
+	})
+}
+
+func formatLifetime(n *procNode) string {
+	if d, ok := n.Lifetime(); ok {
+		return d.String()
+	}
+	if !n.Exited {
+		return "running"
+	}
+	return "?"
+}
+
+func formatExitCode(n *procNode) string {
+	if !n.Exited {
+		return "-"
+	}
+	return fmt.Sprintf("%d", n.ExitCode)
+}
+
+// printProcTree prints the tree with indented PID columns. Groups of more than
+// collapse leaf children sharing the same comm are folded into a single line.
+func printProcTree(w io.Writer, roots []*procNode, collapse int) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PID\tCOMM\tLIFETIME\tEXIT\tDESCENDANTS\tEXECS")
+	var walk func(n *procNode, depth int)
+	walk = func(n *procNode, depth int) {
+		indent := strings.Repeat("  ", depth)
+		_, _ = fmt.Fprintf(tw, "%s%d\t%s\t%s\t%s\t%d\t%s\n", indent, n.Pid, n.Comm,
+			formatLifetime(n), formatExitCode(n), n.Descendants, strings.Join(n.Execs, " -> "))
+
+		leaves := make(map[string][]*procNode)
+		for _, c := range n.Children {
+			if len(c.Children) == 0 {
+				leaves[c.Comm] = append(leaves[c.Comm], c)
+			}
+		}
+		printed := make(map[string]bool)
+		for _, c := range n.Children {
+			group := leaves[c.Comm]
+			if len(c.Children) > 0 || collapse <= 0 || len(group) <= collapse {
+				walk(c, depth+1)
+				continue
+			}
+			if printed[c.Comm] {
+				continue
+			}
+			printed[c.Comm] = true
+			var total time.Duration
+			var failed int
+			for _, g := range group {
+				if d, ok := g.Lifetime(); ok {
+					total += d
+				}
+				if g.Exited && g.ExitCode != 0 {
+					failed++
+				}
+			}
+			_, _ = fmt.Fprintf(tw, "%s  x%d\t%s\ttotal %s\t%d failed\t0\t(collapsed)\n",
+				indent, len(group), c.Comm, total, failed)
+		}
+	}
+	for _, r := range roots {
+		walk(r, 0)
+	}
+	_ = tw.Flush()
+}
+
+const createProcessesTableSQL = `CREATE TABLE IF NOT EXISTS %s (
+	Pid UBIGINT,
+	Ppid UBIGINT,
+	Comm STRING,
+	Cmdline STRING,
+	Execs STRING[],
+	StartTs UBIGINT,
+	EndTs UBIGINT,
+	LifetimeNs UBIGINT,
+	Exited BOOLEAN,
+	ExitCode BIGINT,
+	Children UBIGINT,
+	Descendants UBIGINT)`
+
+func writeProcTable(ctx context.Context, dsn, tableName string, nodes []*procNode) error {
+	connector, err := duckdb.NewConnector(dsn, nil)
+	if err != nil {
+		return err
+	}
+
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(dropProcTableSQL + tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(createProcessesTableSQL, tableName))
+	if err != nil {
+		return err
+	}
+
+	appender, err := duckdb.NewAppenderFromConn(conn, "", tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		var lifetime any
+		if d, ok := n.Lifetime(); ok {
+			lifetime = uint64(d)
+		}
+		execs := n.Execs
+		if execs == nil {
+			execs = []string{}
+		}
+		err = appender.AppendRow(n.Pid, n.Ppid, n.Comm, n.Cmdline, execs, n.Start, n.End,
+			lifetime, n.Exited, n.ExitCode, uint64(len(n.Children)), uint64(n.Descendants))
+		if err != nil {
+			_ = appender.Close()
+			return err
+		}
+	}
+	return appender.Close()
+}
+
+var procTreeCmd = &cli.Command{
+	Name:  "tree",
+	Usage: "Rebuild the process tree from raw process events",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "tree",
+			Usage: "output format: tree, json",
+		},
+		&cli.IntFlag{
+			Name:  "collapse",
+			Value: 10,
+			Usage: "fold more than this many leaf children with the same comm into one line (0 disables)",
+		},
+		&cli.StringFlag{
+			Name:  "dsn",
+			Usage: "DuckDB connection string to also write the processes table to",
+		},
+		&cli.StringFlag{
+			Name:  "table",
+			Value: "processes",
+			Usage: "target table name",
+		},
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		switch format {
+		case "tree", "json":
+		default:
+			return fmt.Errorf("invalid format: %s (must be tree or json)", format)
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		tree := newProcTree()
+		err := procJSONParseThenAppend(r, func(e *procRawEvent) error {
+			tree.Handle(e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		roots := tree.Roots()
+		if format == "json" {
+			data, _ := json.Marshal(roots)
+			fmt.Println(string(data))
+		} else {
+			printProcTree(os.Stdout, roots, command.Int("collapse"))
+		}
+
+		if dsn := command.String("dsn"); dsn != "" {
+			return writeProcTable(ctx, dsn, command.String("table"), tree.Nodes())
+		}
+		return nil
+	},
+}
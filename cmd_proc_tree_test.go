@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const procTreeTestData = `{"type": "attached_probes", "data": {"probes": 3}}
+{"type": "printf", "data": "ts=1000 fn=sched_process_fork pid=200 ppid=100 tid=200 comm='bash'"}
+{"type": "printf", "data": "ts=2000 fn=sched_process_exec pid=200 ppid=100 tid=200 comm='make' cmdline=\"make -j8\""}
+{"type": "printf", "data": "ts=3000 fn=sched_process_fork pid=300 ppid=200 tid=300 comm='make'"}
+{"type": "printf", "data": "ts=4000 fn=sched_process_exec pid=300 ppid=200 tid=300 comm='cc' cmdline=\"cc -c a.c\""}
+{"type": "printf", "data": "ts=5000 fn=sched_process_exit pid=300 ppid=200 tid=301 comm='cc' exit_code=0"}
+{"type": "printf", "data": "ts=9000 fn=sched_process_exit pid=300 ppid=200 tid=300 comm='cc' exit_code=1"}
+{"type": "printf", "data": "ts=9500 fn=sched_process_fork pid=300 ppid=200 tid=300 comm='make'"}
+`
+
+// TestClassifyProcProbe tests probe classification
+func TestClassifyProcProbe(t *testing.T) {
+	tests := map[string]procEventKind{
+		"tracepoint:sched:sched_process_fork": procEventFork,
+		"sched_process_exec":                  procEventExec,
+		"exit":                                procEventExit,
+		"kprobe:kernel_clone":                 procEventFork,
+		"sched_switch":                        procEventOther,
+	}
+	for probe, want := range tests {
+		if got := classifyProcProbe(probe); got != want {
+			t.Errorf("classifyProcProbe(%q) = %d, want %d", probe, got, want)
+		}
+	}
+}
+
+// TestProcTreeHandle tests rebuilding the hierarchy, exec chains and pid reuse
+func TestProcTreeHandle(t *testing.T) {
+	tree := newProcTree()
+	err := procJSONParseThenAppend(strings.NewReader(procTreeTestData), func(e *procRawEvent) error {
+		tree.Handle(e)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := tree.Roots()
+	if len(roots) != 1 || roots[0].Pid != 100 {
+		t.Fatalf("expected a single root with pid 100, got %+v", roots)
+	}
+	if roots[0].Descendants != 3 {
+		t.Errorf("root descendants = %d, want 3", roots[0].Descendants)
+	}
+
+	makeNode := roots[0].Children[0]
+	if makeNode.Comm != "make" || makeNode.Cmdline != "make -j8" {
+		t.Errorf("unexpected node for pid 200: %+v", makeNode)
+	}
+	if len(makeNode.Children) != 2 {
+		t.Fatalf("pid 200 has %d children, want 2 (pid reuse)", len(makeNode.Children))
+	}
+
+	cc := makeNode.Children[0]
+	if !cc.Exited || cc.ExitCode != 1 {
+		t.Errorf("thread exit should be ignored, got exited=%v code=%d", cc.Exited, cc.ExitCode)
+	}
+	if d, ok := cc.Lifetime(); !ok || d != 6000 {
+		t.Errorf("cc lifetime = %v, %v, want 6000ns", d, ok)
+	}
+	if len(cc.Execs) != 1 || cc.Execs[0] != "cc -c a.c" {
+		t.Errorf("cc exec chain = %v", cc.Execs)
+	}
+	if makeNode.Children[1].Exited {
+		t.Error("reused pid should be a new running process")
+	}
+
+	var buf bytes.Buffer
+	printProcTree(&buf, roots, 0)
+	if !strings.Contains(buf.String(), "    300") {
+		t.Errorf("tree output missing indented child:\n%s", buf.String())
+	}
+}
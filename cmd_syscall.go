@@ -7,12 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/duckdb/duckdb-go/v2"
 	"github.com/kr/logfmt"
@@ -27,6 +30,8 @@ var syscallCmd = &cli.Command{
 	Commands: []*cli.Command{
 		syscallCountCmd,
 		syscallRawCmd,
+		syscallPrintCmd,
+		syscallLatencyCmd,
 	},
 }
 
@@ -56,6 +61,11 @@ func (e *SyscallCountEvent) Total() int64 {
 	return total
 }
 
+// Values returns the counts keyed by syscall name.
+func (e *SyscallCountEvent) Values() map[string]int64 {
+	return e.Counts
+}
+
 // Fill populates the event from simdjson data.
 func (e *SyscallCountEvent) Fill(el *simdjson.Element) error {
 	var err error
@@ -112,6 +122,9 @@ func printSyscallEvent(e *SyscallCountEvent, format string, intervalCount int) {
 		data, _ := json.Marshal(output)
 		fmt.Println(string(data))
 
+	case "folded":
+		NewCountOutput().PrintFolded(e.Values())
+
 	case "csv":
 		w := csv.NewWriter(os.Stdout)
 		_ = w.Write([]string{"Syscall", "Count"})
@@ -138,7 +151,7 @@ func printSyscallEvent(e *SyscallCountEvent, format string, intervalCount int) {
 var syscallCountCmd = &cli.Command{
 	Name:  "count",
 	Usage: "Aggregate system call counts",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -148,13 +161,23 @@ var syscallCountCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "format",
 			Value: "table",
-			Usage: "output format: table, json, csv",
+			Usage: "output format: table, json, csv, folded, influx",
 		},
 		&cli.BoolFlag{
 			Name:  "live",
 			Usage: "live mode: print each interval as it arrives",
 		},
-	},
+		syscallArchFlag(),
+		&cli.BoolFlag{
+			Name:  "latency",
+			Usage: "report latency percentiles from the hist() maps of the script instead of counts",
+		},
+		&cli.DurationFlag{
+			Name:  "latency-unit",
+			Value: time.Nanosecond,
+			Usage: "unit of the hist() values, e.g. 1us when the script divides nsecs by 1000",
+		},
+	}, countFlags(), alertFlags(), otlpFlags(), statsdFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		var r io.Reader
 		input := command.String("input")
@@ -171,28 +194,85 @@ var syscallCountCmd = &cli.Command{
 
 		format := command.String("format")
 		live := command.Bool("live")
+		latency := command.Bool("latency")
 
-		if err := ValidateFormat(format); err != nil {
+		validate := ValidateCountFormat
+		if latency {
+			validate = ValidateFormat
+		}
+		if err := validate(format); err != nil {
+			return err
+		}
+
+		table, err := syscallTableFromFlags(command)
+		if err != nil {
 			return err
 		}
 
+		alerts, err := NewAlertEvaluator(command)
+		if err != nil {
+			return err
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		influx, err := NewInfluxOutput(command, "syscall")
+		if err != nil {
+			return err
+		}
+
+		statsd, err := NewStatsDSink(command)
+		if err != nil {
+			return err
+		}
+		defer statsd.Close()
+
 		totalEvent := NewSyscallCountEvent()
-		var intervalCount int
+		totalHists := newSyscallLatencyHists(command.Duration("latency-unit"))
+		var intervalCount, histCount int
 
 		parser := &NDJSONParser{}
-		err := parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
+		err = parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 			switch msgType {
 			case "map":
 				event := NewSyscallCountEvent()
 				if err := event.Fill(data); err != nil {
 					return fmt.Errorf("failed to fill event from map data: %w", err)
 				}
+				event.Counts = table.RenameKeys(event.Counts)
 				intervalCount++
 				totalEvent.Add(event)
 
-				if live {
+				if influx != nil {
+					influx.Print(parser.StartTime, intervalCount, event.Values())
+				} else if live && !latency {
 					printSyscallEvent(event, format, intervalCount)
 				}
+
+				otlp.Counters("syscall", parser.StartTime, intervalCount, event.Values())
+				statsd.Send("syscall", event.Values())
+				if err := alerts.Evaluate(ctx, intervalCount, event.Values()); err != nil {
+					return err
+				}
+			case "hist":
+				if !latency {
+					log.Warn().Msg("hist maps are only read with --latency, skipping")
+					return nil
+				}
+				hists := newSyscallLatencyHists(totalHists.unit)
+				if err := hists.Fill(data); err != nil {
+					return err
+				}
+				histCount++
+				totalHists.Add(hists)
+
+				if live {
+					printSyscallLatency(os.Stdout, hists.Stats(), "Syscall", format)
+				}
 			default:
 				log.Warn().Str("type", msgType).Msg("Unknown message type, skipping")
 			}
@@ -202,9 +282,22 @@ var syscallCountCmd = &cli.Command{
 			return err
 		}
 
+		if latency {
+			if !live || histCount > 1 {
+				if live {
+					fmt.Println("\n--- Total ---")
+				}
+				printSyscallLatency(os.Stdout, totalHists.Stats(), "Syscall", format)
+			}
+			return nil
+		}
+
+		if influx != nil {
+			return nil
+		}
 		if !live {
 			printSyscallEvent(totalEvent, format, intervalCount)
-		} else if intervalCount > 1 {
+		} else if intervalCount > 1 && format != string(FormatFolded) {
 			fmt.Println("\n--- Total ---")
 			printSyscallEvent(totalEvent, format, intervalCount)
 		}
@@ -216,6 +309,7 @@ var syscallCountCmd = &cli.Command{
 // syscallRawEvent holds a single syscall event.
 type syscallRawEvent struct {
 	Timestamp   uint64
+	Probe       string
 	Pid         uint64
 	Tid         uint64
 	Comm        string
@@ -228,6 +322,20 @@ type syscallRawEvent struct {
 	Arg4        uint64
 	Arg5        uint64
 	ReturnValue int64
+	Errno       string
+	Args        syscallArgs
+
+	// Path, SrcAddr, SrcPort, DstAddr and DstPort are optional fields that
+	// name the file or socket of open, connect and accept calls.
+	Path    string
+	SrcAddr netip.Addr
+	SrcPort uint16
+	DstAddr netip.Addr
+	DstPort uint16
+
+	// hasNr is set when the event carried a syscall number, since 0 is a
+	// valid one.
+	hasNr bool
 }
 
 var syscallRawEventPool = sync.Pool{
@@ -244,6 +352,8 @@ func (e *syscallRawEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 	switch k {
 	case "ts":
 		e.Timestamp, err = strconv.ParseUint(v, 10, 64)
+	case "probe":
+		e.Probe = v
 	case "pid":
 		e.Pid, err = strconv.ParseUint(v, 10, 64)
 	case "tid":
@@ -252,6 +362,7 @@ func (e *syscallRawEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 		e.Comm = strings.Trim(v, "'\"")
 	case "nr":
 		e.SyscallNr, err = strconv.ParseUint(v, 10, 64)
+		e.hasNr = true
 	case "name":
 		e.SyscallName = v
 	case "arg0":
@@ -268,14 +379,56 @@ func (e *syscallRawEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 		e.Arg5, err = strconv.ParseUint(v, 0, 64)
 	case "ret":
 		e.ReturnValue, err = strconv.ParseInt(v, 10, 64)
+	case "path", "filename":
+		e.Path = strings.Trim(v, "'\"")
+	case "saddr":
+		// An unparsable address leaves the socket unnamed.
+		e.SrcAddr, _ = parseNetAddr(v)
+	case "sport":
+		var port uint64
+		port, err = strconv.ParseUint(v, 10, 16)
+		e.SrcPort = uint16(port)
+	case "daddr":
+		e.DstAddr, _ = parseNetAddr(v)
+	case "dport":
+		var port uint64
+		port, err = strconv.ParseUint(v, 10, 16)
+		e.DstPort = uint16(port)
 	default:
 		err = ErrUnknownSyscallField
 	}
 	return
 }
 
+// resolve fills in the syscall name from the probe or the number when the
+// script did not emit one, and the errno name of a failed call.
+func (e *syscallRawEvent) resolve(syscalls syscallTable) {
+	if e.SyscallName == "" {
+		e.SyscallName, _, _ = syscallProbeKind(e.Probe)
+	}
+	if e.SyscallName == "" && e.hasNr {
+		e.SyscallName = syscalls.Name(e.SyscallNr)
+	}
+	e.Errno = errnoName(e.ReturnValue)
+}
+
+// args returns the raw syscall arguments.
+func (e *syscallRawEvent) args() [6]uint64 {
+	return [6]uint64{e.Arg0, e.Arg1, e.Arg2, e.Arg3, e.Arg4, e.Arg5}
+}
+
+// argsColumn returns the decoded arguments for the Args JSON column, which
+// the appender marshals itself, or nil when they were not decoded.
+func (e *syscallRawEvent) argsColumn() any {
+	if e.Args == nil {
+		return nil
+	}
+	return e.Args
+}
+
 const createSyscallTableSQL = `CREATE TABLE IF NOT EXISTS %s (
 	Ts UBIGINT,
+	Probe STRING,
 	Pid UBIGINT,
 	Tid UBIGINT,
 	Comm STRING,
@@ -287,14 +440,24 @@ const createSyscallTableSQL = `CREATE TABLE IF NOT EXISTS %s (
 	Arg3 UBIGINT,
 	Arg4 UBIGINT,
 	Arg5 UBIGINT,
-	ReturnValue BIGINT)`
+	ReturnValue BIGINT,
+	Errno STRING,
+	Args JSON,
+	LatencyNs UBIGINT,
+	Fd BIGINT,
+	FdTarget STRING)`
 
 const dropSyscallTableSQL = `DROP TABLE IF EXISTS `
 
 type syscallAppendRowFn = func(e *syscallRawEvent) error
 
 func syscallJSONParseThenAppend(r io.Reader, appendRow syscallAppendRowFn) error {
-	parser := &NDJSONParser{}
+	return syscallParseStream(&NDJSONParser{}, r, appendRow)
+}
+
+// syscallParseStream is syscallJSONParseThenAppend with a caller-owned parser,
+// for commands that need the capture start time.
+func syscallParseStream(parser *NDJSONParser, r io.Reader, appendRow syscallAppendRowFn) error {
 	return parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
 		switch msgType {
 		case "printf":
@@ -319,10 +482,33 @@ func syscallJSONParseThenAppend(r io.Reader, appendRow syscallAppendRowFn) error
 	})
 }
 
+// exportSyscallSpan sends a completed call as a span named after the syscall.
+// Failed calls carry their errno as the error status.
+func exportSyscallSpan(otlp *OTLPExporter, decoder *syscallArgDecoder, c *syscallCall) {
+	if otlp == nil {
+		return
+	}
+	name := c.Name
+	if name == "" {
+		name = "syscall_" + strconv.FormatUint(c.Nr, 10)
+	}
+	attrs := map[string]any{
+		"thread.id":       c.Tid,
+		"process.pid":     c.Pid,
+		"process.command": c.Comm,
+		"syscall.ret":     formatSyscallRet(c),
+		"file.path":       c.Path,
+	}
+	if c.HasArgs {
+		attrs["syscall.args"] = formatSyscallArgs(decoder, name, c.Args)
+	}
+	otlp.Span(name, c.Tid, c.Start, c.End, attrs, c.Errno)
+}
+
 var syscallRawCmd = &cli.Command{
 	Name:  "raw",
 	Usage: "Write raw syscall events to DuckDB",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -339,11 +525,46 @@ var syscallRawCmd = &cli.Command{
 			Required: true,
 			Usage:    "target table name",
 		},
-	},
+		syscallArchFlag(),
+		&cli.BoolFlag{
+			Name:  "decode-args",
+			Usage: "decode the arguments of common syscalls into the Args JSON column",
+		},
+		&cli.BoolFlag{
+			Name:  "track-fds",
+			Usage: "track per-process fd tables and fill the Fd and FdTarget columns",
+		},
+	}, otlpFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		dsn := command.String("dsn")
 		tableName := command.String("table")
 
+		syscalls, err := syscallTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var decoder *syscallArgDecoder
+		if command.Bool("decode-args") {
+			decoder, err = syscallArgDecoderFromFlags(command)
+			if err != nil {
+				return err
+			}
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+		spanDecoder := decoder
+		if otlp != nil && spanDecoder == nil {
+			spanDecoder, err = syscallArgDecoderFromFlags(command)
+			if err != nil {
+				return err
+			}
+		}
+
 		connector, err := duckdb.NewConnector(dsn, nil)
 		if err != nil {
 			return err
@@ -386,10 +607,55 @@ var syscallRawCmd = &cli.Command{
 			r = f
 		}
 
-		return syscallJSONParseThenAppend(r, func(e *syscallRawEvent) error {
-			return appender.AppendRow(e.Timestamp, e.Pid, e.Tid, e.Comm,
+		var fds *fdTracker
+		if command.Bool("track-fds") {
+			fds = newFdTracker()
+		}
+
+		// Exit rows paired with their entry carry the call latency.
+		pairer := newSyscallPairer()
+		parser := &NDJSONParser{}
+		err = syscallParseStream(parser, r, func(e *syscallRawEvent) error {
+			e.resolve(syscalls)
+			if decoder != nil {
+				e.Args = decoder.Decode(e.SyscallName, e.args())
+			}
+			c, stale := pairer.Handle(e)
+			otlp.SetStart(parser.StartTime, e.Timestamp)
+			if stale != nil {
+				exportSyscallSpan(otlp, spanDecoder, stale)
+			}
+			if c != nil {
+				exportSyscallSpan(otlp, spanDecoder, c)
+			}
+			var latency, fd, fdTarget any
+			if c != nil && c.Timed() {
+				latency = uint64(c.Duration())
+			}
+			if fds != nil {
+				if n, target, ok := fds.Handle(c); ok {
+					fd = n
+					if target != nil {
+						fdTarget = target.String()
+					}
+				}
+			}
+			return appender.AppendRow(e.Timestamp, nullString(e.Probe), e.Pid, e.Tid, e.Comm,
 				e.SyscallNr, e.SyscallName, e.Arg0, e.Arg1, e.Arg2,
-				e.Arg3, e.Arg4, e.Arg5, e.ReturnValue)
+				e.Arg3, e.Arg4, e.Arg5, e.ReturnValue, nullString(e.Errno), e.argsColumn(), latency,
+				fd, fdTarget)
 		})
+		if err != nil {
+			return err
+		}
+
+		// Calls still waiting for their exit are exported without an end.
+		for _, c := range pairer.Pending() {
+			exportSyscallSpan(otlp, spanDecoder, c)
+		}
+		if fds != nil && fds.Unknown > 0 {
+			log.Info().Int64("lookups", fds.Unknown).Msg("Fds opened before the capture, FdTarget left NULL")
+		}
+		return nil
 	},
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minio/simdjson-go"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// syscallLatencyStats holds the latency of the calls of one syscall or comm.
+type syscallLatencyStats struct {
+	Key     string          `json:"key"`
+	Errors  int64           `json:"errors"`
+	Latency durationSummary `json:"latency"`
+	// FromHist is set for stats read from bpftrace histograms, which carry
+	// no errors or total time.
+	FromHist bool `json:"from_hist,omitempty"`
+
+	samples []time.Duration
+}
+
+// syscallLatencyReport groups paired syscall durations by syscall name or comm.
+type syscallLatencyReport struct {
+	by       string
+	groups   map[string]*syscallLatencyStats
+	Unpaired int64
+}
+
+func newSyscallLatencyReport(by string) *syscallLatencyReport {
+	return &syscallLatencyReport{by: by, groups: make(map[string]*syscallLatencyStats)}
+}
+
+// Add records a call. Calls without both an entry and an exit have no duration
+// and are only counted as unpaired.
+func (r *syscallLatencyReport) Add(c *syscallCall) {
+	if !c.Timed() {
+		r.Unpaired++
+		return
+	}
+	key := c.Name
+	if r.by == "comm" {
+		key = c.Comm
+	}
+	s, ok := r.groups[key]
+	if !ok {
+		s = &syscallLatencyStats{Key: key}
+		r.groups[key] = s
+	}
+	s.samples = append(s.samples, c.Duration())
+	if c.Errno != "" {
+		s.Errors++
+	}
+}
+
+// Stats summarizes each group, slowest total first.
+func (r *syscallLatencyReport) Stats() []*syscallLatencyStats {
+	stats := make([]*syscallLatencyStats, 0, len(r.groups))
+	for _, s := range r.groups {
+		s.Latency = summarizeDurations(s.samples)
+		stats = append(stats, s)
+	}
+	sortSyscallLatency(stats)
+	return stats
+}
+
+func sortSyscallLatency(stats []*syscallLatencyStats) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Latency.Total != stats[j].Latency.Total {
+			return stats[i].Latency.Total > stats[j].Latency.Total
+		}
+		if stats[i].Latency.Count != stats[j].Latency.Count {
+			return stats[i].Latency.Count > stats[j].Latency.Count
+		}
+		return stats[i].Key < stats[j].Key
+	})
+}
+
+// latencyHistBucket is one bucket of a bpftrace hist() or lhist() map.
+// Max is negative for the open-ended last bucket.
+type latencyHistBucket struct {
+	Min int64
+	Max int64
+}
+
+// latencyHist accumulates the buckets of one bpftrace histogram key across intervals.
+type latencyHist struct {
+	counts map[latencyHistBucket]int64
+}
+
+// syscallLatencyHists collects bpftrace histograms of syscall latency keyed
+// by map key, for scripts that time calls themselves.
+type syscallLatencyHists struct {
+	unit  time.Duration
+	hists map[string]*latencyHist
+}
+
+func newSyscallLatencyHists(unit time.Duration) *syscallLatencyHists {
+	return &syscallLatencyHists{unit: unit, hists: make(map[string]*latencyHist)}
+}
+
+// Fill adds the buckets of a "hist" message. Keyed maps give one histogram
+// per key; an unkeyed map is named after the map.
+func (h *syscallLatencyHists) Fill(el *simdjson.Element) error {
+	v, err := el.Iter.Interface()
+	if err != nil {
+		return fmt.Errorf("failed to parse hist data: %w", err)
+	}
+	maps, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("hist data is not an object")
+	}
+	for mapName, m := range maps {
+		switch m := m.(type) {
+		case []any:
+			h.add(strings.TrimPrefix(mapName, "@"), m)
+		case map[string]any:
+			for key, buckets := range m {
+				if buckets, ok := buckets.([]any); ok {
+					h.add(key, buckets)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Add merges the histograms of another interval.
+func (h *syscallLatencyHists) Add(other *syscallLatencyHists) {
+	for key, o := range other.hists {
+		hist := h.hist(key)
+		for b, n := range o.counts {
+			hist.counts[b] += n
+		}
+	}
+}
+
+func (h *syscallLatencyHists) hist(key string) *latencyHist {
+	hist, ok := h.hists[key]
+	if !ok {
+		hist = &latencyHist{counts: make(map[latencyHistBucket]int64)}
+		h.hists[key] = hist
+	}
+	return hist
+}
+
+func (h *syscallLatencyHists) add(key string, buckets []any) {
+	hist := h.hist(key)
+	for _, b := range buckets {
+		obj, ok := b.(map[string]any)
+		if !ok {
+			continue
+		}
+		count, _ := jsonInt(obj["count"])
+		bucketMin, hasMin := jsonInt(obj["min"])
+		bucketMax, hasMax := jsonInt(obj["max"])
+		if !hasMin {
+			// The underflow bucket of negative values.
+			continue
+		}
+		if !hasMax {
+			bucketMax = -1
+		}
+		hist.counts[latencyHistBucket{Min: bucketMin, Max: bucketMax}] += count
+	}
+}
+
+// Stats summarizes each histogram. Percentiles and the maximum are the upper
+// bound of the bucket they fall in, or its lower bound for the last open bucket;
+// the total is not known.
+func (h *syscallLatencyHists) Stats() []*syscallLatencyStats {
+	stats := make([]*syscallLatencyStats, 0, len(h.hists))
+	for key, hist := range h.hists {
+		stats = append(stats, &syscallLatencyStats{Key: key, Latency: hist.summary(h.unit), FromHist: true})
+	}
+	sortSyscallLatency(stats)
+	return stats
+}
+
+func (hist *latencyHist) summary(unit time.Duration) durationSummary {
+	buckets := make([]latencyHistBucket, 0, len(hist.counts))
+	var s durationSummary
+	for b, n := range hist.counts {
+		if n > 0 {
+			buckets = append(buckets, b)
+			s.Count += n
+		}
+	}
+	if s.Count == 0 {
+		return s
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Min < buckets[j].Min })
+	upper := func(b latencyHistBucket) time.Duration {
+		if b.Max < 0 {
+			return time.Duration(b.Min) * unit
+		}
+		return time.Duration(b.Max) * unit
+	}
+	at := func(p float64) time.Duration {
+		rank := int64(math.Ceil(p / 100 * float64(s.Count)))
+		var seen int64
+		for _, b := range buckets {
+			seen += hist.counts[b]
+			if seen >= rank {
+				return upper(b)
+			}
+		}
+		return upper(buckets[len(buckets)-1])
+	}
+	s.P50 = at(50)
+	s.P90 = at(90)
+	s.P99 = at(99)
+	s.Max = upper(buckets[len(buckets)-1])
+	return s
+}
+
+// jsonInt converts a number decoded by simdjson to int64.
+func jsonInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func printSyscallLatency(w io.Writer, stats []*syscallLatencyStats, keyHeader, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(stats)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{keyHeader, "Count", "Errors", "AvgNs", "P50Ns", "P90Ns", "P99Ns", "MaxNs", "TotalNs"})
+		for _, s := range stats {
+			_ = cw.Write([]string{s.Key,
+				strconv.FormatInt(s.Latency.Count, 10), strconv.FormatInt(s.Errors, 10),
+				strconv.FormatInt(int64(s.Latency.Avg()), 10),
+				strconv.FormatInt(int64(s.Latency.P50), 10), strconv.FormatInt(int64(s.Latency.P90), 10),
+				strconv.FormatInt(int64(s.Latency.P99), 10), strconv.FormatInt(int64(s.Latency.Max), 10),
+				strconv.FormatInt(int64(s.Latency.Total), 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "%s\tCount\tErrors\tAvg\tp50\tp90\tp99\tMax\tTotal\n", keyHeader)
+		_, _ = fmt.Fprintf(tw, "%s\t-----\t------\t---\t---\t---\t---\t---\t-----\n", strings.Repeat("-", len(keyHeader)))
+		for _, s := range stats {
+			errors, avg, total := strconv.FormatInt(s.Errors, 10), s.Latency.Avg().String(), s.Latency.Total.String()
+			if s.FromHist {
+				errors, avg, total = "-", "-", "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.Key, s.Latency.Count, errors, avg,
+				s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.Max, total)
+		}
+		_ = tw.Flush()
+	}
+}
+
+var syscallLatencyCmd = &cli.Command{
+	Name:  "latency",
+	Usage: "Pair syscall entry and exit events and report latency percentiles",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.StringFlag{
+			Name:  "by",
+			Value: "name",
+			Usage: "group calls by: name, comm",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "show only the N groups with the most total time (0 for all)",
+		},
+		syscallArchFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+		by := command.String("by")
+		if by != "name" && by != "comm" {
+			return fmt.Errorf("invalid --by: %s (must be name or comm)", by)
+		}
+		syscalls, err := syscallTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		report := newSyscallLatencyReport(by)
+		pairer := newSyscallPairer()
+		err = syscallJSONParseThenAppend(r, func(e *syscallRawEvent) error {
+			e.resolve(syscalls)
+			c, stale := pairer.Handle(e)
+			if stale != nil {
+				report.Add(stale)
+			}
+			if c != nil {
+				report.Add(c)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		report.Unpaired += int64(len(pairer.Pending()))
+		if report.Unpaired > 0 {
+			log.Warn().Int64("calls", report.Unpaired).Msg("Calls without both entry and exit events, not timed")
+		}
+
+		stats := report.Stats()
+		if top := command.Int("top"); top > 0 && len(stats) > top {
+			stats = stats[:top]
+		}
+		keyHeader := "Syscall"
+		if by == "comm" {
+			keyHeader = "Comm"
+		}
+		printSyscallLatency(os.Stdout, stats, keyHeader, format)
+		return nil
+	},
+}
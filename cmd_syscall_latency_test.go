@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/simdjson-go"
+)
+
+// TestSyscallLatencyReport tests latency percentiles of paired syscalls by name and comm
+func TestSyscallLatencyReport(t *testing.T) {
+	var events []syscallRawEvent
+	// Ten reads by cat taking 1..10us, one failed openat by ls taking 50us.
+	for i := range 10 {
+		ts := uint64(i) * 100_000
+		events = append(events,
+			syscallRawEvent{Timestamp: ts, Probe: "tracepoint:syscalls:sys_enter_read", Tid: 1, Comm: "cat"},
+			syscallRawEvent{Timestamp: ts + uint64(i+1)*1000, Probe: "tracepoint:syscalls:sys_exit_read", Tid: 1, ReturnValue: 10})
+	}
+	events = append(events,
+		syscallRawEvent{Timestamp: 5_000_000, Probe: "tracepoint:raw_syscalls:sys_enter", Tid: 2, Comm: "ls", SyscallNr: 257, hasNr: true},
+		syscallRawEvent{Timestamp: 5_050_000, Probe: "tracepoint:raw_syscalls:sys_exit", Tid: 2, SyscallNr: 257, hasNr: true, ReturnValue: -2},
+		syscallRawEvent{Timestamp: 6_000_000, Probe: "tracepoint:raw_syscalls:sys_exit", Tid: 3, SyscallNr: 0, hasNr: true, ReturnValue: 1})
+
+	byName := newSyscallLatencyReport("name")
+	byComm := newSyscallLatencyReport("comm")
+	pairer := newSyscallPairer()
+	for i := range events {
+		e := &events[i]
+		e.resolve(syscallNamesX86_64)
+		if c, _ := pairer.Handle(e); c != nil {
+			byName.Add(c)
+			byComm.Add(c)
+		}
+	}
+
+	stats := byName.Stats()
+	if len(stats) != 2 || byName.Unpaired != 1 {
+		t.Fatalf("got %d groups and %d unpaired, want 2 and 1", len(stats), byName.Unpaired)
+	}
+	read := stats[0]
+	if read.Key != "read" || read.Latency.Count != 10 || read.Latency.P50 != 5*time.Microsecond ||
+		read.Latency.P90 != 9*time.Microsecond || read.Latency.Max != 10*time.Microsecond {
+		t.Errorf("read stats = %+v", read)
+	}
+	if stats[1].Key != "openat" || stats[1].Errors != 1 || stats[1].Latency.Max != 50*time.Microsecond {
+		t.Errorf("openat stats = %+v", stats[1])
+	}
+
+	comms := byComm.Stats()
+	if len(comms) != 2 || comms[0].Key != "cat" || comms[1].Key != "ls" {
+		t.Errorf("comm stats = %+v %+v", comms[0], comms[1])
+	}
+}
+
+// TestSyscallLatencyHists tests percentiles estimated from bpftrace hist maps
+func TestSyscallLatencyHists(t *testing.T) {
+	hist := `{"data": {"@us": {"read": [{"max": -1, "count": 0}, {"min": 0, "max": 1, "count": 50}, {"min": 2, "max": 3, "count": 40}, {"min": 4, "max": 7, "count": 9}, {"min": 1024, "count": 1}], "write": [{"min": 8, "max": 15, "count": 3}]}}}`
+	pj, err := simdjson.Parse([]byte(hist), nil)
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	iter.AdvanceInto()
+	var el *simdjson.Element
+	el, err = iter.FindElement(el, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hists := newSyscallLatencyHists(time.Microsecond)
+	for range 2 {
+		interval := newSyscallLatencyHists(time.Microsecond)
+		if err := interval.Fill(el); err != nil {
+			t.Fatal(err)
+		}
+		hists.Add(interval)
+	}
+
+	stats := hists.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("got %d histograms, want 2", len(stats))
+	}
+	read := stats[0].Latency
+	if stats[0].Key != "read" || read.Count != 200 || read.P50 != time.Microsecond ||
+		read.P90 != 3*time.Microsecond || read.P99 != 7*time.Microsecond || read.Max != 1024*time.Microsecond {
+		t.Errorf("read = %+v", stats[0])
+	}
+	if stats[1].Key != "write" || stats[1].Latency.Count != 6 || stats[1].Latency.Max != 15*time.Microsecond {
+		t.Errorf("write = %+v", stats[1])
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// straceWriter renders paired syscalls as strace-style lines:
+//
+//	1234 12:00:01.123456 openat(AT_FDCWD, 0x7ffd1000, O_RDONLY) = 3 <0.000012>
+type straceWriter struct {
+	w       io.Writer
+	decoder *syscallArgDecoder
+
+	// start is the wall clock of the event at firstTs. Without a bpftrace
+	// time message, timestamps are printed as seconds since the first event.
+	start   time.Time
+	firstTs uint64
+	started bool
+}
+
+func newStraceWriter(w io.Writer, decoder *syscallArgDecoder) *straceWriter {
+	return &straceWriter{w: w, decoder: decoder}
+}
+
+// SetStart anchors timestamps on the first event, once.
+func (s *straceWriter) SetStart(start time.Time, ts uint64) {
+	if s.started {
+		return
+	}
+	s.start = start
+	s.firstTs = ts
+	s.started = true
+}
+
+func (s *straceWriter) timestamp(ts uint64) string {
+	var offset time.Duration
+	if ts > s.firstTs {
+		offset = time.Duration(ts - s.firstTs)
+	}
+	if s.start.IsZero() {
+		return fmt.Sprintf("%.6f", offset.Seconds())
+	}
+	return s.start.Add(offset).Format("15:04:05.000000")
+}
+
+// Write prints one call.
+func (s *straceWriter) Write(c *syscallCall) error {
+	name := c.Name
+	if name == "" {
+		name = "syscall_" + strconv.FormatUint(c.Nr, 10)
+	}
+	args := "..."
+	if c.HasArgs {
+		args = formatSyscallArgs(s.decoder, name, c.Args)
+	}
+	line := fmt.Sprintf("%d %s %s(%s) = %s", c.Tid, s.timestamp(c.Start), name, args, formatSyscallRet(c))
+	if c.Timed() {
+		line += fmt.Sprintf(" <%.6f>", c.Duration().Seconds())
+	}
+	_, err := fmt.Fprintln(s.w, line)
+	return err
+}
+
+// formatSyscallArgs decodes known syscalls and prints the others as hex,
+// without trailing zero arguments since the arity is unknown.
+func formatSyscallArgs(decoder *syscallArgDecoder, name string, raw [6]uint64) string {
+	if decoded := decoder.Decode(name, raw); decoded != nil {
+		return decoded.String()
+	}
+	n := len(raw)
+	for n > 0 && raw[n-1] == 0 {
+		n--
+	}
+	parts := make([]string, n)
+	for i := range n {
+		parts[i] = "0x" + strconv.FormatUint(raw[i], 16)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSyscallRet prints the return value like strace: -1 and the errno name
+// for failures, addresses in hex, ? when the call never returned.
+func formatSyscallRet(c *syscallCall) string {
+	switch {
+	case !c.HasRet:
+		return "?"
+	case c.Errno != "":
+		return "-1 " + c.Errno
+	}
+	switch c.Name {
+	case "mmap", "mremap", "brk", "shmat":
+		return "0x" + strconv.FormatUint(uint64(c.Ret), 16)
+	}
+	return strconv.FormatInt(c.Ret, 10)
+}
+
+// syscallTraceWriter writes paired syscalls as Chrome trace events named after
+// the syscall, with the probe, decoded arguments and return value as args.
+type syscallTraceWriter struct {
+	trace   *traceWriter
+	decoder *syscallArgDecoder
+}
+
+func newSyscallTraceWriter(w io.Writer, decoder *syscallArgDecoder) *syscallTraceWriter {
+	return &syscallTraceWriter{trace: newTraceWriter(w), decoder: decoder}
+}
+
+// Write adds one call: a slice when both its entry and exit were seen.
+func (s *syscallTraceWriter) Write(c *syscallCall) error {
+	name := c.Name
+	if name == "" {
+		name = "syscall_" + strconv.FormatUint(c.Nr, 10)
+	}
+	args := map[string]any{"ret": formatSyscallRet(c)}
+	if c.HasArgs {
+		args["args"] = formatSyscallArgs(s.decoder, name, c.Args)
+	}
+	if c.Path != "" {
+		args["path"] = c.Path
+	}
+	if c.Probe != "" {
+		args["probe"] = c.Probe
+	}
+	end := c.Start
+	if c.Timed() {
+		end = c.End
+	}
+	return s.trace.Span(name, "syscall", c.Pid, c.Tid, c.Comm, c.Start, end, args)
+}
+
+// Close terminates the trace.
+func (s *syscallTraceWriter) Close() error {
+	return s.trace.Close()
+}
+
+var syscallPrintCmd = &cli.Command{
+	Name:  "print",
+	Usage: "Print raw syscall events as strace-style lines or a Chrome trace",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: text, chrome-trace",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "write to a file instead of stdout",
+		},
+		syscallArchFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) (err error) {
+		format := command.String("format")
+		if format != "text" && format != "chrome-trace" {
+			return fmt.Errorf("invalid format: %s (must be text or chrome-trace)", format)
+		}
+		syscalls, err := syscallTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+		decoder, err := syscallArgDecoderFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		w, closeOutput, err := createOutput(command.String("output"))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+		}()
+
+		strace := newStraceWriter(w, decoder)
+		write := strace.Write
+		var trace *syscallTraceWriter
+		if format == "chrome-trace" {
+			trace = newSyscallTraceWriter(w, decoder)
+			write = trace.Write
+		}
+
+		pairer := newSyscallPairer()
+		parser := &NDJSONParser{}
+		err = syscallParseStream(parser, r, func(e *syscallRawEvent) error {
+			e.resolve(syscalls)
+			strace.SetStart(parser.StartTime, e.Timestamp)
+			c, stale := pairer.Handle(e)
+			if stale != nil {
+				if err := write(stale); err != nil {
+					return err
+				}
+			}
+			if c != nil {
+				return write(c)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range pairer.Pending() {
+			if err := write(c); err != nil {
+				return err
+			}
+		}
+		if trace != nil {
+			return trace.Close()
+		}
+		return nil
+	},
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestSyscallProbeKind tests recognising entry and exit probes and the syscall they name
+func TestSyscallProbeKind(t *testing.T) {
+	tests := []struct {
+		probe   string
+		name    string
+		isEntry bool
+		isExit  bool
+	}{
+		{"tracepoint:raw_syscalls:sys_enter", "", true, false},
+		{"tracepoint:raw_syscalls:sys_exit", "", false, true},
+		{"tracepoint:syscalls:sys_enter_openat", "openat", true, false},
+		{"tracepoint:syscalls:sys_exit_openat", "openat", false, true},
+		{"kprobe:__x64_sys_read", "read", true, false},
+		{"kretprobe:__arm64_sys_read", "read", false, true},
+		{"", "", false, false},
+	}
+	for _, tt := range tests {
+		name, isEntry, isExit := syscallProbeKind(tt.probe)
+		if name != tt.name || isEntry != tt.isEntry || isExit != tt.isExit {
+			t.Errorf("syscallProbeKind(%q) = %q %v %v, want %q %v %v",
+				tt.probe, name, isEntry, isExit, tt.name, tt.isEntry, tt.isExit)
+		}
+	}
+}
+
+// TestStraceWriter tests pairing entry and exit events and rendering strace-style lines
+func TestStraceWriter(t *testing.T) {
+	events := []syscallRawEvent{
+		{Timestamp: 1_000_000, Probe: "tracepoint:raw_syscalls:sys_enter", Tid: 10, SyscallNr: 257, hasNr: true,
+			Arg0: ^uint64(99), Arg1: 0x7ffd1000, Arg2: 0o2000000},
+		{Timestamp: 2_000_000, Probe: "tracepoint:syscalls:sys_enter_read", Tid: 11, Arg0: 3, Arg1: 0x4000, Arg2: 512},
+		{Timestamp: 1_012_000, Probe: "tracepoint:raw_syscalls:sys_exit", Tid: 10, SyscallNr: 257, hasNr: true, ReturnValue: 3},
+		{Timestamp: 2_500_000, Probe: "tracepoint:syscalls:sys_exit_read", Tid: 11, ReturnValue: -11},
+		{Timestamp: 3_000_000, Probe: "tracepoint:syscalls:sys_exit_write", Tid: 12, ReturnValue: 5},
+		{Timestamp: 3_500_000, Tid: 13, SyscallNr: 39, hasNr: true, ReturnValue: 13},
+		{Timestamp: 4_000_000, Probe: "tracepoint:raw_syscalls:sys_enter", Tid: 10, SyscallNr: 1000, hasNr: true, Arg0: 1, Arg1: 2},
+	}
+
+	decoder, err := newSyscallArgDecoder("x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := newStraceWriter(&buf, decoder)
+	start := time.Date(0, 1, 1, 12, 0, 1, 0, time.UTC)
+	pairer := newSyscallPairer()
+	for i := range events {
+		e := &events[i]
+		e.resolve(syscallNamesX86_64)
+		out.SetStart(start, e.Timestamp)
+		if c, _ := pairer.Handle(e); c != nil {
+			if err := out.Write(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, c := range pairer.Pending() {
+		if err := out.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := "10 12:00:01.000000 openat(AT_FDCWD, 0x7ffd1000, O_RDONLY|O_CLOEXEC) = 3 <0.000012>\n" +
+		"11 12:00:01.001000 read(3, 0x4000, 512) = -1 EAGAIN <0.000500>\n" +
+		"12 12:00:01.002000 write(...) = 5\n" +
+		"13 12:00:01.002500 getpid() = 13\n" +
+		"10 12:00:01.003000 syscall_1000(0x1, 0x2) = ?\n"
+	if buf.String() != expected {
+		t.Errorf("strace output = \n%s\nwant\n%s", buf.String(), expected)
+	}
+}
+
+// TestSyscallTraceWriter tests exporting paired syscalls as Chrome trace slices and instants
+func TestSyscallTraceWriter(t *testing.T) {
+	decoder, err := newSyscallArgDecoder("x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := newSyscallTraceWriter(&buf, decoder)
+	calls := []*syscallCall{
+		{Probe: "tracepoint:syscalls:sys_enter_close", Tid: 10, Pid: 9, Comm: "cat", Name: "close", Args: [6]uint64{3}, Ret: 0,
+			Start: 1000, End: 3000, HasArgs: true, HasRet: true},
+		{Tid: 10, Pid: 9, Comm: "cat", Name: "read", Start: 5000, End: 5000, HasArgs: true},
+	}
+	for _, c := range calls {
+		if err := out.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, buf.String())
+	}
+	if len(doc.TraceEvents) != 3 {
+		t.Fatalf("expected 3 trace events, got %d", len(doc.TraceEvents))
+	}
+	if e := doc.TraceEvents[0]; e.Ph != "M" || e.Pid != 9 || e.Tid != 10 || e.Args["name"] != "cat" {
+		t.Errorf("unexpected thread name event: %+v", e)
+	}
+	if e := doc.TraceEvents[1]; e.Name != "close" || e.Ph != "X" || e.Ts != 1 || e.Dur != 2 ||
+		e.Args["args"] != "3" || e.Args["ret"] != "0" || e.Args["probe"] != "tracepoint:syscalls:sys_enter_close" {
+		t.Errorf("unexpected slice: %+v", e)
+	}
+	if e := doc.TraceEvents[2]; e.Name != "read" || e.Ph != "i" || e.Args["ret"] != "?" {
+		t.Errorf("unexpected instant: %+v", e)
+	}
+}
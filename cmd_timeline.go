@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// timelineKind describes how to read the table written by one raw command.
+// Tables are matched to a kind by their columns.
+type timelineKind struct {
+	Name    string
+	Markers []string
+	// Event is the SQL expression naming an event.
+	Event string
+	// Details are the columns printed as Column=value when the table has them
+	// and the value is not empty.
+	Details []string
+	// Duration is a column holding the duration in nanoseconds of an event
+	// that ends at Ts.
+	Duration string
+	// Return are the details set on return rows. The entry and return rows of
+	// a call, told apart by the Probe prefix, are merged into one event.
+	Return []string
+}
+
+const timelineProbeEvent = `regexp_extract(Probe, '[^:]*$')`
+
+var timelineKinds = []timelineKind{
+	{
+		Name:     "syscall",
+		Markers:  []string{"SyscallNr"},
+		Event:    `coalesce(SyscallName, 'syscall_' || SyscallNr)`,
+		Details:  []string{"ReturnValue", "Errno", "Fd", "FdTarget"},
+		Duration: "LatencyNs",
+	},
+	{
+		Name:    "vfs",
+		Markers: []string{"Inode", "Offset"},
+		Event:   timelineProbeEvent,
+		Details: []string{"FullPath", "Path", "Offset", "Length", "RC"},
+		Return:  []string{"RC"},
+	},
+	{
+		Name:    "net",
+		Markers: []string{"DstPort", "Bytes"},
+		Event:   timelineProbeEvent,
+		Details: []string{"Protocol", "SrcAddr", "SrcPort", "DstAddr", "DstPort", "Bytes"},
+	},
+	{
+		Name:    "mem",
+		Markers: []string{"Address", "Size"},
+		Event:   timelineProbeEvent,
+		Details: []string{"Address", "Size", "Type"},
+	},
+	{
+		Name:    "proc",
+		Markers: []string{"Ppid", "Cmdline"},
+		Event:   timelineProbeEvent,
+		Details: []string{"Ppid", "Cmdline", "ExitCode"},
+	},
+}
+
+// timelineColumns are required of every table merged into a timeline.
+var timelineColumns = []string{"Ts", "Tid", "Pid", "Comm"}
+
+// inferTimelineKind returns the kind of a table with the given columns, or nil.
+func inferTimelineKind(columns []string) *timelineKind {
+	for _, c := range timelineColumns {
+		if !slices.Contains(columns, c) {
+			return nil
+		}
+	}
+	for i := range timelineKinds {
+		k := &timelineKinds[i]
+		matched := true
+		for _, m := range k.Markers {
+			if !slices.Contains(columns, m) {
+				matched = false
+				break
+			}
+		}
+		if matched && (k.Event != timelineProbeEvent || slices.Contains(columns, "Probe")) {
+			return k
+		}
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a table or column name for use in a query.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// timelineQuery builds the query reading one table, filtered by the where clause.
+func timelineQuery(table string, kind *timelineKind, columns []string, where string) string {
+	from := quoteIdentifier(table)
+	duration := "NULL"
+	column := quoteIdentifier
+	var window string
+	if len(kind.Return) > 0 {
+		// A return row preceded by the entry of the same call spans the call and
+		// takes the other details from the entry, which is dropped.
+		ret := fmt.Sprintf(`regexp_extract(Probe, '^[^:]*') IN ('%s')`, strings.Join(vfsReturnPrefixes, "', '"))
+		from = fmt.Sprintf(`(SELECT *, %s AS _ret, Probe LIKE '%%:%%' AND NOT %s AS _entry FROM %s WHERE %s) t`,
+			ret, ret, from, where)
+		where = "true"
+		paired := `coalesce(_ret AND lag(_entry) OVER w, false)`
+		duration = fmt.Sprintf(`CASE WHEN %s THEN Ts - lag(Ts) OVER w END`, paired)
+		column = func(c string) string {
+			if slices.Contains(kind.Return, c) {
+				return quoteIdentifier(c)
+			}
+			return fmt.Sprintf(`CASE WHEN %s THEN lag(%s) OVER w ELSE %s END`, paired, quoteIdentifier(c), quoteIdentifier(c))
+		}
+		window = fmt.Sprintf(` WINDOW w AS (PARTITION BY Tid, %s ORDER BY Ts) QUALIFY NOT coalesce(_entry AND lead(_ret) OVER w, false)`,
+			kind.Event)
+	} else {
+		from += " t"
+	}
+
+	var details []string
+	for _, c := range kind.Details {
+		if slices.Contains(columns, c) {
+			details = append(details, fmt.Sprintf(`'%s=' || nullif(CAST(%s AS VARCHAR), '')`, c, column(c)))
+		}
+	}
+	detail := "''"
+	if len(details) > 0 {
+		detail = "concat_ws(' ', " + strings.Join(details, ", ") + ")"
+	}
+	if kind.Duration != "" && slices.Contains(columns, kind.Duration) {
+		duration = quoteIdentifier(kind.Duration)
+		// The exit row spans the whole call, so the entry row it was paired with is dropped.
+		where += fmt.Sprintf(` AND NOT EXISTS (SELECT 1 FROM %s x WHERE x.Tid = t.Tid AND x.Ts > t.Ts AND x.Ts - x.%s = t.Ts)`,
+			quoteIdentifier(table), duration)
+	}
+	return fmt.Sprintf(`SELECT Ts, Tid, Pid, Comm, %s, %s, %s FROM %s WHERE %s%s ORDER BY Ts`,
+		kind.Event, detail, duration, from, where, window)
+}
+
+// timelineEvent is one row of a merged timeline.
+type timelineEvent struct {
+	Ts         uint64 `json:"ts"`
+	RelNs      uint64 `json:"rel_ns"`
+	GapNs      uint64 `json:"gap_ns"`
+	Source     string `json:"source"`
+	Table      string `json:"table"`
+	Tid        uint64 `json:"tid"`
+	Pid        uint64 `json:"pid,omitempty"`
+	Comm       string `json:"comm,omitempty"`
+	Event      string `json:"event"`
+	Detail     string `json:"detail,omitempty"`
+	DurationNs uint64 `json:"duration_ns,omitempty"`
+}
+
+// timelineFilter selects the rows of one thread or comm.
+type timelineFilter struct {
+	Tid  uint64
+	Comm string
+}
+
+func (f timelineFilter) where() (string, []any) {
+	var clauses []string
+	var args []any
+	if f.Tid != 0 {
+		clauses = append(clauses, "Tid = ?")
+		args = append(args, f.Tid)
+	}
+	if f.Comm != "" {
+		clauses = append(clauses, "Comm = ?")
+		args = append(args, f.Comm)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// loadTimeline reads the matching rows of the given tables, or of every table
+// with a known kind when tables is empty, and merges them by Tid and Ts.
+func loadTimeline(ctx context.Context, db *sql.DB, tables []string, filter timelineFilter) ([]*timelineEvent, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT table_name, column_name FROM information_schema.columns ORDER BY table_name, ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	schema := make(map[string][]string)
+	var names []string
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if _, ok := schema[table]; !ok {
+			names = append(names, table)
+		}
+		schema[table] = append(schema[table], column)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tables) == 0 {
+		tables = names
+	}
+	where, args := filter.where()
+	events := make([]*timelineEvent, 0)
+	for _, table := range tables {
+		columns, ok := schema[table]
+		if !ok {
+			return nil, fmt.Errorf("table not found: %s", table)
+		}
+		kind := inferTimelineKind(columns)
+		if kind == nil {
+			log.Debug().Str("table", table).Msg("Not a raw event table, skipping")
+			continue
+		}
+		n := len(events)
+		events, err = appendTimelineRows(ctx, db, events, table, kind, timelineQuery(table, kind, columns, where), args)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", table, err)
+		}
+		log.Debug().Str("table", table).Str("kind", kind.Name).Int("events", len(events)-n).Msg("Table merged")
+	}
+
+	// Threads are listed one after the other, so that gaps are measured
+	// between events of the same thread.
+	var first uint64
+	for i, e := range events {
+		if i == 0 || e.Ts < first {
+			first = e.Ts
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Tid != events[j].Tid {
+			return events[i].Tid < events[j].Tid
+		}
+		return events[i].Ts < events[j].Ts
+	})
+	for i, e := range events {
+		e.RelNs = e.Ts - first
+		if i > 0 && events[i-1].Tid == e.Tid {
+			e.GapNs = e.Ts - events[i-1].Ts
+		}
+	}
+	return events, nil
+}
+
+func appendTimelineRows(ctx context.Context, db *sql.DB, events []*timelineEvent,
+	table string, kind *timelineKind, query string, args []any) ([]*timelineEvent, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var pid, duration sql.NullInt64
+		var comm, event, detail sql.NullString
+		e := &timelineEvent{Source: kind.Name, Table: table}
+		if err := rows.Scan(&e.Ts, &e.Tid, &pid, &comm, &event, &detail, &duration); err != nil {
+			return nil, err
+		}
+		e.Pid = uint64(pid.Int64)
+		e.Comm = comm.String
+		e.Event = event.String
+		e.Detail = detail.String
+		e.DurationNs = uint64(duration.Int64)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// printTimelineText prints the timeline with relative timestamps, marking gaps
+// of at least gapMark between consecutive events.
+func printTimelineText(w io.Writer, events []*timelineEvent, gapMark time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Time\tGap\tSource\tTid\tComm\tEvent\tDuration\tDetail")
+	_, _ = fmt.Fprintln(tw, "----\t---\t------\t---\t----\t-----\t--------\t------")
+	for i, e := range events {
+		if i > 0 && gapMark > 0 && time.Duration(e.GapNs) >= gapMark {
+			_, _ = fmt.Fprintf(tw, "\t%s idle\t\t\t\t\t\t\n", time.Duration(e.GapNs))
+		}
+		duration := ""
+		if e.DurationNs > 0 {
+			duration = time.Duration(e.DurationNs).String()
+		}
+		_, _ = fmt.Fprintf(tw, "+%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			time.Duration(e.RelNs), time.Duration(e.GapNs), e.Source, e.Tid, e.Comm, e.Event, duration, e.Detail)
+	}
+	_ = tw.Flush()
+}
+
+// writeTimelineTrace writes the timeline as Chrome trace events: events with a
+// duration become slices ending at Ts, the others instants.
+func writeTimelineTrace(w io.Writer, events []*timelineEvent) error {
+	tw := newTraceWriter(w)
+	for _, e := range events {
+		args := map[string]any{"table": e.Table}
+		if e.Detail != "" {
+			args["detail"] = e.Detail
+		}
+		var err error
+		if e.DurationNs > 0 && e.DurationNs <= e.Ts {
+			err = tw.Complete(e.Event, e.Source, e.Pid, e.Tid, e.Comm, e.Ts-e.DurationNs, e.DurationNs, args)
+		} else {
+			err = tw.Instant(e.Event, e.Source, e.Pid, e.Tid, e.Comm, e.Ts, args)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+var timelineCmd = &cli.Command{
+	Name:  "timeline",
+	Usage: "Merge the raw event tables of a database into one timeline per thread",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "dsn",
+			Required: true,
+			Usage:    "DuckDB connection string",
+		},
+		&cli.StringSliceFlag{
+			Name:  "table",
+			Usage: "table to merge, repeatable (default: every vfs, net, syscall, mem and proc raw table)",
+		},
+		&cli.Uint64Flag{
+			Name:  "tid",
+			Usage: "thread to show",
+		},
+		&cli.StringFlag{
+			Name:  "comm",
+			Usage: "show the threads with this comm",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: text, json, chrome-trace",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "write to a file instead of stdout",
+		},
+		&cli.DurationFlag{
+			Name:  "gap",
+			Value: time.Millisecond,
+			Usage: "mark gaps between events of at least this long in text output (0 disables)",
+		},
+	},
+	Action: func(ctx context.Context, command *cli.Command) (err error) {
+		filter := timelineFilter{Tid: command.Uint64("tid"), Comm: command.String("comm")}
+		if filter.Tid == 0 && filter.Comm == "" {
+			return fmt.Errorf("--tid or --comm is required")
+		}
+		format := command.String("format")
+		switch format {
+		case "text", "json", "chrome-trace":
+		default:
+			return fmt.Errorf("invalid format: %s (must be text, json, or chrome-trace)", format)
+		}
+
+		connector, err := duckdb.NewConnector(command.String("dsn"), nil)
+		if err != nil {
+			return err
+		}
+		db := sql.OpenDB(connector)
+		defer func() { _ = db.Close() }()
+
+		events, err := loadTimeline(ctx, db, command.StringSlice("table"), filter)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			log.Warn().Msg("No events matched")
+		}
+
+		w, closeOutput, err := createOutput(command.String("output"))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+		}()
+
+		switch format {
+		case "json":
+			data, err := json.Marshal(events)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, string(data))
+			return err
+		case "chrome-trace":
+			return writeTimelineTrace(w, events)
+		default:
+			printTimelineText(w, events, command.Duration("gap"))
+			return nil
+		}
+	},
+}
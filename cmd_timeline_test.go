@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// TestLoadTimeline tests merging a syscall and a vfs table for one thread
+func TestLoadTimeline(t *testing.T) {
+	connector, err := duckdb.NewConnector("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+
+	for _, stmt := range []string{
+		`CREATE TABLE sc (Ts UBIGINT, Probe STRING, Pid UBIGINT, Tid UBIGINT, Comm STRING,
+			SyscallNr UBIGINT, SyscallName STRING, ReturnValue BIGINT, Errno STRING, LatencyNs UBIGINT)`,
+		`INSERT INTO sc VALUES (60, 'tracepoint:syscalls:sys_enter_openat', 5, 5, 'cat', 257, 'openat', 0, NULL, NULL),
+			(100, 'tracepoint:syscalls:sys_exit_openat', 5, 5, 'cat', 257, 'openat', 3, NULL, 40),
+			(5000, '', 5, 5, 'cat', 0, 'read', -1, 'EAGAIN', NULL),
+			(200, '', 6, 6, 'sh', 0, 'read', 0, NULL, NULL),
+			(120, '', 7, 7, 'cat', 0, 'read', 1, NULL, NULL),
+			(3120, '', 7, 7, 'cat', 1, 'write', 1, NULL, NULL)`,
+		`CREATE TABLE vfs (Ts UBIGINT, Probe STRING, Tid UBIGINT, Pid UBIGINT, Comm STRING,
+			RC BIGINT, Path STRING, FullPath STRING, Inode UBIGINT, "Offset" UBIGINT, Length UBIGINT)`,
+		`INSERT INTO vfs VALUES (150, 'kfunc:vmlinux:vfs_read', 5, 5, 'cat', 0, 'hosts', '', 12, 0, 4096),
+			(180, 'kretfunc:vmlinux:vfs_read', 5, 5, 'cat', 4096, '', '', 0, 0, 0),
+			(4000, 'kfunc:vmlinux:vfs_write', 5, 5, 'cat', 0, 'log', '', 13, 8, 16)`,
+		`CREATE TABLE other (Ts UBIGINT, Tid UBIGINT)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := loadTimeline(context.Background(), db, nil, timelineFilter{Tid: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	want := []struct {
+		source, event, detail string
+		rel, gap, duration    uint64
+	}{
+		{"syscall", "openat", "ReturnValue=3", 0, 0, 40},
+		{"vfs", "vfs_read", "Path=hosts Offset=0 Length=4096 RC=4096", 80, 80, 30},
+		{"vfs", "vfs_write", "Path=log Offset=8 Length=16 RC=0", 3900, 3820, 0},
+		{"syscall", "read", "ReturnValue=-1 Errno=EAGAIN", 4900, 1000, 0},
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Source != w.source || e.Event != w.event || e.Detail != w.detail ||
+			e.RelNs != w.rel || e.GapNs != w.gap || e.DurationNs != w.duration {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+
+	// With --comm, threads are grouped and gaps measured within a thread.
+	byComm, err := loadTimeline(context.Background(), db, []string{"sc"}, timelineFilter{Comm: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tids, gaps []uint64
+	for _, e := range byComm {
+		tids = append(tids, e.Tid)
+		gaps = append(gaps, e.GapNs)
+	}
+	if !slices.Equal(tids, []uint64{5, 5, 7, 7}) || !slices.Equal(gaps, []uint64{0, 4900, 0, 3000}) {
+		t.Errorf("tids = %v, gaps = %v", tids, gaps)
+	}
+	if byComm[2].RelNs != 20 {
+		t.Errorf("rel of tid 7 = %d, want 20 since the first event", byComm[2].RelNs)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE "sc""quoted" AS SELECT * FROM sc`); err != nil {
+		t.Fatal(err)
+	}
+	quoted, err := loadTimeline(context.Background(), db, []string{`sc"quoted`}, timelineFilter{Tid: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quoted) != 2 {
+		t.Errorf("expected 2 events from the quoted table, got %d", len(quoted))
+	}
+
+	if _, err := loadTimeline(context.Background(), db, []string{"missing"}, timelineFilter{Tid: 5}); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+
+	var buf bytes.Buffer
+	if err := writeTimelineTrace(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, buf.String())
+	}
+	if len(trace.TraceEvents) != 5 {
+		t.Fatalf("expected 5 trace events, got %d", len(trace.TraceEvents))
+	}
+	if e := trace.TraceEvents[0]; e.Ph != "M" || e.Args["name"] != "cat" {
+		t.Errorf("unexpected thread name event: %+v", e)
+	}
+	if e := trace.TraceEvents[1]; e.Ph != "X" || e.Ts != 0.06 || e.Dur != 0.04 {
+		t.Errorf("unexpected slice: %+v", e)
+	}
+	if e := trace.TraceEvents[2]; e.Ph != "X" || e.Name != "vfs_read" || e.Ts != 0.15 || e.Dur != 0.03 {
+		t.Errorf("unexpected vfs slice: %+v", e)
+	}
+	if e := trace.TraceEvents[3]; e.Ph != "i" || e.Name != "vfs_write" {
+		t.Errorf("unexpected instant: %+v", e)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -21,12 +22,16 @@ var vfsCmd = &cli.Command{
 	Commands: []*cli.Command{
 		vfsCountCmd,
 		vfsRawCmd,
+		vfsFilesCmd,
+		vfsPatternCmd,
+		vfsFsyncCmd,
+		vfsPrintCmd,
 	},
 }
 
 var vfsCountCmd = &cli.Command{
 	Name: "count",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -35,13 +40,13 @@ var vfsCountCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "format",
 			Value: "table",
-			Usage: "output format: table, json, csv",
+			Usage: "output format: table, json, csv, folded, influx",
 		},
 		&cli.BoolFlag{
 			Name:  "live",
 			Usage: "live mode: print each interval as it arrives",
 		},
-	},
+	}, countFlags(), alertFlags(), otlpFlags(), statsdFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		var r io.Reader
 		input := command.String("input")
@@ -59,14 +64,32 @@ var vfsCountCmd = &cli.Command{
 		format := command.String("format")
 		live := command.Bool("live")
 
-		// Validate format
-		switch format {
-		case "table", "json", "csv":
-			// valid
-		default:
-			return fmt.Errorf("invalid format: %s (must be table, json, or csv)", format)
+		if err := ValidateCountFormat(format); err != nil {
+			return err
+		}
+
+		alerts, err := NewAlertEvaluator(command)
+		if err != nil {
+			return err
+		}
+
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		influx, err := NewInfluxOutput(command, "vfs")
+		if err != nil {
+			return err
 		}
 
+		statsd, err := NewStatsDSink(command)
+		if err != nil {
+			return err
+		}
+		defer statsd.Close()
+
 		var startTime time.Time
 		var totalEvent Event
 		var intervalCount int
@@ -138,10 +161,18 @@ var vfsCountCmd = &cli.Command{
 					intervalCount++
 					totalEvent.Add(&event)
 
-					if live {
+					if influx != nil {
+						influx.Print(startTime, intervalCount, event.Values())
+					} else if live {
 						printEvent(&event, format, intervalCount)
 					}
 
+					otlp.Counters("vfs", startTime, intervalCount, event.Values())
+					statsd.Send("vfs", event.Values())
+					if err := alerts.Evaluate(ctx, intervalCount, event.Values()); err != nil {
+						return err
+					}
+
 				default:
 					log.Warn().Str("type", typeStr).Msg("Unknown message type, skipping")
 				}
@@ -156,10 +187,14 @@ var vfsCountCmd = &cli.Command{
 		}
 
 		// Print summary
+		if influx != nil {
+			return nil
+		}
 		if !live {
 			printEvent(&totalEvent, format, intervalCount)
-		} else if intervalCount > 1 {
-			// In live mode, print total at the end if there were multiple intervals
+		} else if intervalCount > 1 && format != string(FormatFolded) {
+			// In live mode, print total at the end if there were multiple intervals.
+			// Folded stacks are summed by the flame graph tools, so the total would count twice.
 			fmt.Println("\n--- Total ---")
 			printEvent(&totalEvent, format, intervalCount)
 		}
@@ -183,6 +218,9 @@ func printEvent(e *Event, format string, intervalCount int) {
 		data, _ := json.Marshal(output)
 		fmt.Println(string(data))
 
+	case "folded":
+		NewCountOutput().PrintFolded(e.Values())
+
 	case "csv":
 		w := csv.NewWriter(os.Stdout)
 		_ = w.Write([]string{"Operation", "Count"})
@@ -195,6 +233,7 @@ func printEvent(e *Event, format string, intervalCount int) {
 		_ = w.Write([]string{"writev", fmt.Sprintf("%d", e.WriteV)})
 		_ = w.Write([]string{"fsync", fmt.Sprintf("%d", e.FSync)})
 		_ = w.Write([]string{"total", fmt.Sprintf("%d", e.Total())})
+		e.Other.writeCSV(w)
 		w.Flush()
 
 	default: // table
@@ -212,6 +251,7 @@ func printEvent(e *Event, format string, intervalCount int) {
 		_, _ = fmt.Fprintln(tw, "---------\t-----")
 		_, _ = fmt.Fprintf(tw, "Total\t%d\n", e.Total())
 		_, _ = fmt.Fprintf(tw, "Intervals\t%d\n", intervalCount)
+		e.Other.writeTable(tw)
 		_ = tw.Flush()
 	}
 }
@@ -225,6 +265,9 @@ type Event struct {
 	Write    int64 `json:"write"`
 	WriteV   int64 `json:"writev"`
 	FSync    int64 `json:"fsync"`
+
+	// Other holds map keys beyond the known operations, e.g. comm,probe,path keys.
+	Other otherCounts `json:"other,omitempty"`
 }
 
 // Add accumulates counts from another Event
@@ -237,13 +280,32 @@ func (e *Event) Add(other *Event) {
 	e.Write += other.Write
 	e.WriteV += other.WriteV
 	e.FSync += other.FSync
+	e.Other.Add(other.Other)
 }
 
-// Total returns the sum of all operation counts
+// Total returns the sum of the known operation counts; Other keys are left out.
 func (e *Event) Total() int64 {
 	return e.Create + e.Open + e.Read + e.ReadLink + e.ReadV + e.Write + e.WriteV + e.FSync
 }
 
+// Values returns the counts keyed by operation name
+func (e *Event) Values() map[string]int64 {
+	values := map[string]int64{
+		"create":   e.Create,
+		"open":     e.Open,
+		"read":     e.Read,
+		"readlink": e.ReadLink,
+		"readv":    e.ReadV,
+		"write":    e.Write,
+		"writev":   e.WriteV,
+		"fsync":    e.FSync,
+	}
+	for k, v := range e.Other {
+		values[k] = v
+	}
+	return values
+}
+
 func (e *Event) Fill(el *simdjson.Element) error {
 	var err error
 	var rootEl *simdjson.Element
@@ -285,7 +347,7 @@ func (e *Event) Fill(el *simdjson.Element) error {
 		case "vfs_fsync":
 			e.FSync = value
 		default:
-			log.Debug().Str("field", m.Name).Int64("value", value).Msg("Unknown field in map data")
+			e.Other.Set(m.Name, value)
 		}
 	}
 	return nil
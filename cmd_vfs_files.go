@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+)
+
+// vfsFileStats is the I/O profile of a single file.
+type vfsFileStats struct {
+	Path       string   `json:"path"`
+	Inode      uint64   `json:"inode,omitempty"`
+	Opens      int64    `json:"opens"`
+	Reads      int64    `json:"reads"`
+	Writes     int64    `json:"writes"`
+	Fsyncs     int64    `json:"fsyncs"`
+	ReadReq    uint64   `json:"read_bytes_requested"`
+	ReadRet    uint64   `json:"read_bytes_returned"`
+	WriteReq   uint64   `json:"write_bytes_requested"`
+	WriteRet   uint64   `json:"write_bytes_returned"`
+	MinOffset  uint64   `json:"min_offset"`
+	MaxOffset  uint64   `json:"max_offset"`
+	Errors     int64    `json:"errors"`
+	RWRatio    *float64 `json:"read_write_ratio"`
+	hasOffsets bool
+}
+
+// Bytes returns the total bytes returned by reads and writes.
+func (s *vfsFileStats) Bytes() uint64 {
+	return s.ReadRet + s.WriteRet
+}
+
+func (s *vfsFileStats) touch(offset, length uint64) {
+	end := offset + length
+	if !s.hasOffsets || offset < s.MinOffset {
+		s.MinOffset = offset
+	}
+	if !s.hasOffsets || end > s.MaxOffset {
+		s.MaxOffset = end
+	}
+	s.hasOffsets = true
+}
+
+// vfsFileReport accumulates per-file statistics from paired vfs calls.
+// Files are keyed by name, so calls of threads that did not resolve the inode
+// count towards the same file; the inode is filled in once any call knows it.
+type vfsFileReport struct {
+	files map[string]*vfsFileStats
+}
+
+func newVfsFileReport() *vfsFileReport {
+	return &vfsFileReport{files: make(map[string]*vfsFileStats)}
+}
+
+func (r *vfsFileReport) file(c *vfsCall) *vfsFileStats {
+	s, ok := r.files[c.Name()]
+	if !ok {
+		s = &vfsFileStats{Path: c.Name()}
+		r.files[c.Name()] = s
+	}
+	if s.Inode == 0 {
+		s.Inode = c.Inode
+	}
+	return s
+}
+
+// Add accounts a paired call to its file. Calls without a path are ignored.
+func (r *vfsFileReport) Add(c *vfsCall) {
+	if c.Path == "" {
+		return
+	}
+	s := r.file(c)
+	if c.RC < 0 {
+		s.Errors++
+	}
+	var ret uint64
+	if c.RC > 0 {
+		ret = uint64(c.RC)
+	}
+	switch c.Op {
+	case "vfs_open", "vfs_create":
+		s.Opens++
+	case "vfs_read", "vfs_readv":
+		s.Reads++
+		s.ReadReq += c.Length
+		s.ReadRet += ret
+		if ret > 0 {
+			s.touch(c.Offset, ret)
+		}
+	case "vfs_write", "vfs_writev":
+		s.Writes++
+		s.WriteReq += c.Length
+		s.WriteRet += ret
+		if ret > 0 {
+			s.touch(c.Offset, ret)
+		}
+	case "vfs_fsync", "vfs_fsync_range":
+		s.Fsyncs++
+	}
+}
+
+// vfsFileSortKeys maps --sort values to descending comparisons.
+var vfsFileSortKeys = map[string]func(a, b *vfsFileStats) bool{
+	"bytes":       func(a, b *vfsFileStats) bool { return a.Bytes() > b.Bytes() },
+	"reads":       func(a, b *vfsFileStats) bool { return a.Reads > b.Reads },
+	"writes":      func(a, b *vfsFileStats) bool { return a.Writes > b.Writes },
+	"read_bytes":  func(a, b *vfsFileStats) bool { return a.ReadRet > b.ReadRet },
+	"write_bytes": func(a, b *vfsFileStats) bool { return a.WriteRet > b.WriteRet },
+	"fsyncs":      func(a, b *vfsFileStats) bool { return a.Fsyncs > b.Fsyncs },
+	"errors":      func(a, b *vfsFileStats) bool { return a.Errors > b.Errors },
+	"ratio": func(a, b *vfsFileStats) bool {
+		if a.RWRatio == nil || b.RWRatio == nil {
+			return a.RWRatio != nil
+		}
+		return *a.RWRatio > *b.RWRatio
+	},
+	"path": func(a, b *vfsFileStats) bool { return a.Path < b.Path },
+}
+
+// Files returns all files sorted by the given key.
+func (r *vfsFileReport) Files(sortBy string) ([]*vfsFileStats, error) {
+	less, ok := vfsFileSortKeys[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort column: %s", sortBy)
+	}
+	files := make([]*vfsFileStats, 0, len(r.files))
+	for _, s := range r.files {
+		files = append(files, s)
+	}
+	for _, s := range files {
+		if s.WriteRet > 0 {
+			ratio := float64(s.ReadRet) / float64(s.WriteRet)
+			s.RWRatio = &ratio
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if less(files[i], files[j]) != less(files[j], files[i]) {
+			return less(files[i], files[j])
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+func formatRatio(ratio *float64) string {
+	if ratio == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*ratio, 'f', 2, 64)
+}
+
+func printVfsFiles(w io.Writer, files []*vfsFileStats, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(files)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Path", "Inode", "Opens", "Reads", "Writes", "Fsyncs",
+			"ReadReq", "ReadRet", "WriteReq", "WriteRet", "MinOffset", "MaxOffset", "Errors", "RWRatio"})
+		for _, s := range files {
+			_ = cw.Write([]string{s.Path, strconv.FormatUint(s.Inode, 10),
+				strconv.FormatInt(s.Opens, 10), strconv.FormatInt(s.Reads, 10),
+				strconv.FormatInt(s.Writes, 10), strconv.FormatInt(s.Fsyncs, 10),
+				strconv.FormatUint(s.ReadReq, 10), strconv.FormatUint(s.ReadRet, 10),
+				strconv.FormatUint(s.WriteReq, 10), strconv.FormatUint(s.WriteRet, 10),
+				strconv.FormatUint(s.MinOffset, 10), strconv.FormatUint(s.MaxOffset, 10),
+				strconv.FormatInt(s.Errors, 10), formatRatio(s.RWRatio)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Path\tInode\tReads\tWrites\tFsyncs\tRead req/ret\tWrite req/ret\tOffsets\tR/W")
+		_, _ = fmt.Fprintln(tw, "----\t-----\t-----\t------\t------\t------------\t-------------\t-------\t---")
+		for _, s := range files {
+			ratio := formatRatio(s.RWRatio)
+			if ratio == "" {
+				ratio = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d/%d\t%d/%d\t%d-%d\t%s\n",
+				s.Path, s.Inode, s.Reads, s.Writes, s.Fsyncs,
+				s.ReadReq, s.ReadRet, s.WriteReq, s.WriteRet,
+				s.MinOffset, s.MaxOffset, ratio)
+		}
+		_ = tw.Flush()
+	}
+}
+
+var vfsFilesCmd = &cli.Command{
+	Name:  "files",
+	Usage: "Report the I/O profile of each file from raw VFS events",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Value: "bytes",
+			Usage: "sort column: bytes, reads, writes, read_bytes, write_bytes, fsyncs, errors, ratio, path",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "only print the first N files (0 prints all)",
+		},
+		inodePathsFlag(),
+		inodeByNameFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+
+		sortBy := command.String("sort")
+		if _, ok := vfsFileSortKeys[sortBy]; !ok {
+			return fmt.Errorf("invalid sort column: %s", sortBy)
+		}
+
+		inodes, err := newInodeResolverFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		report := newVfsFileReport()
+		err = vfsCallsFromStream(r, inodes, nil, func(c *vfsCall) error {
+			report.Add(c)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		files, err := report.Files(sortBy)
+		if err != nil {
+			return err
+		}
+		if top := command.Int("top"); top > 0 && len(files) > top {
+			files = files[:top]
+		}
+		printVfsFiles(os.Stdout, files, format)
+		return nil
+	},
+}
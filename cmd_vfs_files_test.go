@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const vfsPairedTestData = `{"type": "attached_probes", "data": {"probes": 8}}
+{"type": "printf", "data": "ts=100 fn=kfunc:vmlinux:vfs_open tid=1 inode=7 path='data.log'"}
+{"type": "printf", "data": "ts=110 fn=kretfunc:vmlinux:vfs_open tid=1 rc=0"}
+{"type": "printf", "data": "ts=200 fn=kfunc:vmlinux:vfs_write tid=1 path='data.log' offset=0 len=4096"}
+{"type": "printf", "data": "ts=250 fn=kretfunc:vmlinux:vfs_write tid=1 rc=4096"}
+{"type": "printf", "data": "ts=300 fn=kfunc:vmlinux:vfs_read tid=1 path='data.log' offset=8192 len=4096"}
+{"type": "printf", "data": "ts=320 fn=kretfunc:vmlinux:vfs_read tid=1 rc=1024"}
+{"type": "printf", "data": "ts=400 fn=kfunc:vmlinux:vfs_fsync tid=1 path='data.log'"}
+{"type": "printf", "data": "ts=900 fn=kretfunc:vmlinux:vfs_fsync tid=1 rc=0"}
+{"type": "printf", "data": "ts=950 fn=kfunc:vmlinux:vfs_read tid=2 path='other' offset=0 len=10"}
+{"type": "printf", "data": "ts=960 fn=kretfunc:vmlinux:vfs_read tid=2 rc=-5"}
+`
+
+// TestVfsProbeOp tests splitting probe names into operation and direction
+func TestVfsProbeOp(t *testing.T) {
+	tests := []struct {
+		probe    string
+		op       string
+		isEntry  bool
+		isReturn bool
+	}{
+		{"kfunc:vmlinux:vfs_read", "vfs_read", true, false},
+		{"kretfunc:vmlinux:vfs_read", "vfs_read", false, true},
+		{"fexit:vfs_fsync", "vfs_fsync", false, true},
+		{"vfs_write", "vfs_write", false, false},
+	}
+	for _, tt := range tests {
+		op, isEntry, isReturn := vfsProbeOp(tt.probe)
+		if op != tt.op || isEntry != tt.isEntry || isReturn != tt.isReturn {
+			t.Errorf("vfsProbeOp(%q) = %q, %v, %v", tt.probe, op, isEntry, isReturn)
+		}
+	}
+}
+
+// TestVfsCallsFromStream tests pairing entry and return events
+func TestVfsCallsFromStream(t *testing.T) {
+	var calls []vfsCall
+	err := vfsCallsFromStream(strings.NewReader(vfsPairedTestData), newInodeResolver(), nil, func(c *vfsCall) error {
+		calls = append(calls, *c)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 5 {
+		t.Fatalf("expected 5 paired calls, got %d", len(calls))
+	}
+	write := calls[1]
+	if write.Op != "vfs_write" || write.Path != "data.log" || write.RC != 4096 || write.Duration() != 50 {
+		t.Errorf("unexpected write call: %+v", write)
+	}
+	if write.Inode != 7 {
+		t.Errorf("write inode = %d, want 7 learned from vfs_open", write.Inode)
+	}
+}
+
+// TestVfsFileReport tests the per-file I/O profile
+func TestVfsFileReport(t *testing.T) {
+	report := newVfsFileReport()
+	err := vfsCallsFromStream(strings.NewReader(vfsPairedTestData), newInodeResolver(), nil, func(c *vfsCall) error {
+		report.Add(c)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := report.Files("bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+
+	f := files[0]
+	if f.Path != "data.log" || f.Inode != 7 {
+		t.Fatalf("unexpected first file: %+v", f)
+	}
+	if f.Opens != 1 || f.Reads != 1 || f.Writes != 1 || f.Fsyncs != 1 {
+		t.Errorf("unexpected op counts: %+v", f)
+	}
+	if f.ReadReq != 4096 || f.ReadRet != 1024 || f.WriteReq != 4096 || f.WriteRet != 4096 {
+		t.Errorf("unexpected byte counts: %+v", f)
+	}
+	if f.MinOffset != 0 || f.MaxOffset != 9216 {
+		t.Errorf("offset range = %d-%d, want 0-9216", f.MinOffset, f.MaxOffset)
+	}
+	if f.RWRatio == nil || *f.RWRatio != 0.25 {
+		t.Errorf("read/write ratio = %v, want 0.25", f.RWRatio)
+	}
+	if files[1].Errors != 1 || files[1].RWRatio != nil || files[1].hasOffsets {
+		t.Errorf("unexpected second file: %+v", files[1])
+	}
+
+	if _, err := report.Files("nope"); err == nil {
+		t.Error("expected error for invalid sort column")
+	}
+
+	// A thread that never saw the open still writes to the same file.
+	report.Add(&vfsCall{Op: "vfs_write", Tid: 3, Path: "data.log", Length: 100, RC: 100})
+	files, _ = report.Files("bytes")
+	if len(files) != 2 || files[0].Writes != 2 || files[0].Inode != 7 {
+		t.Errorf("write without an inode should join data.log: %+v", files[0])
+	}
+}
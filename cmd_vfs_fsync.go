@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// vfsFsyncStats is the durability cost of a single file.
+type vfsFsyncStats struct {
+	Path    string          `json:"path"`
+	Inode   uint64          `json:"inode,omitempty"`
+	Fsyncs  int64           `json:"fsyncs"`
+	Failed  int64           `json:"failed"`
+	Slow    int64           `json:"slow"`
+	Empty   int64           `json:"empty"` // fsyncs with no bytes written since the previous one
+	Latency durationSummary `json:"latency"`
+	Lag     durationSummary `json:"dirty_lag"` // first dirty write to the end of the covering fsync
+
+	WrittenTotal uint64  `json:"written_bytes"`
+	WrittenAvg   float64 `json:"written_bytes_avg"` // bytes written between fsyncs
+	WrittenMax   uint64  `json:"written_bytes_max"`
+
+	latencies  []time.Duration
+	lags       []time.Duration
+	dirty      uint64
+	dirtySince uint64
+}
+
+// vfsFsyncReport pairs fsyncs with the writes they make durable.
+// Files are keyed by name, so writes and an fsync issued from another thread,
+// which may not have resolved the inode, are matched; the inode is filled in
+// once any call knows it.
+type vfsFsyncReport struct {
+	slow  time.Duration
+	files map[string]*vfsFsyncStats
+}
+
+func newVfsFsyncReport(slow time.Duration) *vfsFsyncReport {
+	return &vfsFsyncReport{slow: slow, files: make(map[string]*vfsFsyncStats)}
+}
+
+func (r *vfsFsyncReport) file(c *vfsCall) *vfsFsyncStats {
+	s, ok := r.files[c.Name()]
+	if !ok {
+		s = &vfsFsyncStats{Path: c.Name()}
+		r.files[c.Name()] = s
+	}
+	if s.Inode == 0 {
+		s.Inode = c.Inode
+	}
+	return s
+}
+
+// vfsFsyncResult describes a completed fsync.
+type vfsFsyncResult struct {
+	File  *vfsFsyncStats
+	Dirty uint64        // bytes written since the previous successful fsync
+	Lag   time.Duration // zero when nothing was dirty
+	Slow  bool
+}
+
+// Add accounts a paired call. Successful writes mark their file dirty and a successful
+// fsync makes every dirty byte of the file durable; failed fsyncs leave the file dirty.
+// It returns a result for fsync calls and nil for everything else.
+func (r *vfsFsyncReport) Add(c *vfsCall) *vfsFsyncResult {
+	if c.Path == "" {
+		return nil
+	}
+	switch c.Op {
+	case "vfs_write", "vfs_writev":
+		if c.RC <= 0 {
+			return nil
+		}
+		s := r.file(c)
+		if s.dirty == 0 {
+			s.dirtySince = c.Start
+		}
+		s.dirty += uint64(c.RC)
+		return nil
+
+	case "vfs_fsync", "vfs_fsync_range":
+		if c.End == 0 {
+			// Still running at the end of the input, so it made nothing durable yet.
+			return nil
+		}
+		s := r.file(c)
+		res := &vfsFsyncResult{File: s, Dirty: s.dirty}
+		latency := c.Duration()
+		s.Fsyncs++
+		s.latencies = append(s.latencies, latency)
+		if r.slow > 0 && latency >= r.slow {
+			s.Slow++
+			res.Slow = true
+		}
+		if c.RC < 0 {
+			s.Failed++
+			return res
+		}
+		if s.dirty == 0 {
+			s.Empty++
+			return res
+		}
+		if c.End > s.dirtySince {
+			res.Lag = time.Duration(c.End - s.dirtySince)
+		}
+		s.lags = append(s.lags, res.Lag)
+		s.WrittenTotal += s.dirty
+		s.WrittenMax = max(s.WrittenMax, s.dirty)
+		s.dirty = 0
+		return res
+	}
+	return nil
+}
+
+// Files returns files that were fsynced, by total fsync time descending.
+func (r *vfsFsyncReport) Files() []*vfsFsyncStats {
+	files := make([]*vfsFsyncStats, 0, len(r.files))
+	for _, s := range r.files {
+		files = append(files, s)
+	}
+	n := 0
+	for _, s := range files {
+		if s.Fsyncs == 0 {
+			continue
+		}
+		s.Latency = summarizeDurations(s.latencies)
+		s.Lag = summarizeDurations(s.lags)
+		if flushed := s.Fsyncs - s.Failed - s.Empty; flushed > 0 {
+			s.WrittenAvg = float64(s.WrittenTotal) / float64(flushed)
+		}
+		files[n] = s
+		n++
+	}
+	files = files[:n]
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Latency.Total != files[j].Latency.Total {
+			return files[i].Latency.Total > files[j].Latency.Total
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+func printVfsFsync(w io.Writer, files []*vfsFsyncStats, format string) {
+	switch format {
+	case "json":
+		data, _ := json.Marshal(files)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Path", "Inode", "Fsyncs", "Failed", "Slow", "Empty",
+			"P50Ns", "P90Ns", "P99Ns", "MaxNs", "TotalNs",
+			"WrittenBytes", "WrittenAvg", "WrittenMax", "LagP50Ns", "LagP99Ns", "LagMaxNs"})
+		for _, s := range files {
+			_ = cw.Write([]string{s.Path, strconv.FormatUint(s.Inode, 10),
+				strconv.FormatInt(s.Fsyncs, 10), strconv.FormatInt(s.Failed, 10),
+				strconv.FormatInt(s.Slow, 10), strconv.FormatInt(s.Empty, 10),
+				strconv.FormatInt(int64(s.Latency.P50), 10), strconv.FormatInt(int64(s.Latency.P90), 10),
+				strconv.FormatInt(int64(s.Latency.P99), 10), strconv.FormatInt(int64(s.Latency.Max), 10),
+				strconv.FormatInt(int64(s.Latency.Total), 10),
+				strconv.FormatUint(s.WrittenTotal, 10), strconv.FormatFloat(s.WrittenAvg, 'f', 1, 64),
+				strconv.FormatUint(s.WrittenMax, 10),
+				strconv.FormatInt(int64(s.Lag.P50), 10), strconv.FormatInt(int64(s.Lag.P99), 10),
+				strconv.FormatInt(int64(s.Lag.Max), 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Path\tFsyncs\tFailed\tSlow\tp50\tp90\tp99\tMax\tAvg written\tMax written\tLag p50\tLag max")
+		_, _ = fmt.Fprintln(tw, "----\t------\t------\t----\t---\t---\t---\t---\t-----------\t-----------\t-------\t-------")
+		for _, s := range files {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%.0f\t%d\t%s\t%s\n",
+				s.Path, s.Fsyncs, s.Failed, s.Slow,
+				s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.Max,
+				s.WrittenAvg, s.WrittenMax, s.Lag.P50, s.Lag.Max)
+		}
+		_ = tw.Flush()
+	}
+}
+
+var vfsFsyncCmd = &cli.Command{
+	Name:  "fsync",
+	Usage: "Report fsync latency, bytes written between fsyncs and write-to-fsync lag per file",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.DurationFlag{
+			Name:  "slow",
+			Value: 10 * time.Millisecond,
+			Usage: "log and count fsyncs slower than this (0 disables)",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "only print the first N files (0 prints all)",
+		},
+		inodePathsFlag(),
+		inodeByNameFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+
+		inodes, err := newInodeResolverFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		report := newVfsFsyncReport(command.Duration("slow"))
+		err = vfsCallsFromStream(r, inodes, nil, func(c *vfsCall) error {
+			res := report.Add(c)
+			if res != nil && res.Slow {
+				log.Warn().Str("path", c.Name()).Uint64("tid", c.Tid).Str("comm", c.Comm).
+					Dur("latency", c.Duration()).Uint64("dirty_bytes", res.Dirty).
+					Dur("dirty_lag", res.Lag).Int64("rc", c.RC).Msg("Slow fsync")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		files := report.Files()
+		if top := command.Int("top"); top > 0 && len(files) > top {
+			files = files[:top]
+		}
+		printVfsFsync(os.Stdout, files, format)
+		return nil
+	},
+}
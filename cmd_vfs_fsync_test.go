@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPercentile tests nearest-rank percentiles
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i))
+	}
+	s := summarizeDurations(samples)
+	if s.P50 != 50 || s.P90 != 90 || s.P99 != 99 || s.Max != 100 || s.Avg() != 50 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+	if percentile(nil, 50) != 0 {
+		t.Error("expected zero percentile of no samples")
+	}
+}
+
+const vfsFsyncTestData = `{"type": "printf", "data": "ts=100 fn=kfunc:vmlinux:vfs_write tid=1 path='wal' offset=0 len=100"}
+{"type": "printf", "data": "ts=110 fn=kretfunc:vmlinux:vfs_write tid=1 rc=100"}
+{"type": "printf", "data": "ts=200 fn=kfunc:vmlinux:vfs_write tid=2 path='wal' offset=100 len=50"}
+{"type": "printf", "data": "ts=210 fn=kretfunc:vmlinux:vfs_write tid=2 rc=50"}
+{"type": "printf", "data": "ts=300 fn=kfunc:vmlinux:vfs_fsync tid=1 path='wal'"}
+{"type": "printf", "data": "ts=20000300 fn=kretfunc:vmlinux:vfs_fsync tid=1 rc=0"}
+{"type": "printf", "data": "ts=20000400 fn=kfunc:vmlinux:vfs_fsync tid=1 path='wal'"}
+{"type": "printf", "data": "ts=20000500 fn=kretfunc:vmlinux:vfs_fsync tid=1 rc=0"}
+{"type": "printf", "data": "ts=20000600 fn=kfunc:vmlinux:vfs_write tid=1 path='wal' offset=150 len=10"}
+{"type": "printf", "data": "ts=20000610 fn=kretfunc:vmlinux:vfs_write tid=1 rc=10"}
+{"type": "printf", "data": "ts=20000700 fn=kfunc:vmlinux:vfs_fsync tid=1 path='wal'"}
+{"type": "printf", "data": "ts=20000800 fn=kretfunc:vmlinux:vfs_fsync tid=1 rc=-5"}
+{"type": "printf", "data": "ts=20000900 fn=kfunc:vmlinux:vfs_write tid=1 path='other' offset=0 len=10"}
+{"type": "printf", "data": "ts=20000910 fn=kretfunc:vmlinux:vfs_write tid=1 rc=10"}
+`
+
+// TestVfsFsyncReport tests fsync latency, dirty bytes and lag accounting
+func TestVfsFsyncReport(t *testing.T) {
+	report := newVfsFsyncReport(10 * time.Millisecond)
+	var slow int
+	err := vfsCallsFromStream(strings.NewReader(vfsFsyncTestData), newInodeResolver(), nil, func(c *vfsCall) error {
+		if res := report.Add(c); res != nil && res.Slow {
+			slow++
+			if res.Dirty != 150 || res.Lag != 20000200 {
+				t.Errorf("slow fsync dirty = %d lag = %s, want 150 and 20.0002ms", res.Dirty, res.Lag)
+			}
+		}
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slow != 1 {
+		t.Errorf("expected 1 slow fsync, got %d", slow)
+	}
+
+	files := report.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected only the fsynced file, got %d", len(files))
+	}
+	f := files[0]
+	if f.Fsyncs != 3 || f.Failed != 1 || f.Empty != 1 || f.Slow != 1 {
+		t.Errorf("unexpected fsync counts: %+v", f)
+	}
+	if f.WrittenTotal != 150 || f.WrittenMax != 150 || f.WrittenAvg != 150 {
+		t.Errorf("unexpected written bytes: %+v", f)
+	}
+	if f.Latency.Max != 20*time.Millisecond || f.Latency.P50 != 100 {
+		t.Errorf("unexpected latency summary: %+v", f.Latency)
+	}
+	if f.Lag.Count != 1 {
+		t.Errorf("expected 1 lag sample, got %d", f.Lag.Count)
+	}
+}
+
+// TestVfsFsyncReportOtherThread tests an fsync thread flushing writes whose
+// writer resolved the inode
+func TestVfsFsyncReportOtherThread(t *testing.T) {
+	report := newVfsFsyncReport(0)
+	report.Add(&vfsCall{Op: "vfs_write", Tid: 1, Path: "db", Inode: 9, RC: 100, Start: 100, End: 110})
+	res := report.Add(&vfsCall{Op: "vfs_fsync", Tid: 3, Path: "db", Start: 200, End: 300})
+	if res == nil || res.Dirty != 100 || res.Lag != 200 {
+		t.Fatalf("fsync from another thread = %+v, want 100 dirty bytes and 200ns lag", res)
+	}
+	if files := report.Files(); len(files) != 1 || files[0].Inode != 9 {
+		t.Errorf("unexpected files: %+v", files)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+)
+
+// seekBuckets is the number of log2 buckets in a seek-distance histogram.
+// Bucket i counts distances in [2^(i-1), 2^i), bucket 0 counts distances below 1.
+const seekBuckets = 48
+
+// Patterns assigned to a stream of accesses.
+const (
+	patternSingle     = "single"
+	patternSequential = "sequential"
+	patternStrided    = "strided"
+	patternRandom     = "random"
+	patternMixed      = "mixed"
+)
+
+// patternThreshold is the share of accesses needed to label a stream sequential or strided.
+const patternThreshold = 0.8
+
+type vfsStreamKey struct {
+	file  string
+	inode uint64
+	tid   uint64
+	dir   string
+}
+
+// vfsStream tracks consecutive reads or writes of one thread to one file.
+type vfsStream struct {
+	Path       string  `json:"path"`
+	Inode      uint64  `json:"inode,omitempty"`
+	Tid        uint64  `json:"tid"`
+	Dir        string  `json:"dir"`
+	Accesses   int64   `json:"accesses"`
+	Bytes      uint64  `json:"bytes"`
+	Sequential int64   `json:"sequential"`
+	Strided    int64   `json:"strided"`
+	Random     int64   `json:"random"`
+	Runs       int64   `json:"runs"` // sequential runs of at least two accesses
+	RunTotal   int64   `json:"run_total"`
+	MaxRun     int64   `json:"max_run"`
+	Pattern    string  `json:"pattern"`
+	AvgSize    float64 `json:"avg_size"`
+	AvgRun     float64 `json:"avg_run"`
+
+	SeekHistogram [seekBuckets]int64 `json:"-"`
+
+	prevEnd int64
+	prevGap int64
+	hasGap  bool
+	run     int64
+}
+
+func (s *vfsStream) endRun() {
+	if s.run == 0 {
+		return
+	}
+	s.Runs++
+	s.RunTotal += s.run
+	if s.run > s.MaxRun {
+		s.MaxRun = s.run
+	}
+	s.run = 0
+}
+
+// add records an access at offset that moved the file position by advance bytes.
+func (s *vfsStream) add(offset, size, advance uint64) {
+	s.Accesses++
+	s.Bytes += size
+	if s.Accesses > 1 {
+		gap := int64(offset) - s.prevEnd
+		switch {
+		case gap == 0:
+			s.Sequential++
+			if s.run == 0 {
+				s.run = 2
+			} else {
+				s.run++
+			}
+			s.hasGap = false
+		case s.hasGap && gap == s.prevGap:
+			s.Strided++
+			s.endRun()
+		default:
+			s.Random++
+			s.endRun()
+		}
+		if gap != 0 {
+			s.prevGap = gap
+			s.hasGap = true
+			distance := gap
+			if distance < 0 {
+				distance = -distance
+			}
+			s.SeekHistogram[min(bits.Len64(uint64(distance)), seekBuckets-1)]++
+		}
+	}
+	s.prevEnd = int64(offset + advance)
+}
+
+// finish closes the current run and computes the derived fields.
+func (s *vfsStream) finish() {
+	s.endRun()
+	s.AvgSize = float64(s.Bytes) / float64(s.Accesses)
+	if s.Runs > 0 {
+		s.AvgRun = float64(s.RunTotal) / float64(s.Runs)
+	}
+	transitions := float64(s.Accesses - 1)
+	switch {
+	case s.Accesses < 2:
+		s.Pattern = patternSingle
+	case float64(s.Sequential)/transitions >= patternThreshold:
+		s.Pattern = patternSequential
+	case float64(s.Strided)/transitions >= patternThreshold:
+		s.Pattern = patternStrided
+	case float64(s.Random)/transitions >= patternThreshold:
+		s.Pattern = patternRandom
+	default:
+		s.Pattern = patternMixed
+	}
+}
+
+// vfsPatternReport classifies read and write streams per file and thread.
+type vfsPatternReport struct {
+	streams map[vfsStreamKey]*vfsStream
+}
+
+func newVfsPatternReport() *vfsPatternReport {
+	return &vfsPatternReport{streams: make(map[vfsStreamKey]*vfsStream)}
+}
+
+// Add accounts a paired read or write call. Other calls are ignored.
+func (r *vfsPatternReport) Add(c *vfsCall) {
+	var dir string
+	switch c.Op {
+	case "vfs_read", "vfs_readv":
+		dir = "read"
+	case "vfs_write", "vfs_writev":
+		dir = "write"
+	default:
+		return
+	}
+	if c.Path == "" || c.RC < 0 {
+		return
+	}
+	key := vfsStreamKey{inode: c.Inode, tid: c.Tid, dir: dir}
+	if c.Inode == 0 {
+		key.file = c.Name()
+	}
+	s, ok := r.streams[key]
+	if !ok {
+		s = &vfsStream{Path: c.Name(), Inode: c.Inode, Tid: c.Tid, Dir: dir}
+		r.streams[key] = s
+	}
+
+	// vfs_readv and vfs_writev do not record a length, fall back to the return value
+	size := c.Length
+	if size == 0 && c.RC > 0 {
+		size = uint64(c.RC)
+	}
+	advance := size
+	if c.RC > 0 {
+		advance = uint64(c.RC)
+	}
+	s.add(c.Offset, size, advance)
+}
+
+// Finish closes the open runs of all streams and classifies them. Call it once
+// the input is exhausted, before Streams.
+func (r *vfsPatternReport) Finish() {
+	for _, s := range r.streams {
+		s.finish()
+	}
+}
+
+// Streams returns streams with at least minAccesses accesses, busiest first.
+func (r *vfsPatternReport) Streams(minAccesses int64) []*vfsStream {
+	streams := make([]*vfsStream, 0, len(r.streams))
+	for _, s := range r.streams {
+		if s.Accesses < minAccesses {
+			continue
+		}
+		streams = append(streams, s)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		if streams[i].Accesses != streams[j].Accesses {
+			return streams[i].Accesses > streams[j].Accesses
+		}
+		if streams[i].Path != streams[j].Path {
+			return streams[i].Path < streams[j].Path
+		}
+		return streams[i].Tid < streams[j].Tid
+	})
+	return streams
+}
+
+// seekBucketLabel returns the lower bound of a histogram bucket in human-readable bytes.
+func seekBucketLabel(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	v := uint64(1) << (i - 1)
+	units := []string{"", "K", "M", "G", "T"}
+	u := 0
+	for v >= 1024 && u < len(units)-1 {
+		v /= 1024
+		u++
+	}
+	return strconv.FormatUint(v, 10) + units[u]
+}
+
+type seekBucket struct {
+	From  string `json:"from"`
+	Count int64  `json:"count"`
+}
+
+func seekHistogram(h *[seekBuckets]int64) []seekBucket {
+	var buckets []seekBucket
+	for i, n := range h {
+		if n > 0 {
+			buckets = append(buckets, seekBucket{From: seekBucketLabel(i), Count: n})
+		}
+	}
+	return buckets
+}
+
+func printSeekHistogram(w io.Writer, h *[seekBuckets]int64) {
+	var peak int64
+	for _, n := range h {
+		peak = max(peak, n)
+	}
+	if peak == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "\nSeek distance histogram:")
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for i, n := range h {
+		if n == 0 {
+			continue
+		}
+		bar := strings.Repeat("@", int(n*40/peak))
+		_, _ = fmt.Fprintf(tw, "[%s,\t%s)\t%d\t|%s\n", seekBucketLabel(i), seekBucketLabel(i+1), n, bar)
+	}
+	_ = tw.Flush()
+}
+
+func printVfsPattern(w io.Writer, streams []*vfsStream, format string) {
+	switch format {
+	case "json":
+		type jsonStream struct {
+			*vfsStream
+			SeekHistogram []seekBucket `json:"seek_histogram"`
+		}
+		out := make([]jsonStream, 0, len(streams))
+		for _, s := range streams {
+			out = append(out, jsonStream{vfsStream: s, SeekHistogram: seekHistogram(&s.SeekHistogram)})
+		}
+		data, _ := json.Marshal(out)
+		_, _ = fmt.Fprintln(w, string(data))
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"Path", "Inode", "Tid", "Dir", "Pattern", "Accesses", "AvgSize",
+			"Sequential", "Strided", "Random", "Runs", "AvgRun", "MaxRun"})
+		for _, s := range streams {
+			_ = cw.Write([]string{s.Path, strconv.FormatUint(s.Inode, 10), strconv.FormatUint(s.Tid, 10),
+				s.Dir, s.Pattern, strconv.FormatInt(s.Accesses, 10),
+				strconv.FormatFloat(s.AvgSize, 'f', 1, 64),
+				strconv.FormatInt(s.Sequential, 10), strconv.FormatInt(s.Strided, 10),
+				strconv.FormatInt(s.Random, 10), strconv.FormatInt(s.Runs, 10),
+				strconv.FormatFloat(s.AvgRun, 'f', 1, 64), strconv.FormatInt(s.MaxRun, 10)})
+		}
+		cw.Flush()
+
+	default: // table
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Path\tTid\tDir\tPattern\tAccesses\tAvg size\tSeq\tStrided\tRandom\tAvg run\tMax run")
+		_, _ = fmt.Fprintln(tw, "----\t---\t---\t-------\t--------\t--------\t---\t-------\t------\t-------\t-------")
+		var total [seekBuckets]int64
+		for _, s := range streams {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%.0f\t%d\t%d\t%d\t%.1f\t%d\n",
+				s.Path, s.Tid, s.Dir, s.Pattern, s.Accesses, s.AvgSize,
+				s.Sequential, s.Strided, s.Random, s.AvgRun, s.MaxRun)
+			for i, n := range s.SeekHistogram {
+				total[i] += n
+			}
+		}
+		_ = tw.Flush()
+		printSeekHistogram(w, &total)
+	}
+}
+
+var vfsPatternCmd = &cli.Command{
+	Name:  "pattern",
+	Usage: "Classify sequential, strided and random access per file and thread",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format: table, json, csv",
+		},
+		&cli.IntFlag{
+			Name:  "min-accesses",
+			Value: 2,
+			Usage: "skip streams with fewer accesses",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "only print the N busiest streams (0 prints all)",
+		},
+		inodePathsFlag(),
+		inodeByNameFlag(),
+	},
+	Action: func(ctx context.Context, command *cli.Command) error {
+		format := command.String("format")
+		if err := ValidateFormat(format); err != nil {
+			return err
+		}
+
+		inodes, err := newInodeResolverFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		report := newVfsPatternReport()
+		err = vfsCallsFromStream(r, inodes, nil, func(c *vfsCall) error {
+			report.Add(c)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		report.Finish()
+		streams := report.Streams(int64(command.Int("min-accesses")))
+		if top := command.Int("top"); top > 0 && len(streams) > top {
+			streams = streams[:top]
+		}
+		printVfsPattern(os.Stdout, streams, format)
+		return nil
+	},
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+// TestVfsPatternClassification tests labelling of sequential, strided and random streams
+func TestVfsPatternClassification(t *testing.T) {
+	report := newVfsPatternReport()
+	add := func(tid uint64, offset uint64) {
+		report.Add(&vfsCall{Op: "vfs_read", Tid: tid, Path: "f", Offset: offset, Length: 4096, RC: 4096})
+	}
+	for i := uint64(0); i < 10; i++ {
+		add(1, i*4096)
+		add(2, i*3*4096)
+	}
+	for _, off := range []uint64{0, 1 << 20, 4096, 1 << 30, 8192, 77} {
+		add(3, off)
+	}
+	report.Add(&vfsCall{Op: "vfs_fsync", Tid: 1, Path: "f"})
+
+	report.Finish()
+	streams := report.Streams(2)
+	if len(streams) != 3 {
+		t.Fatalf("expected 3 streams, got %d", len(streams))
+	}
+	want := map[uint64]string{1: patternSequential, 2: patternStrided, 3: patternRandom}
+	for _, s := range streams {
+		if s.Pattern != want[s.Tid] {
+			t.Errorf("tid %d pattern = %s, want %s", s.Tid, s.Pattern, want[s.Tid])
+		}
+		if s.AvgSize != 4096 {
+			t.Errorf("tid %d avg size = %f, want 4096", s.Tid, s.AvgSize)
+		}
+	}
+
+	seq := streams[0]
+	if seq.Tid != 1 || seq.Runs != 1 || seq.MaxRun != 10 {
+		t.Errorf("sequential stream runs = %d max = %d, want 1 run of 10", seq.Runs, seq.MaxRun)
+	}
+	if seq.SeekHistogram != [seekBuckets]int64{} {
+		t.Error("sequential stream should have no seeks")
+	}
+}
+
+// TestSeekBucketLabel tests histogram bucket labels
+func TestSeekBucketLabel(t *testing.T) {
+	tests := map[int]string{0: "0", 1: "1", 11: "1K", 13: "4K", 21: "1M"}
+	for i, want := range tests {
+		if got := seekBucketLabel(i); got != want {
+			t.Errorf("seekBucketLabel(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
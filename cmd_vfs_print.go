@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// vfsLineWriter renders paired vfs calls as strace-style lines, with
+// timestamps in seconds since the first call:
+//
+//	1234 0.000120 vfs_read("/etc/hosts", offset=0, len=4096) = 100 <0.000010>
+type vfsLineWriter struct {
+	w       io.Writer
+	firstTs uint64
+	started bool
+}
+
+// Write prints one call.
+func (v *vfsLineWriter) Write(c *vfsCall) error {
+	if !v.started {
+		v.firstTs = c.Start
+		v.started = true
+	}
+	var offset time.Duration
+	if c.Start > v.firstTs {
+		offset = time.Duration(c.Start - v.firstTs)
+	}
+	line := fmt.Sprintf("%d %.6f %s(%s, offset=%d, len=%d) = %d", c.Tid, offset.Seconds(),
+		c.Op, strconv.Quote(c.Name()), c.Offset, c.Length, c.RC)
+	if c.End > c.Start {
+		line += fmt.Sprintf(" <%.6f>", c.Duration().Seconds())
+	}
+	_, err := fmt.Fprintln(v.w, line)
+	return err
+}
+
+// writeVfsTrace adds one call to a Chrome trace, named after the operation
+// with the probe, file and request as args. Calls that did not return are instants.
+func writeVfsTrace(t *traceWriter, c *vfsCall) error {
+	args := map[string]any{"offset": c.Offset, "len": c.Length, "rc": c.RC}
+	if c.Probe != "" {
+		args["probe"] = c.Probe
+	}
+	if name := c.Name(); name != "" {
+		args["path"] = name
+	}
+	if c.Inode != 0 {
+		args["inode"] = c.Inode
+	}
+	return t.Span(c.Op, "vfs", c.Pid, c.Tid, c.Comm, c.Start, c.End, args)
+}
+
+var vfsPrintCmd = &cli.Command{
+	Name:  "print",
+	Usage: "Print paired vfs calls as strace-style lines or a Chrome trace",
+	Flags: slices.Concat([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "input",
+			Aliases: []string{"i"},
+			Value:   "-",
+			Usage:   "input file (- for stdin)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format: text, chrome-trace",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "write to a file instead of stdout",
+		},
+		inodePathsFlag(),
+		inodeByNameFlag(),
+	}, taskFlags()),
+	Action: func(ctx context.Context, command *cli.Command) (err error) {
+		format := command.String("format")
+		if format != "text" && format != "chrome-trace" {
+			return fmt.Errorf("invalid format: %s (must be text or chrome-trace)", format)
+		}
+
+		inodes, err := newInodeResolverFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := newTaskTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader
+		input := command.String("input")
+		if input == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("open input: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		w, closeOutput, err := createOutput(command.String("output"))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := closeOutput(); err == nil {
+				err = cerr
+			}
+		}()
+
+		if format == "chrome-trace" {
+			trace := newTraceWriter(w)
+			err = vfsCallsFromStream(r, inodes, tasks, func(c *vfsCall) error {
+				return writeVfsTrace(trace, c)
+			})
+			if err != nil {
+				return err
+			}
+			return trace.Close()
+		}
+
+		lines := &vfsLineWriter{w: w}
+		return vfsCallsFromStream(r, inodes, tasks, lines.Write)
+	},
+}
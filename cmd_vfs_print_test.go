@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestVfsPrint tests rendering paired vfs calls as lines and as a Chrome trace
+func TestVfsPrint(t *testing.T) {
+	var lines, trace bytes.Buffer
+	out := &vfsLineWriter{w: &lines}
+	tw := newTraceWriter(&trace)
+	err := vfsCallsFromStream(strings.NewReader(vfsPairedTestData), newInodeResolver(), nil, func(c *vfsCall) error {
+		if err := out.Write(c); err != nil {
+			return err
+		}
+		return writeVfsTrace(tw, c)
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Split(strings.TrimSpace(lines.String()), "\n")
+	if len(got) != 5 {
+		t.Fatalf("expected 5 lines, got %q", got)
+	}
+	if want := `1 0.000000 vfs_open("data.log", offset=0, len=0) = 0 <0.000000>`; got[0] != want {
+		t.Errorf("line 0 = %q, want %q", got[0], want)
+	}
+	if want := `2 0.000001 vfs_read("other", offset=0, len=10) = -5 <0.000000>`; got[4] != want {
+		t.Errorf("line 4 = %q, want %q", got[4], want)
+	}
+
+	var doc struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(trace.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, trace.String())
+	}
+	if len(doc.TraceEvents) != 5 {
+		t.Fatalf("expected 5 trace events, got %d", len(doc.TraceEvents))
+	}
+	write := doc.TraceEvents[1]
+	if write.Name != "vfs_write" || write.Ph != "X" || write.Ts != 0.2 || write.Dur != 0.05 ||
+		write.Tid != 1 || write.Args["path"] != "data.log" || write.Args["len"] != float64(4096) ||
+		write.Args["probe"] != "kfunc:vmlinux:vfs_write" {
+		t.Errorf("unexpected write event: %+v", write)
+	}
+}
+
+// TestVfsPrintPending tests that calls are attributed to their process and that
+// calls without a return are written last as instants
+func TestVfsPrintPending(t *testing.T) {
+	testData := `{"type": "attached_probes", "data": {"probes": 8}}
+{"type": "printf", "data": "ts=1 fn=sched_process_exec pid=42 ppid=1 tid=42 comm='postgres' cmdline=\"postgres -D /data\""}
+{"type": "printf", "data": "ts=100 fn=kfunc:vmlinux:vfs_fsync tid=42 path='wal'"}
+{"type": "printf", "data": "ts=200 fn=kfunc:vmlinux:vfs_read tid=42 path='wal' offset=0 len=10"}
+{"type": "printf", "data": "ts=210 fn=kretfunc:vmlinux:vfs_read tid=42 rc=10"}
+`
+	var trace bytes.Buffer
+	tw := newTraceWriter(&trace)
+	err := vfsCallsFromStream(strings.NewReader(testData), newInodeResolver(), newTaskTable(), func(c *vfsCall) error {
+		return writeVfsTrace(tw, c)
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(trace.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, trace.String())
+	}
+	if len(doc.TraceEvents) != 3 {
+		t.Fatalf("expected 3 trace events, got %d", len(doc.TraceEvents))
+	}
+	if e := doc.TraceEvents[0]; e.Ph != "M" || e.Pid != 42 || e.Args["name"] != "postgres" {
+		t.Errorf("unexpected thread name event: %+v", e)
+	}
+	if e := doc.TraceEvents[1]; e.Name != "vfs_read" || e.Ph != "X" || e.Pid != 42 {
+		t.Errorf("unexpected read event: %+v", e)
+	}
+	if e := doc.TraceEvents[2]; e.Name != "vfs_fsync" || e.Ph != "i" || e.Ts != 0.1 || e.Pid != 42 {
+		t.Errorf("unexpected pending fsync event: %+v", e)
+	}
+}
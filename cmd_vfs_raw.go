@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,8 +24,11 @@ const createTableSql = `CREATE TABLE IF NOT EXISTS %s (
 	Ts UBIGINT,
 	Probe STRING,
 	Tid UBIGINT,
+	Pid UBIGINT,
+	Comm STRING,
 	RC  BIGINT,
 	Path STRING,
+	FullPath STRING,
 	Inode UBIGINT,
 	"Offset" UBIGINT,
 	Length UBIGINT)`
@@ -35,8 +39,11 @@ type vfsEvent struct {
 	Timestamp   uint64
 	Probe       string
 	Tid         uint64
+	Pid         uint64
+	Comm        string
 	ReturnValue int64
 	Path        string
+	FullPath    string
 	Inode       uint64
 	Offset      uint64
 	Length      uint64
@@ -60,6 +67,10 @@ func (e *vfsEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 		e.Probe = v
 	case "tid":
 		e.Tid, err = strconv.ParseUint(v, 10, 64)
+	case "pid":
+		e.Pid, err = strconv.ParseUint(v, 10, 64)
+	case "comm":
+		e.Comm = strings.Trim(v, "'\"")
 	case "rc":
 		e.ReturnValue, err = strconv.ParseInt(v, 10, 64)
 	case "path":
@@ -78,6 +89,26 @@ func (e *vfsEvent) HandleLogfmt(key []byte, val []byte) (err error) {
 
 type appendRowFn = func(e *vfsEvent) error
 
+// appendVfsRow appends a vfsEvent in the column order of createTableSql.
+func appendVfsRow(appender *duckdb.Appender, e *vfsEvent) error {
+	return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.Pid, e.Comm, e.ReturnValue,
+		e.Path, e.FullPath, e.Inode, e.Offset, e.Length)
+}
+
+// enrich fills in the pid and comm of the event's thread from the task table.
+func (e *vfsEvent) enrich(tasks *taskTable) {
+	info, ok := tasks.LookupAt(e.Tid, e.Timestamp)
+	if !ok {
+		return
+	}
+	if e.Pid == 0 {
+		e.Pid = info.Pid
+	}
+	if e.Comm == "" {
+		e.Comm = info.Comm
+	}
+}
+
 // Ad-hoc parse for the best performance
 func simpleParseThenAppend(r io.Reader, appendRow appendRowFn) error {
 	const attachedProbeKeyword = `{"type": "attached_probes", "data": {"probes": `
@@ -138,120 +169,65 @@ func simpleParseThenAppend(r io.Reader, appendRow appendRowFn) error {
 	return nil
 }
 
-func jsonParseThenAppend(r io.Reader, appendRow appendRowFn) error {
-	var startTime time.Time
-
-	reuse := make(chan *simdjson.ParsedJson, 10)
-	res := make(chan simdjson.Stream, 10)
-	simdjson.ParseNDStream(r, res, reuse)
-
-	for got := range res {
-		err := got.Error
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
+func jsonParseThenAppend(r io.Reader, appendRow appendRowFn, hooks ...printfHook) error {
+	return vfsParseStream(&NDJSONParser{}, r, appendRow, hooks...)
+}
 
-		err = got.Value.ForEach(func(iter simdjson.Iter) error {
-			var typeEl, dataEl *simdjson.Element
-			typeEl, err = iter.FindElement(typeEl, "type")
+// vfsParseStream parses vfs NDJSON with a parser owned by the caller, which
+// can read the start time from it while appending rows.
+func vfsParseStream(parser *NDJSONParser, r io.Reader, appendRow appendRowFn, hooks ...printfHook) error {
+	return parser.ParseStream(r, func(msgType string, data *simdjson.Element) error {
+		switch msgType {
+		case "printf":
+			buf, err := data.Iter.StringBytes()
 			if err != nil {
-				return fmt.Errorf("failed to find 'type' element: %w", err)
+				return fmt.Errorf("failed to get 'printf' data as string: %w", err)
 			}
-			var typeStr string
-			typeStr, err = typeEl.Iter.String()
-			if err != nil {
-				return fmt.Errorf("failed to get 'type' as string: %w", err)
+			handled, err := runPrintfHooks(hooks, buf)
+			if err != nil || handled {
+				return err
 			}
-			dataEl, err = iter.FindElement(dataEl, "data")
+			e := vfsEventPool.Get().(*vfsEvent)
+			*e = vfsEvent{}
+			err = logfmt.Unmarshal(buf, e)
 			if err != nil {
-				return fmt.Errorf("failed to find 'data' element: %w", err)
-			}
-
-			switch typeStr {
-			case "attached_probes":
-				var probesEl *simdjson.Element
-				probesEl, err = dataEl.Iter.FindElement(probesEl, "probes")
-				if err != nil {
-					return fmt.Errorf("failed to find 'probes' element: %w", err)
-				}
-				var probes int64
-				probes, err = probesEl.Iter.Int()
-				if err != nil {
-					return fmt.Errorf("failed to get 'probes' as int: %w", err)
-				}
-				if probes <= 0 {
-					return fmt.Errorf("probes not attached")
-				}
-
-			case "time":
-				var timeStr string
-				if !startTime.IsZero() {
-					log.Warn().Msg("Received multiple 'time' messages, ignoring")
-					return nil
-				}
-				timeStr, err = dataEl.Iter.String()
-				if err != nil {
-					return fmt.Errorf("failed to get 'time' data as string: %w", err)
-				}
-				timeStr = strings.TrimSpace(timeStr)
-				startTime, err = time.Parse(time.TimeOnly, timeStr)
-				if err != nil {
-					return fmt.Errorf("failed to parse time: %w", err)
-				}
-				log.Info().Str("start_time", startTime.Format(time.TimeOnly)).Msg("Record start from")
-
-			case "lost_events":
-				var eventCountEl *simdjson.Element
-				eventCountEl, err = dataEl.Iter.FindElement(eventCountEl, "events")
-				if err != nil {
-					return fmt.Errorf("failed to find 'events' element: %w", err)
-				}
-				var lostEvents int64
-				lostEvents, err = eventCountEl.Iter.Int()
-				if err != nil {
-					return fmt.Errorf("failed to get 'events' as int: %w", err)
-				}
-				log.Info().Int64("lost_events", lostEvents).Msg("Lost events")
-
-			case "printf":
-				var buf []byte
-				buf, err = dataEl.Iter.StringBytes()
-				if err != nil {
-					return fmt.Errorf("failed to get 'printf' data as string: %w", err)
-				}
-				e := vfsEventPool.Get().(*vfsEvent)
-				*e = vfsEvent{}
-				err = logfmt.Unmarshal(buf, e)
-				if err != nil {
-					vfsEventPool.Put(e)
-					return fmt.Errorf("failed to unmarshal logfmt data: %w", err)
-				}
-				err = appendRow(e)
 				vfsEventPool.Put(e)
-				if err != nil {
-					return fmt.Errorf("failed to append row: %w", err)
-				}
-
-			default:
-				log.Warn().Str("type", typeStr).Msg("Unknown message type, skipping")
+				return fmt.Errorf("failed to unmarshal logfmt data: %w", err)
 			}
-
-			return nil
-		})
-		if err != nil {
-			return err
+			err = appendRow(e)
+			vfsEventPool.Put(e)
+			if err != nil {
+				return fmt.Errorf("failed to append row: %w", err)
+			}
+		default:
+			log.Warn().Str("type", msgType).Msg("Unknown message type, skipping")
 		}
-	}
+		return nil
+	})
+}
 
-	return nil
+// exportVfsSpan sends a paired call as a span named after the operation.
+// Negative return values mark the span as an error.
+func exportVfsSpan(otlp *OTLPExporter, c *vfsCall) {
+	var failure string
+	if c.RC < 0 {
+		failure = "rc=" + strconv.FormatInt(c.RC, 10)
+	}
+	otlp.Span(c.Op, c.Tid, c.Start, c.End, map[string]any{
+		"thread.id":       c.Tid,
+		"process.pid":     c.Pid,
+		"process.command": c.Comm,
+		"file.path":       c.Name(),
+		"file.inode":      c.Inode,
+		"vfs.offset":      c.Offset,
+		"vfs.length":      c.Length,
+		"vfs.rc":          c.RC,
+	}, failure)
 }
 
 var vfsRawCmd = &cli.Command{
 	Name: "raw",
-	Flags: []cli.Flag{
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:    "input",
 			Aliases: []string{"i"},
@@ -265,11 +241,23 @@ var vfsRawCmd = &cli.Command{
 			Name:     "table",
 			Required: true,
 		},
-	},
+		inodePathsFlag(),
+		inodeByNameFlag(),
+	}, taskFlags(), otlpFlags()),
 	Action: func(ctx context.Context, command *cli.Command) error {
 		dsn := command.String("dsn")
 		tableName := command.String("table")
 
+		tasks, err := newTaskTableFromFlags(command)
+		if err != nil {
+			return err
+		}
+
+		inodes, err := newInodeResolverFromFlags(command)
+		if err != nil {
+			return err
+		}
+
 		connector, err := duckdb.NewConnector(dsn, nil)
 		if err != nil {
 			return err
@@ -312,9 +300,35 @@ var vfsRawCmd = &cli.Command{
 			r = f
 		}
 
-		return jsonParseThenAppend(r, func(e *vfsEvent) error {
-			return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.ReturnValue,
-				e.Path, e.Inode, e.Offset, e.Length)
-		})
+		otlp, err := NewOTLPExporter(command)
+		if err != nil {
+			return err
+		}
+		defer otlp.Close()
+
+		pairer := newVfsPairer()
+		parser := &NDJSONParser{}
+		err = vfsParseStream(parser, r, func(e *vfsEvent) error {
+			e.enrich(tasks)
+			inodes.Resolve(e)
+			if otlp != nil {
+				otlp.SetStart(parser.StartTime, e.Timestamp)
+				if c := pairer.Handle(e); c != nil {
+					exportVfsSpan(otlp, c)
+				}
+			}
+			return appendVfsRow(appender, e)
+		}, tasks.Intercept)
+		if err != nil {
+			return err
+		}
+
+		// Calls still waiting for their return are exported without an end.
+		if otlp != nil {
+			for _, c := range pairer.Pending() {
+				exportVfsSpan(otlp, c)
+			}
+		}
+		return nil
 	},
 }
@@ -296,8 +296,7 @@ func BenchmarkReadMemoryWriteDuckDB(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		r := bytes.NewReader(buffer)
 		err = jsonParseThenAppend(r, func(e *vfsEvent) error {
-			return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.ReturnValue,
-				e.Path, e.Inode, e.Offset, e.Length)
+			return appendVfsRow(appender, e)
 		})
 		if err != nil {
 			b.Fatal(err)
@@ -362,8 +361,7 @@ func BenchmarkImportFromBpf(b *testing.B) {
 		}
 
 		err = jsonParseThenAppend(r, func(e *vfsEvent) error {
-			return appender.AppendRow(e.Timestamp, e.Probe, e.Tid, e.ReturnValue,
-				e.Path, e.Inode, e.Offset, e.Length)
+			return appendVfsRow(appender, e)
 		})
 		if err != nil {
 			b.Fatal(err)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 const testDataFile = "testdata/vfs-raw.ndjson"
@@ -154,6 +155,22 @@ func TestJsonParseValidData(t *testing.T) {
 	}
 }
 
+// TestVfsParseStreamStartTime tests that the start time is known while rows are appended
+func TestVfsParseStreamStartTime(t *testing.T) {
+	parser := &NDJSONParser{}
+	var start time.Time
+	err := vfsParseStream(parser, strings.NewReader(vfsRawTestDataValid), func(e *vfsEvent) error {
+		start = parser.StartTime
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.Format(time.TimeOnly) != "12:34:56" {
+		t.Errorf("start time = %v", start)
+	}
+}
+
 // TestJsonParseUnknownType tests jsonParseThenAppend with unknown message type
 func TestJsonParseUnknownType(t *testing.T) {
 	// Unknown type should be logged but not cause error
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -51,7 +52,7 @@ func TestEventAdd(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.initial.Add(&tt.toAdd)
-			if tt.initial != tt.expected {
+			if !reflect.DeepEqual(tt.initial, tt.expected) {
 				t.Errorf("Event.Add() = %+v, want %+v", tt.initial, tt.expected)
 			}
 		})
@@ -128,7 +129,7 @@ func TestEventFill(t *testing.T) {
 		ReadV: 3, Write: 15, WriteV: 7, FSync: 2,
 	}
 
-	if event != expected {
+	if !reflect.DeepEqual(event, expected) {
 		t.Errorf("Event.Fill() = %+v, want %+v", event, expected)
 	}
 }
@@ -344,3 +345,93 @@ func TestPrintEventCSV(t *testing.T) {
 		}
 	}
 }
+
+// TestPrintEventFolded tests the printEvent function with folded format
+func TestPrintEventFolded(t *testing.T) {
+	event := Event{Open: 20, Read: 30, FSync: 2}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	printEvent(&event, "folded", 1)
+
+	_ = w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	output := buf.String()
+
+	expected := "fsync 2\nopen 20\nread 30\n"
+	if output != expected {
+		t.Errorf("printEvent(folded) = %q, want %q", output, expected)
+	}
+}
+
+// TestEventFillCompositeKeys tests that comm,probe,path keys are kept and folded
+func TestEventFillCompositeKeys(t *testing.T) {
+	pj, err := simdjson.Parse([]byte(`{"data": {"@": {"postgres,vfs_read,base": 7, "sh,vfs_open,etc": 1}}}`), nil)
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := pj.Iter()
+	iter.AdvanceInto()
+	var dataEl *simdjson.Element
+	if dataEl, err = iter.FindElement(dataEl, "data"); err != nil {
+		t.Fatal(err)
+	}
+
+	var event Event
+	if err := event.Fill(dataEl); err != nil {
+		t.Fatal(err)
+	}
+	var total Event
+	total.Add(&event)
+	total.Add(&event)
+	if total.Other["postgres,vfs_read,base"] != 14 || total.Total() != 0 {
+		t.Errorf("unexpected other keys: %+v", total)
+	}
+
+	var buf bytes.Buffer
+	NewCountOutputWriter(&buf).PrintFolded(event.Values())
+	expected := "postgres;vfs_read;base 7\nsh;vfs_open;etc 1\n"
+	if buf.String() != expected {
+		t.Errorf("PrintFolded = %q, want %q", buf.String(), expected)
+	}
+}
+
+// TestPrintFoldedCompositeKeys tests folding composite bpftrace map keys
+func TestPrintFoldedCompositeKeys(t *testing.T) {
+	var buf bytes.Buffer
+	NewCountOutputWriter(&buf).PrintFolded(map[string]int64{
+		"postgres,vfs_read,base;1": 7,
+		"postgres, vfs_write, wal": 3,
+		"idle":                     0,
+	})
+	expected := "postgres;vfs_read;base:1 7\npostgres;vfs_write;wal 3\n"
+	if buf.String() != expected {
+		t.Errorf("PrintFolded = %q, want %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	NewCountOutputWriter(&buf).PrintFolded(map[string]int64{
+		"postgres,/data/a,b.csv": 2,
+		"postgres,/data/c.csv":   1,
+	})
+	expected = "postgres;/data/a,b.csv 2\npostgres;/data/c.csv 1\n"
+	if buf.String() != expected {
+		t.Errorf("PrintFolded = %q, want %q", buf.String(), expected)
+	}
+	if err := ValidateCountFormat("folded"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateFormat("folded"); err == nil {
+		t.Error("expected folded to be rejected by ValidateFormat")
+	}
+}
@@ -0,0 +1,97 @@
+//go:build ignore
+
+// gen_syscall_table generates syscall_table.go from the syscall numbers and
+// errno names in golang.org/x/sys/unix.
+//
+//	go run gen_syscall_table.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	sysnumRe = regexp.MustCompile(`^\s*SYS_(?P<name>\w+)\s*=\s*(?P<nr>\d+)`)
+	errnoRe  = regexp.MustCompile(`^\s*\{(?P<nr>\d+), "(?P<name>E\w+)", "`)
+)
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "golang.org/x/sys").Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir := filepath.Join(strings.TrimSpace(string(out)), "unix")
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_syscall_table.go; DO NOT EDIT.\n\npackage main\n")
+	for _, arch := range []struct{ goarch, name string }{{"amd64", "X86_64"}, {"arm64", "Arm64"}} {
+		m := scan(filepath.Join(dir, "zsysnum_linux_"+arch.goarch+".go"), sysnumRe, true)
+		fmt.Fprintf(&buf, "\nvar syscallNames%s = syscallTable{\n", arch.name)
+		writeMap(&buf, m)
+	}
+	buf.WriteString("\nvar errnoNames = map[uint64]string{\n")
+	writeMap(&buf, scan(filepath.Join(dir, "zerrors_linux_amd64.go"), errnoRe, false))
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile("syscall_table.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func scan(path string, re *regexp.Regexp, lower bool) map[uint64]string {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	m := make(map[uint64]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		match := re.FindStringSubmatch(s.Text())
+		if match == nil {
+			continue
+		}
+		nr, err := strconv.ParseUint(match[re.SubexpIndex("nr")], 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := match[re.SubexpIndex("name")]
+		if lower {
+			name = strings.ToLower(name)
+		}
+		if _, ok := m[nr]; !ok {
+			m[nr] = name
+		}
+	}
+	if err = s.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return m
+}
+
+func writeMap(buf *bytes.Buffer, m map[uint64]string) {
+	keys := make([]uint64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		fmt.Fprintf(buf, "\t%d: %q,\n", k, m[k])
+	}
+	buf.WriteString("}\n")
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// heatmapBuckets is the number of log2 value buckets on the y axis.
+// Bucket i counts values in [2^(i-1), 2^i), bucket 0 counts zero.
+const heatmapBuckets = 65
+
+// heatmapMaxColumns bounds the memory of a heatmap when a timestamp lies far
+// after the first one.
+const heatmapMaxColumns = 100000
+
+// heatmap counts samples per time bin and log2 value bucket.
+type heatmap struct {
+	Title string
+	Unit  string // "ns" or "bytes"
+	Bin   time.Duration
+
+	// Dropped counts samples beyond heatmapMaxColumns.
+	Dropped int64
+
+	start   uint64
+	started bool
+	cols    [][heatmapBuckets]int64
+}
+
+func newHeatmap(title, unit string, bin time.Duration) *heatmap {
+	return &heatmap{Title: title, Unit: unit, Bin: bin}
+}
+
+// Add counts a value observed at ts (nanoseconds). The first sample sets time zero;
+// samples before it land in the first column, samples after the last column are dropped.
+func (h *heatmap) Add(ts, value uint64) {
+	if !h.started {
+		h.start = ts
+		h.started = true
+	}
+	var col uint64
+	if ts > h.start {
+		col = (ts - h.start) / uint64(h.Bin)
+	}
+	if col >= heatmapMaxColumns {
+		h.Dropped++
+		return
+	}
+	for uint64(len(h.cols)) <= col {
+		h.cols = append(h.cols, [heatmapBuckets]int64{})
+	}
+	h.cols[col][bits.Len64(value)]++
+}
+
+// Columns returns the number of time bins.
+func (h *heatmap) Columns() int {
+	return len(h.cols)
+}
+
+// bucketRange returns the lowest and highest non-empty buckets.
+func (h *heatmap) bucketRange() (lo, hi int, ok bool) {
+	lo, hi = heatmapBuckets, -1
+	for _, col := range h.cols {
+		for i, n := range col {
+			if n > 0 {
+				lo = min(lo, i)
+				hi = max(hi, i)
+			}
+		}
+	}
+	return lo, hi, hi >= 0
+}
+
+// merged returns columns downsampled by summing groups of n adjacent bins.
+func (h *heatmap) merged(n int) [][heatmapBuckets]int64 {
+	if n <= 1 {
+		return h.cols
+	}
+	out := make([][heatmapBuckets]int64, (len(h.cols)+n-1)/n)
+	for i, col := range h.cols {
+		for b, v := range col {
+			out[i/n][b] += v
+		}
+	}
+	return out
+}
+
+func peakCount(cols [][heatmapBuckets]int64) int64 {
+	var peak int64
+	for _, col := range cols {
+		for _, n := range col {
+			peak = max(peak, n)
+		}
+	}
+	return peak
+}
+
+// bucketLabel returns the lower bound of a bucket in the heatmap unit.
+func (h *heatmap) bucketLabel(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	v := uint64(1) << (i - 1)
+	if h.Unit == "ns" {
+		return time.Duration(v).String()
+	}
+	return seekBucketLabel(i)
+}
+
+var (
+	heatmapCold = pterm.NewRGB(30, 30, 110)
+	heatmapHot  = pterm.NewRGB(255, 230, 60)
+)
+
+// heatColor returns the color of a cell holding n samples, scaled logarithmically to peak.
+func heatColor(n, peak int64) pterm.RGB {
+	if peak <= 1 {
+		return heatmapHot
+	}
+	x := float32(bits.Len64(uint64(n))) / float32(bits.Len64(uint64(peak)))
+	return heatmapCold.Fade(0, 1, x, pterm.NewRGB(200, 40, 40), heatmapHot)
+}
+
+// RenderTerminal draws the heatmap with one character per cell, merging time bins
+// so that it fits into width columns.
+func (h *heatmap) RenderTerminal(w io.Writer, width int) {
+	lo, hi, ok := h.bucketRange()
+	if !ok {
+		_, _ = fmt.Fprintln(w, "No samples")
+		return
+	}
+	labelWidth := 0
+	for i := lo; i <= hi; i++ {
+		labelWidth = max(labelWidth, len(h.bucketLabel(i)))
+	}
+	perCol := 1
+	if avail := width - labelWidth - 3; avail > 0 && len(h.cols) > avail {
+		perCol = (len(h.cols) + avail - 1) / avail
+	}
+	cols := h.merged(perCol)
+	peak := peakCount(cols)
+
+	_, _ = fmt.Fprintf(w, "%s (%s per column, peak %d)\n", h.Title, h.Bin*time.Duration(perCol), peak)
+	for i := hi; i >= lo; i-- {
+		var sb strings.Builder
+		for _, col := range cols {
+			if col[i] == 0 {
+				sb.WriteByte(' ')
+				continue
+			}
+			sb.WriteString(heatColor(col[i], peak).Sprint("█"))
+		}
+		_, _ = fmt.Fprintf(w, "%*s |%s\n", labelWidth, h.bucketLabel(i), sb.String())
+	}
+	_, _ = fmt.Fprintf(w, "%*s +%s\n", labelWidth, "", strings.Repeat("-", len(cols)))
+}
+
+const (
+	svgCell   = 10
+	svgMargin = 80
+)
+
+// WriteSVG renders the heatmap as a standalone SVG document.
+func (h *heatmap) WriteSVG(w io.Writer) error {
+	lo, hi, ok := h.bucketRange()
+	if !ok {
+		lo, hi = 0, 0
+	}
+	rows := hi - lo + 1
+	width := svgMargin + len(h.cols)*svgCell + 20
+	height := rows*svgCell + 70
+	peak := peakCount(h.cols)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="10">`+"\n", width, height)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(&sb, `<text x="%d" y="16" font-size="13">%s</text>`+"\n", svgMargin, html.EscapeString(h.Title))
+	top := 30
+	for i := hi; i >= lo; i-- {
+		y := top + (hi-i)*svgCell
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgMargin-4, y+svgCell-1, html.EscapeString(h.bucketLabel(i)))
+		for c, col := range h.cols {
+			n := col[i]
+			if n == 0 {
+				continue
+			}
+			r, g, b := heatColor(n, peak).GetValues()
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="rgb(%d,%d,%d)"><title>%s, %s: %d</title></rect>`+"\n",
+				svgMargin+c*svgCell, y, svgCell, svgCell, r, g, b,
+				time.Duration(c)*h.Bin, html.EscapeString(h.bucketLabel(i)), n)
+		}
+	}
+	axis := top + rows*svgCell + 14
+	fmt.Fprintf(&sb, `<text x="%d" y="%d">0s</text>`+"\n", svgMargin, axis)
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s (%s bins)</text>`+"\n",
+		width-20, axis, time.Duration(len(h.cols))*h.Bin, h.Bin)
+	sb.WriteString("</svg>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteHTML renders the heatmap as an HTML page embedding the SVG.
+func (h *heatmap) WriteHTML(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n",
+		html.EscapeString(h.Title))
+	if err != nil {
+		return err
+	}
+	if err := h.WriteSVG(w); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
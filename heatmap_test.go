@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHeatmapAdd tests bucketing samples into time bins and log2 buckets
+func TestHeatmapAdd(t *testing.T) {
+	h := newHeatmap("test", "ns", time.Second)
+	h.Add(uint64(5*time.Second), 3000)
+	h.Add(uint64(5*time.Second+10), 3500)
+	h.Add(uint64(7*time.Second), 0)
+	h.Add(uint64(time.Second), 1<<20)
+
+	if h.Columns() != 3 {
+		t.Fatalf("expected 3 columns, got %d", h.Columns())
+	}
+	if h.cols[0][12] != 2 || h.cols[0][21] != 1 || h.cols[2][0] != 1 {
+		t.Errorf("unexpected buckets: %v", h.cols)
+	}
+	lo, hi, ok := h.bucketRange()
+	if !ok || lo != 0 || hi != 21 {
+		t.Errorf("bucketRange = %d, %d, %v", lo, hi, ok)
+	}
+	if merged := h.merged(2); len(merged) != 2 || merged[0][12] != 2 || merged[1][0] != 1 {
+		t.Errorf("unexpected merged columns: %v", merged)
+	}
+	if got := h.bucketLabel(11); got != "1.024µs" {
+		t.Errorf("bucketLabel(11) = %s", got)
+	}
+}
+
+// TestHeatmapMaxColumns tests that samples after the last column are dropped
+func TestHeatmapMaxColumns(t *testing.T) {
+	h := newHeatmap("test", "ns", time.Millisecond)
+	h.Add(0, 1)
+	h.Add(uint64(heatmapMaxColumns-1)*uint64(time.Millisecond), 1)
+	h.Add(1<<62, 1)
+	if h.Columns() != heatmapMaxColumns || h.Dropped != 1 {
+		t.Errorf("columns = %d, dropped = %d", h.Columns(), h.Dropped)
+	}
+}
+
+// TestHeatmapSyscallSize tests that only reads and writes are plotted by size
+func TestHeatmapSyscallSize(t *testing.T) {
+	const data = `{"type": "printf", "data": "ts=100 probe=tracepoint:raw_syscalls:sys_exit tid=1 nr=0 ret=4096"}
+{"type": "printf", "data": "ts=200 probe=tracepoint:raw_syscalls:sys_exit tid=1 nr=257 ret=3"}
+{"type": "printf", "data": "ts=300 probe=tracepoint:syscalls:sys_exit_pwrite64 tid=1 ret=512"}
+`
+	var values []uint64
+	err := heatmapFromStream(strings.NewReader(data), "syscall", "size", syscallNamesX86_64, func(ts, value uint64) {
+		values = append(values, value)
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != 4096 || values[1] != 512 {
+		t.Errorf("sizes = %v, want [4096 512]", values)
+	}
+}
+
+// TestHeatmapLatency tests that only calls with both an entry and a return are plotted by latency
+func TestHeatmapLatency(t *testing.T) {
+	const vfsData = `{"type": "printf", "data": "ts=100 fn=kretfunc:vmlinux:vfs_read tid=1 rc=10"}
+{"type": "printf", "data": "ts=200 fn=kfunc:vmlinux:vfs_read tid=1 path='a' len=10"}
+{"type": "printf", "data": "ts=250 fn=kretfunc:vmlinux:vfs_read tid=1 rc=10"}
+`
+	const syscallData = `{"type": "printf", "data": "ts=100 probe=tracepoint:raw_syscalls:sys_enter tid=1 nr=0"}
+{"type": "printf", "data": "ts=400 probe=tracepoint:raw_syscalls:sys_exit tid=1 nr=0 ret=4096"}
+`
+	for _, tt := range []struct{ source, data string }{{"vfs", vfsData}, {"syscall", syscallData}} {
+		var values []uint64
+		err := heatmapFromStream(strings.NewReader(tt.data), tt.source, "latency", syscallNamesX86_64, func(ts, value uint64) {
+			values = append(values, value)
+		})
+		if isErrorUnsupportedPlatform(err) {
+			t.Skip()
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := uint64(50)
+		if tt.source == "syscall" {
+			want = 300
+		}
+		if len(values) != 1 || values[0] != want {
+			t.Errorf("%s latencies = %v, want [%d]", tt.source, values, want)
+		}
+	}
+}
+
+// TestHeatmapWriteSVG tests the SVG rendering
+func TestHeatmapWriteSVG(t *testing.T) {
+	h := newHeatmap("vfs <size>", "bytes", time.Second)
+	h.Add(0, 4096)
+	h.Add(uint64(time.Second), 65536)
+
+	var buf bytes.Buffer
+	if err := h.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Count(out, "<rect x=") != 2 {
+		t.Errorf("expected 2 cells, got:\n%s", out)
+	}
+	if !strings.Contains(out, "vfs &lt;size&gt;") || !strings.Contains(out, ">64K<") {
+		t.Errorf("missing escaped title or bucket label:\n%s", out)
+	}
+}
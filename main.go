@@ -20,6 +20,8 @@ var rootCmd = &cli.Command{
 		procCmd,
 		memCmd,
 		syscallCmd,
+		heatmapCmd,
+		timelineCmd,
 	},
 }
 
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// memOp classifies a memory probe.
+type memOp int
+
+const (
+	memOpOther memOp = iota
+	memOpMmap
+	memOpMunmap
+	memOpBrk
+	memOpFault
+)
+
+// classifyMemProbe maps probes such as "tracepoint:syscalls:sys_exit_mmap" or
+// "kprobe:handle_mm_fault" to the operation they record.
+func classifyMemProbe(probe string) memOp {
+	name := probe
+	if i := strings.LastIndexByte(probe, ':'); i >= 0 {
+		name = probe[i+1:]
+	}
+	switch {
+	case strings.Contains(name, "munmap"):
+		return memOpMunmap
+	case strings.Contains(name, "mremap"):
+		return memOpOther
+	case strings.Contains(name, "mmap"):
+		return memOpMmap
+	case strings.Contains(name, "brk"):
+		return memOpBrk
+	case strings.Contains(name, "fault"):
+		return memOpFault
+	default:
+		return memOpOther
+	}
+}
+
+// memMapFailed reports whether an mmap result is an error: zero or a negative errno.
+func memMapFailed(addr uint64) bool {
+	return addr == 0 || addr >= ^uint64(4095)
+}
+
+// memRegion is a live mapping [Start, End) of a process.
+type memRegion struct {
+	Pid      uint64 `json:"pid"`
+	Comm     string `json:"comm"`
+	Start    uint64 `json:"start"`
+	End      uint64 `json:"end"`
+	Type     string `json:"type"`
+	Path     string `json:"path,omitempty"`
+	MappedTs uint64 `json:"mapped_ts"`
+}
+
+// Class returns heap, stack, file or anon. Types recorded from mmap events count as
+// file-backed when they mention a file.
+func (r *memRegion) Class() string {
+	switch {
+	case r.Type == "heap" || r.Type == "stack":
+		return r.Type
+	case strings.Contains(strings.ToLower(r.Type), "file"):
+		return "file"
+	default:
+		return "anon"
+	}
+}
+
+// Size returns the length of the region in bytes.
+func (r *memRegion) Size() uint64 {
+	return r.End - r.Start
+}
+
+// memRegionMap is the set of live mappings of one process, kept sorted by start
+// address and non-overlapping. The brk heap is tracked separately from its first
+// observed break, so heap growth is counted from the start of the capture.
+type memRegionMap struct {
+	Pid     uint64
+	Comm    string
+	regions []*memRegion
+	live    uint64
+
+	MappedBytes   uint64
+	UnmappedBytes uint64
+	Maps          int64
+	Unmaps        int64
+
+	HeapStart uint64
+	HeapEnd   uint64
+	heapKnown bool
+}
+
+func newMemRegionMap(pid uint64) *memRegionMap {
+	return &memRegionMap{Pid: pid}
+}
+
+// LiveBytes returns the bytes of live mappings plus heap growth.
+func (m *memRegionMap) LiveBytes() uint64 {
+	return m.live + m.HeapGrowth()
+}
+
+// HeapGrowth returns how far the break moved above its first observed value.
+func (m *memRegionMap) HeapGrowth() uint64 {
+	if m.HeapEnd < m.HeapStart {
+		return 0
+	}
+	return m.HeapEnd - m.HeapStart
+}
+
+// Regions returns the live mappings sorted by address.
+func (m *memRegionMap) Regions() []*memRegion {
+	return m.regions
+}
+
+// Map records a new mapping and returns it. Any overlap with existing mappings is
+// replaced, as with MAP_FIXED.
+func (m *memRegionMap) Map(start, size, ts uint64, typ string) *memRegion {
+	if size == 0 {
+		return nil
+	}
+	m.remove(start, start+size)
+	r := &memRegion{Pid: m.Pid, Comm: m.Comm, Start: start, End: start + size, Type: typ, MappedTs: ts}
+	i := sort.Search(len(m.regions), func(i int) bool { return m.regions[i].Start >= start })
+	m.regions = append(m.regions, nil)
+	copy(m.regions[i+1:], m.regions[i:])
+	m.regions[i] = r
+	m.live += size
+	m.MappedBytes += size
+	m.Maps++
+	return r
+}
+
+// Lookup returns the live mapping containing addr, or nil.
+func (m *memRegionMap) Lookup(addr uint64) *memRegion {
+	i := sort.Search(len(m.regions), func(i int) bool { return m.regions[i].End > addr })
+	if i < len(m.regions) && m.regions[i].Start <= addr {
+		return m.regions[i]
+	}
+	return nil
+}
+
+// InHeap reports whether addr lies between the first and the current program break.
+func (m *memRegionMap) InHeap(addr uint64) bool {
+	return m.heapKnown && addr >= m.HeapStart && addr < m.HeapEnd
+}
+
+// LoadProcMaps adds the mappings of a /proc/<pid>/maps snapshot, stamped at ts.
+// Mappings named [heap] and [stack] get those types, named mappings are file-backed
+// and the rest are anonymous.
+func (m *memRegionMap) LoadProcMaps(r io.Reader, ts uint64) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return fmt.Errorf("invalid maps line %d: %q", line, scanner.Text())
+		}
+		start, err := strconv.ParseUint(startStr, 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid start address on maps line %d: %w", line, err)
+		}
+		end, err := strconv.ParseUint(endStr, 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid end address on maps line %d: %w", line, err)
+		}
+		if end <= start {
+			continue
+		}
+		var path string
+		if len(fields) >= 6 {
+			path = strings.Join(fields[5:], " ")
+		}
+		typ := "anon"
+		switch {
+		case path == "[heap]":
+			typ = "heap"
+		case strings.HasPrefix(path, "[stack"):
+			typ = "stack"
+		case path != "" && !strings.HasPrefix(path, "["):
+			typ = "file"
+		}
+		if region := m.Map(start, end-start, ts, typ); region != nil {
+			region.Path = path
+		}
+	}
+	return scanner.Err()
+}
+
+// Unmap removes [start, start+size), splitting mappings that are only partially covered.
+// It returns the number of live bytes released.
+func (m *memRegionMap) Unmap(start, size uint64) uint64 {
+	if size == 0 {
+		return 0
+	}
+	freed := m.remove(start, start+size)
+	m.UnmappedBytes += freed
+	m.Unmaps++
+	return freed
+}
+
+func (m *memRegionMap) remove(start, end uint64) uint64 {
+	var freed uint64
+	i := sort.Search(len(m.regions), func(i int) bool { return m.regions[i].End > start })
+	var kept []*memRegion
+	j := i
+	for ; j < len(m.regions) && m.regions[j].Start < end; j++ {
+		r := m.regions[j]
+		if r.Start < start {
+			head := *r
+			head.End = start
+			kept = append(kept, &head)
+		}
+		if r.End > end {
+			tail := *r
+			tail.Start = end
+			kept = append(kept, &tail)
+		}
+		freed += min(r.End, end) - max(r.Start, start)
+	}
+	if j > i || len(kept) > 0 {
+		m.regions = append(m.regions[:i], append(kept, m.regions[j:]...)...)
+	}
+	m.live -= freed
+	return freed
+}
+
+// Brk moves the program break.
+func (m *memRegionMap) Brk(addr uint64) {
+	if addr == 0 {
+		return
+	}
+	if !m.heapKnown {
+		m.HeapStart, m.heapKnown = addr, true
+	}
+	m.HeapEnd = addr
+}
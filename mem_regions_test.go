@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMemRegionMap tests mapping, partial unmapping and MAP_FIXED replacement
+func TestMemRegionMap(t *testing.T) {
+	m := newMemRegionMap(1)
+	m.Map(0x1000, 0x4000, 10, "anon")
+	m.Map(0x10000, 0x1000, 20, "file")
+
+	if freed := m.Unmap(0x2000, 0x1000); freed != 0x1000 {
+		t.Fatalf("freed = %#x, want 0x1000", freed)
+	}
+	regions := m.Regions()
+	if len(regions) != 3 || regions[0].End != 0x2000 || regions[1].Start != 0x3000 || regions[1].End != 0x5000 {
+		t.Fatalf("unexpected split regions: %+v %+v", regions[0], regions[1])
+	}
+	if regions[1].MappedTs != 10 {
+		t.Errorf("split region lost its map time: %+v", regions[1])
+	}
+
+	// MAP_FIXED over the tail of the first mapping and the gap before the second
+	m.Map(0x4000, 0x8000, 30, "anon")
+	if m.LiveBytes() != 0x1000+0x1000+0x8000+0x1000 {
+		t.Errorf("live = %#x", m.LiveBytes())
+	}
+
+	// unmapping a range spanning several regions and holes
+	if freed := m.Unmap(0, 0x20000); freed != m.MappedBytes-0x1000-0x1000 {
+		t.Errorf("freed = %#x", freed)
+	}
+	if len(m.Regions()) != 0 || m.LiveBytes() != 0 {
+		t.Errorf("expected no live regions, got %+v", m.Regions())
+	}
+
+	m.Brk(0x500000)
+	m.Brk(0x540000)
+	if m.HeapGrowth() != 0x40000 || m.LiveBytes() != 0x40000 {
+		t.Errorf("heap growth = %#x", m.HeapGrowth())
+	}
+}
+
+// TestClassifyMemProbe tests mapping probe names to memory operations
+func TestClassifyMemProbe(t *testing.T) {
+	tests := map[string]memOp{
+		"tracepoint:syscalls:sys_exit_mmap":    memOpMmap,
+		"tracepoint:syscalls:sys_enter_munmap": memOpMunmap,
+		"tracepoint:syscalls:sys_exit_brk":     memOpBrk,
+		"kprobe:handle_mm_fault":               memOpFault,
+		"kprobe:do_mremap":                     memOpOther,
+	}
+	for probe, want := range tests {
+		if got := classifyMemProbe(probe); got != want {
+			t.Errorf("classifyMemProbe(%q) = %d, want %d", probe, got, want)
+		}
+	}
+	if !memMapFailed(0xfffffffffffffff4) || memMapFailed(0x7f0000000000) {
+		t.Error("unexpected memMapFailed result")
+	}
+}
+
+const memLeaksTestData = `{"type": "printf", "data": "ts=1000000000 fn=tracepoint:syscalls:sys_exit_mmap pid=1 tid=1 comm=svc addr=0x7f0000000000 size=1048576 type=anon"}
+{"type": "printf", "data": "ts=2000000000 fn=tracepoint:syscalls:sys_exit_mmap pid=1 tid=1 comm=svc addr=0x7f0000200000 size=4096 type=file"}
+{"type": "printf", "data": "ts=3000000000 fn=tracepoint:syscalls:sys_exit_mmap pid=1 tid=1 comm=svc addr=0xfffffffffffffff4 size=4096 type=anon"}
+{"type": "printf", "data": "ts=12000000000 fn=tracepoint:syscalls:sys_enter_munmap pid=1 tid=1 comm=svc addr=0x7f0000200000 size=4096"}
+{"type": "printf", "data": "ts=13000000000 fn=tracepoint:syscalls:sys_exit_brk pid=2 tid=2 comm=web addr=0x1000000 size=0"}
+{"type": "printf", "data": "ts=35000000000 fn=tracepoint:syscalls:sys_exit_brk pid=2 tid=2 comm=web addr=0x1021000 size=0"}
+`
+
+// TestMemLeakTracker tests the leak report from a raw stream
+func TestMemLeakTracker(t *testing.T) {
+	tracker := newMemLeakTracker(10 * time.Second)
+	err := memJSONParseThenAppend(strings.NewReader(memLeaksTestData), func(e *memRawEvent) error {
+		tracker.Handle(e)
+		return nil
+	})
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Finish()
+
+	if tracker.Skipped != 1 {
+		t.Errorf("skipped = %d, want the failed mmap", tracker.Skipped)
+	}
+	// The third window has no events and carries the second one forward.
+	wantTimeline := []uint64{1048576 + 4096, 1048576, 1048576, 1048576 + 0x21000}
+	if len(tracker.Timeline) != len(wantTimeline) {
+		t.Fatalf("unexpected timeline: %+v", tracker.Timeline)
+	}
+	for i, want := range wantTimeline {
+		if tracker.Timeline[i].LiveBytes != want || tracker.Timeline[i].Offset != time.Duration(i+1)*10*time.Second {
+			t.Errorf("timeline[%d] = %+v, want %d bytes", i, tracker.Timeline[i], want)
+		}
+	}
+
+	report := tracker.Report(time.Second, 0)
+	if len(report.Processes) != 2 || report.Processes[0].Comm != "svc" || report.Processes[1].HeapGrowth != 0x21000 {
+		t.Errorf("unexpected processes: %+v", report.Processes)
+	}
+	if len(report.Regions) != 1 || report.Regions[0].Size != 1048576 || report.Regions[0].Age != 34*time.Second {
+		t.Errorf("unexpected live regions: %+v", report.Regions)
+	}
+}
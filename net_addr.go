@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// parseNetAddr parses an address as printed by bpftrace's ntop(), with optional quotes.
+// IPv4-mapped IPv6 addresses are normalised to plain IPv4 and zones are dropped.
+func parseNetAddr(v string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.Trim(v, "'\""))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap().WithZone(""), nil
+}
+
+// netAddrFamily returns 4 or 6 for the family column, or nil when the address is unknown.
+func netAddrFamily(a netip.Addr) any {
+	switch {
+	case a.Is4():
+		return uint8(4)
+	case a.Is6():
+		return uint8(6)
+	default:
+		return nil
+	}
+}
+
+// netAddrColumns returns the text form of an address and its 128-bit value split
+// into high and low halves. IPv4 addresses are stored in their IPv4-mapped form
+// (::ffff:a.b.c.d), so a CIDR filter is a range check on the two halves.
+// Unknown addresses produce NULLs.
+func netAddrColumns(a netip.Addr) (text, hi, lo any) {
+	if !a.IsValid() {
+		return nil, nil, nil
+	}
+	b := a.As16()
+	return a.String(), binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+}
+
+// netAddrString returns the text form of an address, or "" when it is unknown.
+func netAddrString(a netip.Addr) string {
+	if !a.IsValid() {
+		return ""
+	}
+	return a.String()
+}
+
+// netEndpoint formats an address and port, bracketing IPv6 addresses.
+func netEndpoint(a netip.Addr, port uint16) string {
+	if !a.IsValid() {
+		return "?:" + strconv.FormatUint(uint64(port), 10)
+	}
+	return netip.AddrPortFrom(a, port).String()
+}
+
+// netFilterClause is a single comparison of a --where expression.
+type netFilterClause struct {
+	field  string
+	negate bool
+	prefix netip.Prefix // address fields
+	value  string       // other fields
+}
+
+// netFilter is a conjunction of clauses parsed from a --where expression such as
+// "saddr in 10.0.0.0/8 and dport=443". Address fields accept "in"/"not in" with a
+// CIDR prefix and "="/"!=" with an address or prefix.
+type netFilter struct {
+	clauses []netFilterClause
+}
+
+var netFilterFields = map[string]bool{
+	"saddr": true, "daddr": true, "addr": true,
+	"sport": true, "dport": true, "port": true,
+	"proto": true, "comm": true, "pid": true, "probe": true,
+}
+
+func isNetAddrField(field string) bool {
+	return field == "saddr" || field == "daddr" || field == "addr"
+}
+
+// parseNetFilter parses a --where expression. An empty expression yields a nil filter
+// that matches everything.
+func parseNetFilter(expr string) (*netFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+	f := &netFilter{}
+	for _, part := range splitAnd(expr) {
+		c, err := parseNetFilterClause(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", part, err)
+		}
+		f.clauses = append(f.clauses, c)
+	}
+	return f, nil
+}
+
+// splitAnd splits an expression on the case-insensitive keyword "and".
+func splitAnd(expr string) []string {
+	var parts, cur []string
+	for _, word := range strings.Fields(expr) {
+		if strings.EqualFold(word, "and") {
+			parts = append(parts, strings.Join(cur, " "))
+			cur = nil
+			continue
+		}
+		cur = append(cur, word)
+	}
+	return append(parts, strings.Join(cur, " "))
+}
+
+func parseNetFilterClause(s string) (netFilterClause, error) {
+	var c netFilterClause
+	var op, value string
+	if i := strings.Index(s, "!="); i >= 0 {
+		c.field, op, value = s[:i], "!=", s[i+2:]
+	} else if i := strings.IndexByte(s, '='); i >= 0 {
+		c.field, op, value = s[:i], "=", s[i+1:]
+	} else {
+		words := strings.Fields(s)
+		switch {
+		case len(words) == 3 && strings.EqualFold(words[1], "in"):
+			c.field, op, value = words[0], "in", words[2]
+		case len(words) == 4 && strings.EqualFold(words[1], "not") && strings.EqualFold(words[2], "in"):
+			c.field, op, value = words[0], "not in", words[3]
+		default:
+			return c, fmt.Errorf("expected field=value, field!=value, field in prefix or field not in prefix")
+		}
+	}
+	c.field = strings.ToLower(strings.TrimSpace(c.field))
+	value = strings.Trim(strings.TrimSpace(value), "'\"")
+	c.negate = op == "!=" || op == "not in"
+	if !netFilterFields[c.field] {
+		return c, fmt.Errorf("unknown field: %s", c.field)
+	}
+	if value == "" {
+		return c, fmt.Errorf("missing value")
+	}
+
+	if !isNetAddrField(c.field) {
+		if op == "in" || op == "not in" {
+			return c, fmt.Errorf("%s does not support %s", c.field, op)
+		}
+		c.value = value
+		return c, nil
+	}
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return c, err
+		}
+		c.prefix = prefix.Masked()
+	} else {
+		addr, err := parseNetAddr(value)
+		if err != nil {
+			return c, err
+		}
+		c.prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	// match IPv4 prefixes written in IPv4-mapped form against unmapped event addresses
+	if c.prefix.Addr().Is4In6() && c.prefix.Bits() >= 96 {
+		c.prefix = netip.PrefixFrom(c.prefix.Addr().Unmap(), c.prefix.Bits()-96)
+	}
+	return c, nil
+}
+
+func (c *netFilterClause) match(e *netRawEvent) bool {
+	var ok bool
+	switch c.field {
+	case "saddr":
+		ok = c.prefix.Contains(e.SrcAddr)
+	case "daddr":
+		ok = c.prefix.Contains(e.DstAddr)
+	case "addr":
+		ok = c.prefix.Contains(e.SrcAddr) || c.prefix.Contains(e.DstAddr)
+	case "sport":
+		ok = strconv.FormatUint(uint64(e.SrcPort), 10) == c.value
+	case "dport":
+		ok = strconv.FormatUint(uint64(e.DstPort), 10) == c.value
+	case "port":
+		ok = strconv.FormatUint(uint64(e.SrcPort), 10) == c.value || strconv.FormatUint(uint64(e.DstPort), 10) == c.value
+	case "proto":
+		ok = strings.EqualFold(e.Protocol, c.value)
+	case "comm":
+		ok = e.Comm == c.value
+	case "pid":
+		ok = strconv.FormatUint(e.Pid, 10) == c.value
+	case "probe":
+		ok = e.Probe == c.value || strings.HasSuffix(e.Probe, ":"+c.value)
+	}
+	return ok != c.negate
+}
+
+// Match reports whether the event satisfies every clause. A nil filter matches everything.
+func (f *netFilter) Match(e *netRawEvent) bool {
+	if f == nil {
+		return true
+	}
+	for i := range f.clauses {
+		if !f.clauses[i].match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// netWhereFlag returns the --where flag accepted by net commands that read raw events.
+func netWhereFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "where",
+		Usage: "only keep events matching e.g. \"saddr in 10.0.0.0/8 and dport=443\" (fields: saddr, daddr, addr, sport, dport, port, proto, comm, pid, probe)",
+	}
+}
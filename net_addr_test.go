@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestParseNetAddr tests address normalisation
+func TestParseNetAddr(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.1":          "10.0.0.1",
+		"'::ffff:10.0.0.1'": "10.0.0.1",
+		"2001:DB8::1":       "2001:db8::1",
+		"fe80::1%eth0":      "fe80::1",
+		"\"192.168.1.1\"":   "192.168.1.1",
+	}
+	for in, want := range tests {
+		addr, err := parseNetAddr(in)
+		if err != nil {
+			t.Errorf("parseNetAddr(%q) error: %v", in, err)
+			continue
+		}
+		if addr.String() != want {
+			t.Errorf("parseNetAddr(%q) = %s, want %s", in, addr, want)
+		}
+	}
+	if _, err := parseNetAddr("not-an-ip"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+// TestNetAddrColumns tests the split numeric representation
+func TestNetAddrColumns(t *testing.T) {
+	text, hi, lo := netAddrColumns(netip.MustParseAddr("10.0.0.1"))
+	if text != "10.0.0.1" || hi != uint64(0) || lo != uint64(0x0000ffff0a000001) {
+		t.Errorf("unexpected IPv4 columns: %v %v %x", text, hi, lo)
+	}
+	_, hi, lo = netAddrColumns(netip.MustParseAddr("2001:db8::1"))
+	if hi != uint64(0x20010db800000000) || lo != uint64(1) {
+		t.Errorf("unexpected IPv6 columns: %x %x", hi, lo)
+	}
+	if text, _, _ := netAddrColumns(netip.Addr{}); text != nil {
+		t.Errorf("expected NULL for invalid address, got %v", text)
+	}
+	if netAddrFamily(netip.MustParseAddr("::1")) != uint8(6) || netAddrFamily(netip.Addr{}) != nil {
+		t.Error("unexpected address family")
+	}
+}
+
+// TestNetRawEventInvalidAddr tests counting unparsable addresses
+func TestNetRawEventInvalidAddr(t *testing.T) {
+	var e netRawEvent
+	if err := e.HandleLogfmt([]byte("saddr"), []byte("garbage")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.HandleLogfmt([]byte("daddr"), []byte("::ffff:1.2.3.4")); err != nil {
+		t.Fatal(err)
+	}
+	if e.InvalidAddrs != 1 || e.SrcAddr.IsValid() || e.DstAddr.String() != "1.2.3.4" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+// TestNetFilter tests --where expressions
+func TestNetFilter(t *testing.T) {
+	e := &netRawEvent{
+		Probe: "kprobe:tcp_sendmsg", Comm: "curl", Pid: 7, Protocol: "tcp",
+		SrcAddr: netip.MustParseAddr("10.1.2.3"), SrcPort: 40000,
+		DstAddr: netip.MustParseAddr("2001:db8::5"), DstPort: 443,
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"saddr in 10.0.0.0/8 and dport=443", true},
+		{"saddr in ::ffff:10.0.0.0/104", true},
+		{"saddr not in 10.0.0.0/8", false},
+		{"daddr in 2001:db8::/32 AND proto=TCP", true},
+		{"addr=10.1.2.3", true},
+		{"daddr=10.1.2.3", false},
+		{"port=40000 and comm=curl and pid=7", true},
+		{"dport!=443", false},
+		{"probe=tcp_sendmsg", true},
+	}
+	for _, tt := range tests {
+		f, err := parseNetFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseNetFilter(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(e); got != tt.want {
+			t.Errorf("%q matched = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"bogus=1", "saddr in 10.0.0.0/33", "dport in 443", "saddr"} {
+		if _, err := parseNetFilter(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+type netService struct {
+	port  uint16
+	proto string
+}
+
+// netPod is an entry of a --pods JSON file.
+type netPod struct {
+	IP        string `json:"ip"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Container string `json:"container"`
+}
+
+// Name returns "namespace/pod", or the pod alone without a namespace.
+func (p *netPod) Name() string {
+	if p.Namespace == "" {
+		return p.Pod
+	}
+	return p.Namespace + "/" + p.Pod
+}
+
+// netEnricher names addresses and ports from local files, without any DNS lookups.
+type netEnricher struct {
+	services map[netService]string
+	hosts    map[netip.Addr]string
+	pods     map[netip.Addr]string
+}
+
+func newNetEnricher() *netEnricher {
+	return &netEnricher{
+		services: make(map[netService]string),
+		hosts:    make(map[netip.Addr]string),
+		pods:     make(map[netip.Addr]string),
+	}
+}
+
+// netEnrichFlags returns the flags that configure a netEnricher.
+func netEnrichFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "services",
+			Usage: "services file mapping ports to names, e.g. /etc/services",
+		},
+		&cli.StringSliceFlag{
+			Name:  "hosts",
+			Usage: "hosts file (/etc/hosts format) or .csv of ip,name lines mapping addresses to names (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "pods",
+			Usage: "JSON array of {\"ip\", \"pod\", \"namespace\", \"container\"} objects mapping addresses to pods",
+		},
+	}
+}
+
+// newNetEnricherFromFlags builds a netEnricher from the flags added by netEnrichFlags.
+// It returns nil when no enrichment source is configured.
+func newNetEnricherFromFlags(command *cli.Command) (*netEnricher, error) {
+	services := command.String("services")
+	hosts := command.StringSlice("hosts")
+	pods := command.String("pods")
+	if services == "" && len(hosts) == 0 && pods == "" {
+		return nil, nil
+	}
+
+	n := newNetEnricher()
+	load := func(path string, fn func(io.Reader) error) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		if err := fn(f); err != nil {
+			return fmt.Errorf("load %s: %w", path, err)
+		}
+		return nil
+	}
+	if services != "" {
+		if err := load(services, n.LoadServices); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range hosts {
+		fn := n.LoadHosts
+		if strings.HasSuffix(strings.ToLower(path), ".csv") {
+			fn = n.LoadHostsCSV
+		}
+		if err := load(path, fn); err != nil {
+			return nil, err
+		}
+	}
+	if pods != "" {
+		if err := load(pods, n.LoadPods); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// LoadServices reads "name port/proto [aliases] [# comment]" lines as in /etc/services.
+// The first name listed for a port wins.
+func (n *netEnricher) LoadServices(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) < 2 {
+			continue
+		}
+		portStr, proto, ok := strings.Cut(fields[1], "/")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			continue
+		}
+		key := netService{port: uint16(port), proto: strings.ToLower(proto)}
+		if _, ok := n.services[key]; !ok {
+			n.services[key] = fields[0]
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadHosts reads "address name [aliases]" lines as in /etc/hosts.
+func (n *netEnricher) LoadHosts(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) < 2 {
+			continue
+		}
+		addr, err := parseNetAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		if _, ok := n.hosts[addr]; !ok {
+			n.hosts[addr] = fields[1]
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadHostsCSV reads "ip,name" records. Records whose first column is not an address,
+// such as a header line, are skipped. Later records override earlier ones.
+func (n *netEnricher) LoadHostsCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 2 {
+			continue
+		}
+		addr, err := parseNetAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+		n.hosts[addr] = strings.TrimSpace(record[1])
+	}
+}
+
+// LoadPods reads a JSON array of netPod objects.
+func (n *netEnricher) LoadPods(r io.Reader) error {
+	var pods []netPod
+	if err := json.NewDecoder(r).Decode(&pods); err != nil {
+		return err
+	}
+	for i := range pods {
+		addr, err := parseNetAddr(pods[i].IP)
+		if err != nil {
+			return fmt.Errorf("pod %s: %w", pods[i].Pod, err)
+		}
+		n.pods[addr] = pods[i].Name()
+	}
+	return nil
+}
+
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// Host returns the host name of an address.
+func (n *netEnricher) Host(a netip.Addr) string {
+	if n == nil {
+		return ""
+	}
+	return n.hosts[a]
+}
+
+// Pod returns the "namespace/pod" name of an address.
+func (n *netEnricher) Pod(a netip.Addr) string {
+	if n == nil {
+		return ""
+	}
+	return n.pods[a]
+}
+
+// Service returns the service name of a port, trying tcp and then udp when proto is unknown.
+func (n *netEnricher) Service(port uint16, proto string) string {
+	if n == nil || port == 0 {
+		return ""
+	}
+	proto = strings.ToLower(proto)
+	if proto == "tcp" || proto == "udp" {
+		return n.services[netService{port: port, proto: proto}]
+	}
+	if name, ok := n.services[netService{port: port, proto: "tcp"}]; ok {
+		return name
+	}
+	return n.services[netService{port: port, proto: "udp"}]
+}
+
+// Name returns the most specific name of an address: pod, host, or the address itself.
+func (n *netEnricher) Name(a netip.Addr) string {
+	if pod := n.Pod(a); pod != "" {
+		return pod
+	}
+	if host := n.Host(a); host != "" {
+		return host
+	}
+	return a.String()
+}
+
+// Apply fills the host, pod and service columns of a raw event.
+func (n *netEnricher) Apply(e *netRawEvent) {
+	if n == nil {
+		return
+	}
+	e.SrcHost, e.DstHost = n.Host(e.SrcAddr), n.Host(e.DstAddr)
+	e.SrcPod, e.DstPod = n.Pod(e.SrcAddr), n.Pod(e.DstAddr)
+	e.SrcService, e.DstService = n.Service(e.SrcPort, e.Protocol), n.Service(e.DstPort, e.Protocol)
+}
+
+// RewriteKey names the addresses and ports in a count map key such as
+// "curl,10.0.0.2:443". Each comma-separated part that is an address or an
+// address:port pair is replaced; other parts are kept as they are.
+func (n *netEnricher) RewriteKey(key string) string {
+	if n == nil {
+		return key
+	}
+	parts := strings.Split(key, ",")
+	for i, part := range parts {
+		p := strings.TrimSpace(part)
+		if ap, err := netip.ParseAddrPort(p); err == nil {
+			port := strconv.FormatUint(uint64(ap.Port()), 10)
+			if svc := n.Service(ap.Port(), ""); svc != "" {
+				port = svc
+			}
+			addr := ap.Addr().Unmap()
+			name := n.Name(addr)
+			if addr.Is6() && name == addr.String() {
+				name = "[" + name + "]"
+			}
+			parts[i] = name + ":" + port
+		} else if addr, err := parseNetAddr(p); err == nil {
+			parts[i] = n.Name(addr)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const testServices = `# Network services
+http		80/tcp		www		# WorldWideWeb HTTP
+https		443/tcp
+https		443/udp
+domain		53/udp
+`
+
+const testHosts = `127.0.0.1	localhost
+10.0.0.2	db.internal db
+# 10.0.0.3	commented
+::1		ip6-localhost
+`
+
+const testHostsCSV = `ip,name
+10.0.0.9,"cache.internal"
+10.0.0.2,db-primary
+`
+
+const testPods = `[
+  {"ip": "10.244.1.5", "pod": "web-0", "namespace": "shop", "container": "nginx"},
+  {"ip": "10.244.1.6", "pod": "worker"}
+]`
+
+func newTestNetEnricher(t *testing.T) *netEnricher {
+	t.Helper()
+	n := newNetEnricher()
+	for _, load := range []struct {
+		fn   func(r io.Reader) error
+		data string
+	}{
+		{n.LoadServices, testServices},
+		{n.LoadHosts, testHosts},
+		{n.LoadHostsCSV, testHostsCSV},
+		{n.LoadPods, testPods},
+	} {
+		if err := load.fn(strings.NewReader(load.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
+
+// TestNetEnricherLookups tests service, host and pod lookups
+func TestNetEnricherLookups(t *testing.T) {
+	n := newTestNetEnricher(t)
+
+	if got := n.Service(80, "tcp"); got != "http" {
+		t.Errorf("Service(80, tcp) = %q", got)
+	}
+	if got := n.Service(53, ""); got != "domain" {
+		t.Errorf("Service(53) = %q, want udp fallback", got)
+	}
+	if got := n.Service(80, "udp"); got != "" {
+		t.Errorf("Service(80, udp) = %q, want empty", got)
+	}
+	if got := n.Host(netip.MustParseAddr("10.0.0.2")); got != "db-primary" {
+		t.Errorf("Host(10.0.0.2) = %q, want CSV override", got)
+	}
+	if got := n.Host(netip.MustParseAddr("10.0.0.3")); got != "" {
+		t.Errorf("Host(10.0.0.3) = %q, want commented line skipped", got)
+	}
+	if got := n.Name(netip.MustParseAddr("10.244.1.5")); got != "shop/web-0" {
+		t.Errorf("Name(10.244.1.5) = %q", got)
+	}
+	if got := n.Name(netip.MustParseAddr("10.244.1.6")); got != "worker" {
+		t.Errorf("Name(10.244.1.6) = %q", got)
+	}
+
+	var nilEnricher *netEnricher
+	if nilEnricher.Service(80, "tcp") != "" || nilEnricher.RewriteKey("10.0.0.2") != "10.0.0.2" {
+		t.Error("nil enricher should not rename anything")
+	}
+}
+
+// TestNetEnricherApply tests filling the name columns of raw events
+func TestNetEnricherApply(t *testing.T) {
+	n := newTestNetEnricher(t)
+	e := &netRawEvent{
+		Protocol: "tcp",
+		SrcAddr:  netip.MustParseAddr("10.244.1.5"), SrcPort: 40000,
+		DstAddr: netip.MustParseAddr("10.0.0.9"), DstPort: 443,
+	}
+	n.Apply(e)
+	if e.SrcPod != "shop/web-0" || e.SrcHost != "" || e.SrcService != "" {
+		t.Errorf("unexpected source names: %+v", e)
+	}
+	if e.DstHost != "cache.internal" || e.DstPod != "" || e.DstService != "https" {
+		t.Errorf("unexpected destination names: %+v", e)
+	}
+}
+
+// TestNetCountRenameOther tests enriching count map keys
+func TestNetCountRenameOther(t *testing.T) {
+	n := newTestNetEnricher(t)
+	e := NetCountEvent{TCPConnect: 1, Other: map[string]int64{
+		"curl,10.0.0.2:443":    3,
+		"curl,db.internal:443": 0,
+		"10.244.1.5":           2,
+		"[2001:db8::1]:80":     1,
+		"nginx":                4,
+	}}
+	e.RenameOther(n)
+
+	want := map[string]int64{
+		"curl,db-primary:https": 3,
+		"curl,db.internal:443":  0,
+		"shop/web-0":            2,
+		"[2001:db8::1]:http":    1,
+		"nginx":                 4,
+	}
+	if len(e.Other) != len(want) {
+		t.Fatalf("unexpected keys: %v", e.Other)
+	}
+	for k, v := range want {
+		if e.Other[k] != v {
+			t.Errorf("Other[%q] = %d, want %d (got %v)", k, e.Other[k], v, e.Other)
+		}
+	}
+	if e.Total() != 1 {
+		t.Errorf("Total = %d, want 1 (other keys are not operations)", e.Total())
+	}
+	if keys := e.Other.Sorted(); keys[0] != "nginx" {
+		t.Errorf("Other.Sorted() = %v", keys)
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// otlpSpanBatch is the number of spans sent per OTLP request.
+	otlpSpanBatch = 512
+	// otlpQueueSize is the number of requests waiting to be sent before new
+	// ones are dropped, or wait for room when the input is a file.
+	otlpQueueSize = 64
+	// otlpDrainTimeout bounds the time Close waits for queued requests.
+	otlpDrainTimeout = 10 * time.Second
+)
+
+// otlpFlags returns the flags of the commands that can export to an OTLP collector.
+func otlpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "otlp-endpoint",
+			Usage: "OTLP collector to export to, e.g. http://127.0.0.1:4318 (http/json) or http://127.0.0.1:4317 (grpc)",
+		},
+		&cli.StringFlag{
+			Name:  "otlp-protocol",
+			Value: "http/json",
+			Usage: "OTLP transport: http/json, grpc",
+		},
+		&cli.StringFlag{
+			Name:  "otlp-run",
+			Usage: "run id set as the bpfstream.run resource attribute (default: the start time)",
+		},
+	}
+}
+
+// otlpAnyValue is an OTLP attribute value in the protobuf JSON mapping, where
+// 64-bit integers are strings.
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAttributes converts attributes to OTLP key-values, sorted by key.
+// Empty strings are dropped.
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var value otlpAnyValue
+		switch v := v.(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+			value.StringValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case uint64:
+			s := strconv.FormatUint(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpStatusError is STATUS_CODE_ERROR.
+const otlpStatusError = 2
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+// otlpTemporalityDelta is AGGREGATION_TEMPORALITY_DELTA: every interval of a
+// count command holds the counts of that interval only.
+const otlpTemporalityDelta = 1
+
+type otlpMetric struct {
+	Name string   `json:"name"`
+	Unit string   `json:"unit,omitempty"`
+	Sum  *otlpSum `json:"sum"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// wallClock returns the wall clock of a bpftrace time message, which only
+// carries the time of day, on the current date. A zero start stays zero.
+func wallClock(start time.Time) time.Time {
+	if start.IsZero() || start.Year() != 0 {
+		return start
+	}
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(),
+		start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.Local)
+}
+
+// countInterval returns the wall-clock bounds of the n-th interval (1-based)
+// of a count command that started at start.
+func countInterval(start time.Time, interval time.Duration, n int) (time.Time, time.Time) {
+	from := start.Add(time.Duration(n-1) * interval)
+	return from, from.Add(interval)
+}
+
+// otlpExport is a request waiting in the send queue. signal is "traces" or
+// "metrics".
+type otlpExport struct {
+	signal  string
+	payload any
+}
+
+// OTLPExporter sends paired calls as spans and count intervals as metrics to
+// an OTLP collector, over HTTP with the JSON encoding or over gRPC. Requests
+// are sent from a background goroutine so that a slow collector never blocks
+// a live stream; they are dropped when the queue is full. A file is replayed
+// at the pace of the collector instead, so that no request is lost.
+type OTLPExporter struct {
+	endpoint string
+	protocol string
+	run      string
+	resource otlpResource
+	client   *http.Client
+	interval time.Duration
+	created  time.Time
+
+	spans   []*otlpSpan
+	spanSeq uint64
+
+	queue   chan otlpExport
+	block   bool
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	Dropped int64
+
+	// anchor is the wall clock of the event at firstTs, used to convert
+	// bpftrace timestamps to unix time.
+	anchor  time.Time
+	firstTs uint64
+	started bool
+}
+
+// NewOTLPExporter creates an OTLPExporter from the flags added by otlpFlags.
+// It returns nil when no endpoint is configured.
+func NewOTLPExporter(command *cli.Command) (*OTLPExporter, error) {
+	endpoint := command.String("otlp-endpoint")
+	if endpoint == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: must be an http:// or https:// URL", endpoint)
+	}
+	protocol := command.String("otlp-protocol")
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch protocol {
+	case "http/json":
+	case "grpc":
+		// gRPC runs over HTTP/2, in cleartext for http:// endpoints.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Protocols = new(http.Protocols)
+		if strings.HasPrefix(endpoint, "https://") {
+			transport.Protocols.SetHTTP2(true)
+		} else {
+			transport.Protocols.SetUnencryptedHTTP2(true)
+		}
+		client.Transport = transport
+	default:
+		return nil, fmt.Errorf("invalid OTLP protocol: %s (must be http/json or grpc)", protocol)
+	}
+
+	created := time.Now()
+	run := command.String("otlp-run")
+	if run == "" {
+		run = created.Format("20060102T150405")
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("get hostname: %w", err)
+	}
+
+	interval := time.Second
+	if d := command.Duration("interval"); d > 0 {
+		interval = d
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &OTLPExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		protocol: protocol,
+		run:      run,
+		resource: otlpResource{Attributes: otlpAttributes(map[string]any{
+			"service.name":  "bpfstream",
+			"host.name":     host,
+			"bpfstream.run": run,
+		})},
+		client:   client,
+		interval: interval,
+		created:  created,
+		queue:    make(chan otlpExport, otlpQueueSize),
+		block:    command.String("input") != "" && command.String("input") != "-",
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go o.sendLoop()
+	return o, nil
+}
+
+// SetStart anchors bpftrace timestamps on the first event, once. Without a
+// start time from the stream, the first event is taken to happen now.
+func (o *OTLPExporter) SetStart(start time.Time, ts uint64) {
+	if o == nil || o.started {
+		return
+	}
+	o.anchor = wallClock(start)
+	if o.anchor.IsZero() {
+		o.anchor = time.Now()
+	}
+	o.firstTs = ts
+	o.started = true
+}
+
+func (o *OTLPExporter) wall(ts uint64) time.Time {
+	if ts < o.firstTs {
+		return o.anchor.Add(-time.Duration(o.firstTs - ts))
+	}
+	return o.anchor.Add(time.Duration(ts - o.firstTs))
+}
+
+// Span queues a span of one call and sends a batch once enough are queued.
+// Calls of a thread share a trace. A non-empty failure marks the span as an error.
+func (o *OTLPExporter) Span(name string, tid, startTs, endTs uint64,
+	attrs map[string]any, failure string) {
+	if o == nil {
+		return
+	}
+	o.SetStart(time.Time{}, startTs)
+	if endTs < startTs {
+		endTs = startTs
+	}
+	o.spanSeq++
+	span := &otlpSpan{
+		TraceID:           o.traceID(tid),
+		SpanID:            fmt.Sprintf("%016x", o.spanSeq),
+		Name:              name,
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: unixNano(o.wall(startTs)),
+		EndTimeUnixNano:   unixNano(o.wall(endTs)),
+		Attributes:        otlpAttributes(attrs),
+	}
+	if failure != "" {
+		span.Status = &otlpStatus{Code: otlpStatusError, Message: failure}
+	}
+	o.spans = append(o.spans, span)
+	if len(o.spans) >= otlpSpanBatch {
+		o.flushSpans()
+	}
+}
+
+// traceID derives a stable trace id from the run and the thread.
+func (o *OTLPExporter) traceID(tid uint64) string {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(o.run + "/" + strconv.FormatUint(tid, 10)))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (o *OTLPExporter) flushSpans() {
+	if len(o.spans) == 0 {
+		return
+	}
+	req := otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   o.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "bpfstream"}, Spans: o.spans}},
+	}}}
+	o.enqueue("traces", req)
+	o.spans = nil
+}
+
+// Counters sends the counts of the n-th interval (1-based) of a count
+// command as a delta sum named bpfstream.<subsystem>.ops, with the key as
+// the op attribute. start is the start time from the stream, if any.
+func (o *OTLPExporter) Counters(subsystem string, start time.Time, n int, values map[string]int64) {
+	if o == nil {
+		return
+	}
+	start = wallClock(start)
+	if start.IsZero() {
+		start = o.created
+	}
+	from, to := countInterval(start, o.interval, n)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	points := make([]otlpDataPoint, 0, len(keys))
+	for _, k := range keys {
+		points = append(points, otlpDataPoint{
+			Attributes:        otlpAttributes(map[string]any{"op": k}),
+			StartTimeUnixNano: unixNano(from),
+			TimeUnixNano:      unixNano(to),
+			AsInt:             strconv.FormatInt(values[k], 10),
+		})
+	}
+	req := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: o.resource,
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope: otlpScope{Name: "bpfstream"},
+			Metrics: []*otlpMetric{{
+				Name: "bpfstream." + subsystem + ".ops",
+				Unit: "{event}",
+				Sum: &otlpSum{
+					DataPoints:             points,
+					AggregationTemporality: otlpTemporalityDelta,
+					IsMonotonic:            true,
+				},
+			}},
+		}},
+	}}}
+	o.enqueue("metrics", req)
+}
+
+// Close sends the queued spans and waits up to otlpDrainTimeout for the queue
+// to drain.
+func (o *OTLPExporter) Close() {
+	if o == nil {
+		return
+	}
+	o.flushSpans()
+	close(o.queue)
+	timer := time.NewTimer(otlpDrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-o.done:
+	case <-timer.C:
+		log.Warn().Int("requests", len(o.queue)).Msg("OTLP collector too slow, dropping queued requests")
+		o.cancel()
+		<-o.done
+	}
+	o.cancel()
+	if o.Dropped > 0 {
+		log.Warn().Int64("requests", o.Dropped).Msg("OTLP send queue was full, requests dropped")
+	}
+}
+
+// enqueue hands a request to the sender. When the queue is full it waits for
+// room if the input is a file and drops the request otherwise.
+func (o *OTLPExporter) enqueue(signal string, payload any) {
+	if o.block {
+		o.queue <- otlpExport{signal: signal, payload: payload}
+		return
+	}
+	select {
+	case o.queue <- otlpExport{signal: signal, payload: payload}:
+	default:
+		if o.Dropped == 0 {
+			log.Warn().Str("signal", signal).Msg("OTLP send queue full, dropping requests")
+		}
+		o.Dropped++
+	}
+}
+
+func (o *OTLPExporter) sendLoop() {
+	defer close(o.done)
+	for e := range o.queue {
+		if o.protocol == "grpc" {
+			o.sendGRPC(e)
+		} else {
+			o.post("/v1/"+e.signal, e.payload)
+		}
+	}
+}
+
+// post sends one export request. Failures are logged and the data dropped, so
+// a collector outage never stops a capture.
+func (o *OTLPExporter) post(path string, payload any) {
+	url := o.endpoint + path
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to encode OTLP request")
+		return
+	}
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to create OTLP request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("OTLP export failed")
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Error().Int("status", resp.StatusCode).Str("url", url).Msg("OTLP collector returned error status")
+	}
+}
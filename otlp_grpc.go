@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OTLP/gRPC export methods of the collector services.
+var otlpGRPCPaths = map[string]string{
+	"traces":  "/opentelemetry.proto.collector.trace.v1.TraceService/Export",
+	"metrics": "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export",
+}
+
+// sendGRPC sends one export request as a unary gRPC call. Failures are logged
+// and the data dropped, like post.
+func (o *OTLPExporter) sendGRPC(e otlpExport) {
+	url := o.endpoint + otlpGRPCPaths[e.signal]
+	var p otlpProto
+	var msg []byte
+	switch payload := e.payload.(type) {
+	case otlpTracesRequest:
+		msg = p.traces(payload)
+	case otlpMetricsRequest:
+		msg = p.metrics(payload)
+	}
+	if p.err != nil {
+		log.Error().Err(p.err).Str("url", url).Msg("Failed to encode OTLP request")
+		return
+	}
+
+	// A gRPC message is prefixed by an uncompressed flag and its length.
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodPost, url, bytes.NewReader(frame))
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to create OTLP request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("OTLP export failed")
+		return
+	}
+	// Trailers are only available once the body is read.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Error().Int("status", resp.StatusCode).Str("url", url).Msg("OTLP collector returned error status")
+		return
+	}
+	// Errors without a response message come as headers only.
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		log.Error().Str("grpc_status", status).Str("message", message).Str("url", url).
+			Msg("OTLP collector returned error status")
+	}
+}
+
+// otlpProto encodes export requests in the protobuf wire format of the
+// OTLP protos. Fields are numbered after opentelemetry-proto; default values
+// are omitted like proto3 does. The first malformed number or id of the JSON
+// mapping is kept in err.
+type otlpProto struct {
+	err error
+}
+
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+)
+
+func pbAppendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func pbAppendTag(b []byte, field, wire int) []byte {
+	return pbAppendVarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func pbAppendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return pbAppendVarint(pbAppendTag(b, field, pbVarint), v)
+}
+
+func pbAppendFixed64(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.LittleEndian.AppendUint64(pbAppendTag(b, field, pbFixed64), v)
+}
+
+func pbAppendBytes(b []byte, field int, v []byte) []byte {
+	b = pbAppendVarint(pbAppendTag(b, field, pbBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbAppendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return pbAppendBytes(b, field, []byte(s))
+}
+
+func (p *otlpProto) uint(s string) uint64 {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+// int parses a signed number, wrapping unsigned ones above the int64 range
+// like the uint64 attributes they came from.
+func (p *otlpProto) int(s string) int64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		var u uint64
+		u, err = strconv.ParseUint(s, 10, 64)
+		v = int64(u)
+	}
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+func (p *otlpProto) id(s string) []byte {
+	v, err := hex.DecodeString(s)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
+
+// traces encodes an ExportTraceServiceRequest.
+func (p *otlpProto) traces(req otlpTracesRequest) []byte {
+	var b []byte
+	for _, rs := range req.ResourceSpans {
+		var m []byte
+		m = pbAppendBytes(m, 1, p.resource(rs.Resource))
+		for _, ss := range rs.ScopeSpans {
+			var sm []byte
+			sm = pbAppendBytes(sm, 1, pbAppendString(nil, 1, ss.Scope.Name))
+			for _, span := range ss.Spans {
+				sm = pbAppendBytes(sm, 2, p.span(span))
+			}
+			m = pbAppendBytes(m, 2, sm)
+		}
+		b = pbAppendBytes(b, 1, m)
+	}
+	return b
+}
+
+func (p *otlpProto) span(s *otlpSpan) []byte {
+	var b []byte
+	b = pbAppendBytes(b, 1, p.id(s.TraceID))
+	b = pbAppendBytes(b, 2, p.id(s.SpanID))
+	b = pbAppendString(b, 5, s.Name)
+	b = pbAppendUint(b, 6, uint64(s.Kind))
+	b = pbAppendFixed64(b, 7, p.uint(s.StartTimeUnixNano))
+	b = pbAppendFixed64(b, 8, p.uint(s.EndTimeUnixNano))
+	b = p.attributes(b, 9, s.Attributes)
+	if s.Status != nil {
+		var st []byte
+		st = pbAppendString(st, 2, s.Status.Message)
+		st = pbAppendUint(st, 3, uint64(s.Status.Code))
+		b = pbAppendBytes(b, 15, st)
+	}
+	return b
+}
+
+// metrics encodes an ExportMetricsServiceRequest.
+func (p *otlpProto) metrics(req otlpMetricsRequest) []byte {
+	var b []byte
+	for _, rm := range req.ResourceMetrics {
+		var m []byte
+		m = pbAppendBytes(m, 1, p.resource(rm.Resource))
+		for _, sm := range rm.ScopeMetrics {
+			var s []byte
+			s = pbAppendBytes(s, 1, pbAppendString(nil, 1, sm.Scope.Name))
+			for _, metric := range sm.Metrics {
+				s = pbAppendBytes(s, 2, p.metric(metric))
+			}
+			m = pbAppendBytes(m, 2, s)
+		}
+		b = pbAppendBytes(b, 1, m)
+	}
+	return b
+}
+
+func (p *otlpProto) metric(m *otlpMetric) []byte {
+	var b []byte
+	b = pbAppendString(b, 1, m.Name)
+	b = pbAppendString(b, 3, m.Unit)
+	if m.Sum != nil {
+		var sum []byte
+		for _, dp := range m.Sum.DataPoints {
+			var point []byte
+			point = pbAppendFixed64(point, 2, p.uint(dp.StartTimeUnixNano))
+			point = pbAppendFixed64(point, 3, p.uint(dp.TimeUnixNano))
+			// as_int is an sfixed64, always written so that a zero count is not
+			// mistaken for a point without a value.
+			point = binary.LittleEndian.AppendUint64(pbAppendTag(point, 6, pbFixed64), uint64(p.int(dp.AsInt)))
+			point = p.attributes(point, 7, dp.Attributes)
+			sum = pbAppendBytes(sum, 1, point)
+		}
+		sum = pbAppendUint(sum, 2, uint64(m.Sum.AggregationTemporality))
+		if m.Sum.IsMonotonic {
+			sum = pbAppendUint(sum, 3, 1)
+		}
+		b = pbAppendBytes(b, 7, sum)
+	}
+	return b
+}
+
+func (p *otlpProto) resource(r otlpResource) []byte {
+	return p.attributes(nil, 1, r.Attributes)
+}
+
+// attributes appends key-values as a repeated field.
+func (p *otlpProto) attributes(b []byte, field int, kvs []otlpKeyValue) []byte {
+	for _, kv := range kvs {
+		var value []byte
+		switch v := kv.Value; {
+		case v.StringValue != nil:
+			value = pbAppendBytes(value, 1, []byte(*v.StringValue))
+		case v.BoolValue != nil:
+			var bit uint64
+			if *v.BoolValue {
+				bit = 1
+			}
+			value = pbAppendVarint(pbAppendTag(value, 2, pbVarint), bit)
+		case v.IntValue != nil:
+			value = pbAppendVarint(pbAppendTag(value, 3, pbVarint), uint64(p.int(*v.IntValue)))
+		case v.DoubleValue != nil:
+			value = binary.LittleEndian.AppendUint64(pbAppendTag(value, 4, pbFixed64), math.Float64bits(*v.DoubleValue))
+		}
+		var m []byte
+		m = pbAppendString(m, 1, kv.Key)
+		m = pbAppendBytes(m, 2, value)
+		b = pbAppendBytes(b, field, m)
+	}
+	return b
+}
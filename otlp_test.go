@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// newTestOTLPExporter builds an exporter from command-line arguments.
+func newTestOTLPExporter(t *testing.T, args ...string) *OTLPExporter {
+	var o *OTLPExporter
+	cmd := &cli.Command{
+		Name: "test",
+		Flags: slices.Concat([]cli.Flag{
+			&cli.StringFlag{Name: "input", Value: "-"},
+		}, countFlags(), otlpFlags()),
+		Action: func(ctx context.Context, command *cli.Command) error {
+			var err error
+			o, err = NewOTLPExporter(command)
+			return err
+		},
+	}
+	if err := cmd.Run(context.Background(), append([]string{"test"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// TestOTLPExporter tests exporting spans and interval counters to an OTLP/HTTP receiver
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var traces []otlpTracesRequest
+	var metrics []otlpMetricsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var err error
+		switch r.URL.Path {
+		case "/v1/traces":
+			var req otlpTracesRequest
+			err = json.NewDecoder(r.Body).Decode(&req)
+			traces = append(traces, req)
+		case "/v1/metrics":
+			var req otlpMetricsRequest
+			err = json.NewDecoder(r.Body).Decode(&req)
+			metrics = append(metrics, req)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err != nil {
+			t.Errorf("failed to decode %s: %v", r.URL.Path, err)
+		}
+	}))
+	defer srv.Close()
+
+	if o := newTestOTLPExporter(t); o != nil {
+		t.Fatal("expected no exporter without an endpoint")
+	}
+	o := newTestOTLPExporter(t, "--otlp-endpoint", srv.URL+"/", "--otlp-run", "run-1", "--interval", "10s")
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	o.SetStart(start, 1000)
+	o.Span("openat", 7, 1000, 1500, map[string]any{"thread.id": uint64(7), "file.path": "/etc/hosts"}, "")
+	o.Span("read", 7, 2000, 2100, map[string]any{"syscall.ret": "-1 EAGAIN"}, "EAGAIN")
+	o.Span("read", 8, 3000, 3000, nil, "")
+	if len(o.spans) != 3 || len(o.queue) != 0 {
+		t.Fatal("spans queued before the batch was full")
+	}
+	o.Counters("vfs", start, 2, map[string]int64{"write": 5, "read": 3})
+	o.Close()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(traces) != 1 || len(traces[0].ResourceSpans) != 1 {
+		t.Fatalf("unexpected trace requests: %+v", traces)
+	}
+	rs := traces[0].ResourceSpans[0]
+	resource := map[string]string{}
+	for _, kv := range rs.Resource.Attributes {
+		resource[kv.Key] = *kv.Value.StringValue
+	}
+	if resource["bpfstream.run"] != "run-1" || resource["host.name"] == "" || resource["service.name"] != "bpfstream" {
+		t.Errorf("unexpected resource: %v", resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	openat := spans[0]
+	if openat.Name != "openat" || openat.StartTimeUnixNano != strconv.FormatInt(start.UnixNano(), 10) ||
+		openat.EndTimeUnixNano != strconv.FormatInt(start.UnixNano()+500, 10) || openat.Status != nil {
+		t.Errorf("unexpected openat span: %+v", openat)
+	}
+	if len(openat.Attributes) != 2 || openat.Attributes[0].Key != "file.path" ||
+		openat.Attributes[1].Key != "thread.id" || *openat.Attributes[1].Value.IntValue != "7" {
+		t.Errorf("unexpected openat attributes: %+v", openat.Attributes)
+	}
+	if spans[1].TraceID != openat.TraceID || spans[2].TraceID == openat.TraceID || len(openat.TraceID) != 32 {
+		t.Errorf("spans of a thread should share a trace: %s %s %s", openat.TraceID, spans[1].TraceID, spans[2].TraceID)
+	}
+	if spans[1].SpanID == openat.SpanID {
+		t.Error("span ids should be unique")
+	}
+	if spans[1].Status == nil || spans[1].Status.Code != otlpStatusError || spans[1].Status.Message != "EAGAIN" {
+		t.Errorf("unexpected status: %+v", spans[1].Status)
+	}
+
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 metrics request, got %d", len(metrics))
+	}
+	metric := metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	if metric.Name != "bpfstream.vfs.ops" || metric.Sum.AggregationTemporality != otlpTemporalityDelta || !metric.Sum.IsMonotonic {
+		t.Errorf("unexpected metric: %+v", metric)
+	}
+	points := metric.Sum.DataPoints
+	if len(points) != 2 || *points[0].Attributes[0].Value.StringValue != "read" || points[0].AsInt != "3" {
+		t.Fatalf("unexpected data points: %+v", points)
+	}
+	from := start.Add(10 * time.Second)
+	if points[0].StartTimeUnixNano != strconv.FormatInt(from.UnixNano(), 10) ||
+		points[0].TimeUnixNano != strconv.FormatInt(from.Add(10*time.Second).UnixNano(), 10) {
+		t.Errorf("unexpected interval bounds: %s %s", points[0].StartTimeUnixNano, points[0].TimeUnixNano)
+	}
+
+	var nilExporter *OTLPExporter
+	nilExporter.Span("read", 1, 0, 0, nil, "")
+	nilExporter.Counters("vfs", start, 1, map[string]int64{"read": 1})
+	nilExporter.Close()
+}
+
+// TestOTLPExporterQueueFull tests that requests are dropped instead of
+// blocking the caller while the collector is slow
+func TestOTLPExporterQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	o := newTestOTLPExporter(t, "--otlp-endpoint", srv.URL)
+	for n := range otlpQueueSize + 2 {
+		o.Counters("vfs", time.Time{}, n+1, map[string]int64{"read": 1})
+	}
+	if o.Dropped == 0 {
+		t.Error("expected requests to be dropped when the queue is full")
+	}
+	close(release)
+	o.Close()
+}
+
+// TestOTLPExporterFileInput tests that a file input waits for the collector
+// instead of dropping requests once the queue is full
+func TestOTLPExporterFileInput(t *testing.T) {
+	var mu sync.Mutex
+	var received int
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	o := newTestOTLPExporter(t, "--otlp-endpoint", srv.URL, "--input", "vfs.ndjson")
+	const n = otlpQueueSize + 2
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := range n {
+			o.Counters("vfs", time.Time{}, i+1, map[string]int64{"read": 1})
+		}
+	}()
+	for len(o.queue) < otlpQueueSize {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-sent
+	o.Close()
+
+	if o.Dropped != 0 {
+		t.Errorf("dropped %d requests", o.Dropped)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if received != n {
+		t.Errorf("received %d requests, want %d", received, n)
+	}
+}
+
+// pbField is one field of a protobuf message, decoded for the tests.
+type pbField struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// pbDecode splits a protobuf message into its fields.
+func pbDecode(t *testing.T, b []byte) []pbField {
+	t.Helper()
+	var fields []pbField
+	varint := func() uint64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid varint in %x", b)
+		}
+		b = b[n:]
+		return v
+	}
+	for len(b) > 0 {
+		tag := varint()
+		f := pbField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case pbVarint:
+			f.value = varint()
+		case pbFixed64:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case pbBytes:
+			n := varint()
+			f.bytes = b[:n]
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// pbGet returns the fields numbered num.
+func pbGet(t *testing.T, b []byte, num int) []pbField {
+	t.Helper()
+	var fields []pbField
+	for _, f := range pbDecode(t, b) {
+		if f.num == num {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// TestOTLPExporterGRPC tests exporting spans and counters over OTLP/gRPC
+func TestOTLPExporterGRPC(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string][]byte{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("unexpected request: %s %s", r.Proto, r.Header.Get("Content-Type"))
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+			t.Errorf("invalid gRPC frame %x: %v", body, err)
+			return
+		}
+		requests[r.URL.Path] = body[5:]
+		w.Header().Set("Content-Type", "application/grpc")
+		_, _ = w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	cmd := &cli.Command{
+		Name:  "test",
+		Flags: otlpFlags(),
+		Action: func(ctx context.Context, command *cli.Command) error {
+			_, err := NewOTLPExporter(command)
+			return err
+		},
+	}
+	if err := cmd.Run(context.Background(), []string{"test", "--otlp-endpoint", srv.URL, "--otlp-protocol", "zipkin"}); err == nil {
+		t.Error("expected an error for an unknown protocol")
+	}
+	o := newTestOTLPExporter(t, "--otlp-endpoint", srv.URL, "--otlp-protocol", "grpc", "--interval", "10s")
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	o.SetStart(start, 1000)
+	o.Span("openat", 7, 1000, 1500, map[string]any{"thread.id": uint64(7)}, "")
+	o.Span("read", 7, 2000, 2100, nil, "EAGAIN")
+	o.Counters("vfs", start, 1, map[string]int64{"read": 3})
+	o.Close()
+	mu.Lock()
+	defer mu.Unlock()
+
+	traces := requests["/opentelemetry.proto.collector.trace.v1.TraceService/Export"]
+	resourceSpans := pbGet(t, traces, 1)
+	if len(resourceSpans) != 1 {
+		t.Fatalf("expected 1 resource spans, got %d", len(resourceSpans))
+	}
+	spans := pbGet(t, pbGet(t, resourceSpans[0].bytes, 2)[0].bytes, 2)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	openat := spans[0].bytes
+	if name := string(pbGet(t, openat, 5)[0].bytes); name != "openat" {
+		t.Errorf("span name = %q", name)
+	}
+	if id := pbGet(t, openat, 1)[0].bytes; len(id) != 16 {
+		t.Errorf("trace id = %x", id)
+	}
+	if ts := pbGet(t, openat, 7)[0].value; ts != uint64(start.UnixNano()) {
+		t.Errorf("start = %d, want %d", ts, start.UnixNano())
+	}
+	attr := pbGet(t, openat, 9)[0].bytes
+	if key := string(pbGet(t, attr, 1)[0].bytes); key != "thread.id" {
+		t.Errorf("attribute key = %q", key)
+	}
+	if v := pbGet(t, pbGet(t, attr, 2)[0].bytes, 3)[0].value; v != 7 {
+		t.Errorf("thread.id = %d", v)
+	}
+	status := pbGet(t, spans[1].bytes, 15)[0].bytes
+	if code := pbGet(t, status, 3)[0].value; code != otlpStatusError {
+		t.Errorf("status code = %d", code)
+	}
+
+	metrics := requests["/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"]
+	metric := pbGet(t, pbGet(t, pbGet(t, metrics, 1)[0].bytes, 2)[0].bytes, 2)[0].bytes
+	if name := string(pbGet(t, metric, 1)[0].bytes); name != "bpfstream.vfs.ops" {
+		t.Errorf("metric name = %q", name)
+	}
+	sum := pbGet(t, metric, 7)[0].bytes
+	if temporality := pbGet(t, sum, 2)[0].value; temporality != otlpTemporalityDelta {
+		t.Errorf("temporality = %d", temporality)
+	}
+	point := pbGet(t, sum, 1)[0].bytes
+	if count := pbGet(t, point, 6)[0].value; count != 3 {
+		t.Errorf("count = %d", count)
+	}
+}
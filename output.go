@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
 )
 
 // OutputFormat represents supported output formats.
@@ -17,6 +21,9 @@ const (
 	FormatTable OutputFormat = "table"
 	FormatJSON  OutputFormat = "json"
 	FormatCSV   OutputFormat = "csv"
+
+	// FormatFolded is the folded-stack format read by flamegraph.pl and speedscope.
+	FormatFolded OutputFormat = "folded"
 )
 
 // ValidateFormat checks if the format string is valid.
@@ -29,6 +36,124 @@ func ValidateFormat(format string) error {
 	}
 }
 
+// ValidateCountFormat checks if the format string is valid for count commands,
+// which additionally support folded stacks and InfluxDB line protocol.
+func ValidateCountFormat(format string) error {
+	switch format {
+	case "table", "json", "csv", "folded", "influx":
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s (must be table, json, csv, folded, or influx)", format)
+	}
+}
+
+// countFlags returns the flags shared by all count commands. The interval is
+// used by alert rates, OTLP metrics and influx timestamps.
+func countFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "interval",
+			Value: time.Second,
+			Usage: "length of one interval of the bpftrace script, used for per-second rates and timestamps",
+		},
+	}
+}
+
+// keyParts returns the number of parts of the composite keys of a bpftrace map.
+// bpftrace joins the parts of a key with commas and every key of a map has the
+// same number of parts, so commas beyond the fewest found belong to a part.
+func keyParts(keys []string) int {
+	parts := 0
+	for _, k := range keys {
+		n := strings.Count(k, ",") + 1
+		if parts == 0 || n < parts {
+			parts = n
+		}
+	}
+	return parts
+}
+
+// foldKey turns a bpftrace map key such as "comm,probe,path" into a folded stack
+// of at most parts frames; the last frame keeps any further commas. Frames are
+// separated by ';', so semicolons inside a frame are replaced.
+func foldKey(key string, parts int) string {
+	frames := strings.SplitN(key, ",", parts)
+	for i, f := range frames {
+		frames[i] = strings.ReplaceAll(strings.TrimSpace(f), ";", ":")
+	}
+	return strings.Join(frames, ";")
+}
+
+// otherCounts holds the keys of a count map beyond the known operations, such
+// as per-address or comm,probe,path keys. They may count the known operations
+// again, so they are kept out of totals.
+type otherCounts map[string]int64
+
+// Set records the count of a key.
+func (o *otherCounts) Set(key string, value int64) {
+	if *o == nil {
+		*o = make(otherCounts)
+	}
+	(*o)[key] = value
+}
+
+// Add accumulates the counts of another interval.
+func (o *otherCounts) Add(other otherCounts) {
+	for k, v := range other {
+		if *o == nil {
+			*o = make(otherCounts)
+		}
+		(*o)[k] += v
+	}
+}
+
+// Sorted returns the keys sorted by count descending.
+func (o otherCounts) Sorted() []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if o[keys[i]] != o[keys[j]] {
+			return o[keys[i]] > o[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// writeCSV appends the keys as Operation,Count rows.
+func (o otherCounts) writeCSV(w *csv.Writer) {
+	for _, k := range o.Sorted() {
+		_ = w.Write([]string{k, fmt.Sprintf("%d", o[k])})
+	}
+}
+
+// writeTable prints the keys as a separate block after the known operations.
+func (o otherCounts) writeTable(tw *tabwriter.Writer) {
+	if len(o) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(tw, "\nOther\tCount")
+	_, _ = fmt.Fprintln(tw, "-----\t-----")
+	for _, k := range o.Sorted() {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", k, o[k])
+	}
+}
+
+// createOutput opens the file named by an --output flag, or returns stdout when
+// name is empty, along with the function that closes it.
+func createOutput(name string) (io.Writer, func() error, error) {
+	if name == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create output: %w", err)
+	}
+	return f, f.Close, nil
+}
+
 // CountData represents a key-value pair for count output.
 type CountData struct {
 	Key   string
@@ -79,3 +204,29 @@ func (o *CountOutput) PrintCSV(header string, data []CountData) {
 	}
 	w.Flush()
 }
+
+// PrintFolded prints one "frame;frame count" line per non-zero value, sorted by stack.
+func (o *CountOutput) PrintFolded(values map[string]int64) {
+	keys := make([]string, 0, len(values))
+	for k, v := range values {
+		if v != 0 {
+			keys = append(keys, k)
+		}
+	}
+	parts := keyParts(keys)
+
+	stacks := make([]string, 0, len(keys))
+	counts := make(map[string]int64, len(keys))
+	for _, k := range keys {
+		v := values[k]
+		stack := foldKey(k, parts)
+		if _, ok := counts[stack]; !ok {
+			stacks = append(stacks, stack)
+		}
+		counts[stack] += v
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		_, _ = fmt.Fprintf(o.writer, "%s %d\n", stack, counts[stack])
+	}
+}
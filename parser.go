@@ -17,6 +17,21 @@ import (
 // The handler receives the message type and data element, returning an error if processing fails.
 type MessageHandler func(msgType string, data *simdjson.Element) error
 
+// printfHook inspects a printf payload before it is parsed as a row.
+// It returns true when it consumed the payload and no row should be appended.
+type printfHook func(buf []byte) (bool, error)
+
+// runPrintfHooks reports whether any hook consumed the payload.
+func runPrintfHooks(hooks []printfHook, buf []byte) (bool, error) {
+	for _, hook := range hooks {
+		handled, err := hook(buf)
+		if err != nil || handled {
+			return handled, err
+		}
+	}
+	return false, nil
+}
+
 // NDJSONParser provides a common framework for parsing bpftrace NDJSON output.
 type NDJSONParser struct {
 	StartTime time.Time
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// FormatInflux prints every interval of a count command as InfluxDB line protocol.
+const FormatInflux OutputFormat = "influx"
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// InfluxOutput prints the intervals of a count command as line protocol, one
+// line per key, stamped with the end of the interval:
+//
+//	bpfstream_vfs,host=web-1,op=vfs_read count=5i 1767236645000000000
+type InfluxOutput struct {
+	writer      io.Writer
+	measurement string
+	host        string
+	interval    time.Duration
+	created     time.Time
+}
+
+// NewInfluxOutput creates an InfluxOutput writing to stdout when the format
+// flag is influx, and returns nil otherwise. The interval must be positive to
+// stamp intervals apart.
+func NewInfluxOutput(command *cli.Command, subsystem string) (*InfluxOutput, error) {
+	if command.String("format") != string(FormatInflux) {
+		return nil, nil
+	}
+	interval := command.Duration("interval")
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid --interval %s: must be positive for influx output", interval)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("get hostname: %w", err)
+	}
+	return &InfluxOutput{
+		writer:      os.Stdout,
+		measurement: "bpfstream_" + subsystem,
+		host:        host,
+		interval:    interval,
+		created:     time.Now(),
+	}, nil
+}
+
+// Print writes the counts of the n-th interval (1-based). start is the start
+// time from the stream, if any; intervals count from the command start otherwise.
+func (o *InfluxOutput) Print(start time.Time, n int, values map[string]int64) {
+	start = wallClock(start)
+	if start.IsZero() {
+		start = o.created
+	}
+	_, ts := countInterval(start, o.interval, n)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	prefix := influxEscaper.Replace(o.measurement) + ",host=" + influxEscaper.Replace(o.host) + ",op="
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(o.writer, "%s%s count=%di %d\n", prefix, influxEscaper.Replace(k), values[k], ts.UnixNano())
+	}
+}
+
+// statsdMaxPacket keeps datagrams below the common 1500 byte MTU.
+const statsdMaxPacket = 1432
+
+var statsdUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// statsdFlags returns the flags of the count commands that push to StatsD.
+func statsdFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "statsd",
+			Usage: "StatsD server to send the counts of every interval to, e.g. udp://127.0.0.1:8125",
+		},
+	}
+}
+
+// StatsDSink sends the counts of every interval as StatsD counters named
+// bpfstream.<subsystem>.<op>. Counts of an interval are already deltas.
+type StatsDSink struct {
+	conn net.Conn
+}
+
+// NewStatsDSink creates a StatsDSink from the flags added by statsdFlags.
+// It returns nil when no server is configured.
+func NewStatsDSink(command *cli.Command) (*StatsDSink, error) {
+	addr := command.String("statsd")
+	if addr == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != "udp" || u.Host == "" {
+		return nil, fmt.Errorf("invalid StatsD address %q: must be udp://host:port", addr)
+	}
+	conn, err := net.Dial("udp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dial StatsD: %w", err)
+	}
+	return &StatsDSink{conn: conn}, nil
+}
+
+// Send sends the non-zero counts of one interval, packing as many lines into
+// a datagram as fit. Send errors are logged and the counts dropped.
+func (s *StatsDSink) Send(subsystem string, values map[string]int64) {
+	if s == nil {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for k, v := range values {
+		if v != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var packet []byte
+	for _, k := range keys {
+		line := "bpfstream." + subsystem + "." + statsdName(k) + ":" + strconv.FormatInt(values[k], 10) + "|c"
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			s.write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		s.write(packet)
+	}
+}
+
+func (s *StatsDSink) write(packet []byte) {
+	if _, err := s.conn.Write(packet); err != nil {
+		log.Error().Err(err).Str("addr", s.conn.RemoteAddr().String()).Msg("StatsD send failed")
+	}
+}
+
+// Close closes the socket.
+func (s *StatsDSink) Close() {
+	if s == nil {
+		return
+	}
+	_ = s.conn.Close()
+}
+
+// statsdName turns a map key into a metric name component. StatsD reserves
+// ':', '|' and '@', and '.' separates levels of the name.
+func statsdName(key string) string {
+	name := strings.Trim(statsdUnsafe.ReplaceAllString(key, "_"), "_")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
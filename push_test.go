@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// TestInfluxOutput tests printing an interval as line protocol stamped with the interval end
+func TestInfluxOutput(t *testing.T) {
+	var buf bytes.Buffer
+	o := &InfluxOutput{writer: &buf, measurement: "bpfstream_net", host: "web 1", interval: 10 * time.Second}
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	o.Print(start, 2, map[string]int64{"tcp_sendmsg": 7, "curl,db:443": 2})
+
+	ts := start.Add(20 * time.Second).UnixNano()
+	expected := "bpfstream_net,host=web\\ 1,op=curl\\,db:443 count=2i " + strconv.FormatInt(ts, 10) + "\n" +
+		"bpfstream_net,host=web\\ 1,op=tcp_sendmsg count=7i " + strconv.FormatInt(ts, 10) + "\n"
+	if buf.String() != expected {
+		t.Errorf("influx output = %q, want %q", buf.String(), expected)
+	}
+	if err := ValidateCountFormat("influx"); err != nil {
+		t.Error(err)
+	}
+
+	for _, interval := range []string{"0s", "-1s"} {
+		cmd := &cli.Command{
+			Name:  "test",
+			Flags: slices.Concat([]cli.Flag{&cli.StringFlag{Name: "format"}}, countFlags()),
+			Action: func(ctx context.Context, command *cli.Command) error {
+				_, err := NewInfluxOutput(command, "vfs")
+				return err
+			},
+		}
+		if err := cmd.Run(context.Background(), []string{"test", "--format", "influx", "--interval", interval}); err == nil {
+			t.Errorf("expected an error for --interval %s", interval)
+		}
+	}
+}
+
+// TestStatsDSink tests sending the non-zero counts of an interval to a UDP listener
+func TestStatsDSink(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &StatsDSink{conn: conn}
+	defer s.Close()
+
+	s.Send("vfs", map[string]int64{"vfs_read": 5, "vfs_write": 0, "postgres,vfs_fsync": 2})
+
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, statsdMaxPacket)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "bpfstream.vfs.postgres_vfs_fsync:2|c\nbpfstream.vfs.vfs_read:5|c"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("statsd packet = %q, want %q", got, expected)
+	}
+
+	values := make(map[string]int64)
+	for i := range 200 {
+		values["op_"+strconv.Itoa(i)] = 1
+	}
+	s.Send("syscall", values)
+	var lines int
+	for lines < len(values) {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > statsdMaxPacket {
+			t.Errorf("packet of %d bytes exceeds the limit", n)
+		}
+		lines += strings.Count(string(buf[:n]), "\n") + 1
+	}
+	if lines != len(values) {
+		t.Errorf("received %d lines, want %d", lines, len(values))
+	}
+
+	var nilSink *StatsDSink
+	nilSink.Send("vfs", values)
+	nilSink.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// durationSummary holds latency percentiles of a set of samples.
+type durationSummary struct {
+	Count int64         `json:"count"`
+	Total time.Duration `json:"total_ns"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+}
+
+// Avg returns the mean of the samples.
+func (s durationSummary) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// summarizeDurations sorts samples in place and computes their summary.
+func summarizeDurations(samples []time.Duration) durationSummary {
+	s := durationSummary{Count: int64(len(samples))}
+	if len(samples) == 0 {
+		return s
+	}
+	slices.Sort(samples)
+	for _, d := range samples {
+		s.Total += d
+	}
+	s.P50 = percentile(samples, 50)
+	s.P90 = percentile(samples, 90)
+	s.P99 = percentile(samples, 99)
+	s.Max = samples[len(samples)-1]
+	return s
+}
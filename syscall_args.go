@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// syscallArg is one decoded syscall argument. Value is an int64 for fds and
+// signed integers, a uint64 for sizes and counts, or a string for pointers
+// and symbolic flags.
+type syscallArg struct {
+	Name  string
+	Value any
+}
+
+// syscallArgs holds the decoded arguments of a syscall in call order.
+type syscallArgs []syscallArg
+
+// MarshalJSON writes the arguments as a JSON object, keeping call order.
+func (a syscallArgs) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, arg := range a {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(arg.Name)
+		value, err := json.Marshal(arg.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// String renders the arguments the way strace prints them.
+func (a syscallArgs) String() string {
+	parts := make([]string, len(a))
+	for i, arg := range a {
+		parts[i] = fmt.Sprint(arg.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// syscallFlag names a bit mask. Masks with several bits are matched before
+// their parts, so composite flags such as O_SYNC must come first.
+type syscallFlag struct {
+	Mask uint64
+	Name string
+}
+
+// formatFlags renders v as NAME|NAME, with leftover bits in hex.
+func formatFlags(v uint64, flags []syscallFlag) string {
+	var parts []string
+	for _, f := range flags {
+		if v&f.Mask == f.Mask {
+			parts = append(parts, f.Name)
+			v &^= f.Mask
+		}
+	}
+	if v != 0 || len(parts) == 0 {
+		parts = append(parts, "0x"+strconv.FormatUint(v, 16))
+	}
+	return strings.Join(parts, "|")
+}
+
+const (
+	atFdcwd  = -100
+	oAccmode = 0o3
+	oCreat   = 0o100
+)
+
+var openAccessModes = []string{"O_RDONLY", "O_WRONLY", "O_RDWR", "O_ACCMODE"}
+
+// openFlagsGeneric are the open flags shared by x86_64 and arm64.
+var openFlagsGeneric = []syscallFlag{
+	{0o4010000, "O_SYNC"},
+	{0o100, "O_CREAT"},
+	{0o200, "O_EXCL"},
+	{0o400, "O_NOCTTY"},
+	{0o1000, "O_TRUNC"},
+	{0o2000, "O_APPEND"},
+	{0o4000, "O_NONBLOCK"},
+	{0o10000, "O_DSYNC"},
+	{0o20000, "O_ASYNC"},
+	{0o1000000, "O_NOATIME"},
+	{0o2000000, "O_CLOEXEC"},
+	{0o10000000, "O_PATH"},
+}
+
+// openFlagsX86_64 and openFlagsArm64 add the flags whose values differ
+// between the two architectures.
+var (
+	openFlagsX86_64 = append([]syscallFlag{
+		{0o20200000, "O_TMPFILE"},
+		{0o40000, "O_DIRECT"},
+		{0o100000, "O_LARGEFILE"},
+		{0o200000, "O_DIRECTORY"},
+		{0o400000, "O_NOFOLLOW"},
+	}, openFlagsGeneric...)
+	openFlagsArm64 = append([]syscallFlag{
+		{0o20040000, "O_TMPFILE"},
+		{0o40000, "O_DIRECTORY"},
+		{0o100000, "O_NOFOLLOW"},
+		{0o200000, "O_DIRECT"},
+		{0o400000, "O_LARGEFILE"},
+	}, openFlagsGeneric...)
+)
+
+var mmapProtFlags = []syscallFlag{
+	{0x1, "PROT_READ"},
+	{0x2, "PROT_WRITE"},
+	{0x4, "PROT_EXEC"},
+}
+
+var mmapFlagsGeneric = []syscallFlag{
+	{0x3, "MAP_SHARED_VALIDATE"},
+	{0x1, "MAP_SHARED"},
+	{0x2, "MAP_PRIVATE"},
+	{0x10, "MAP_FIXED"},
+	{0x20, "MAP_ANONYMOUS"},
+	{0x100, "MAP_GROWSDOWN"},
+	{0x800, "MAP_DENYWRITE"},
+	{0x1000, "MAP_EXECUTABLE"},
+	{0x2000, "MAP_LOCKED"},
+	{0x4000, "MAP_NORESERVE"},
+	{0x8000, "MAP_POPULATE"},
+	{0x10000, "MAP_NONBLOCK"},
+	{0x20000, "MAP_STACK"},
+	{0x40000, "MAP_HUGETLB"},
+	{0x80000, "MAP_SYNC"},
+	{0x100000, "MAP_FIXED_NOREPLACE"},
+}
+
+var mmapFlagsX86_64 = append([]syscallFlag{{0x40, "MAP_32BIT"}}, mmapFlagsGeneric...)
+
+var cloneFlags = []syscallFlag{
+	{0x100, "CLONE_VM"},
+	{0x200, "CLONE_FS"},
+	{0x400, "CLONE_FILES"},
+	{0x800, "CLONE_SIGHAND"},
+	{0x1000, "CLONE_PIDFD"},
+	{0x2000, "CLONE_PTRACE"},
+	{0x4000, "CLONE_VFORK"},
+	{0x8000, "CLONE_PARENT"},
+	{0x10000, "CLONE_THREAD"},
+	{0x20000, "CLONE_NEWNS"},
+	{0x40000, "CLONE_SYSVSEM"},
+	{0x80000, "CLONE_SETTLS"},
+	{0x100000, "CLONE_PARENT_SETTID"},
+	{0x200000, "CLONE_CHILD_CLEARTID"},
+	{0x400000, "CLONE_DETACHED"},
+	{0x800000, "CLONE_UNTRACED"},
+	{0x1000000, "CLONE_CHILD_SETTID"},
+	{0x2000000, "CLONE_NEWCGROUP"},
+	{0x4000000, "CLONE_NEWUTS"},
+	{0x8000000, "CLONE_NEWIPC"},
+	{0x10000000, "CLONE_NEWUSER"},
+	{0x20000000, "CLONE_NEWPID"},
+	{0x40000000, "CLONE_NEWNET"},
+	{0x80000000, "CLONE_IO"},
+}
+
+// signalNames covers the signals that show up as clone exit signals.
+var signalNames = map[uint64]string{
+	1: "SIGHUP", 2: "SIGINT", 9: "SIGKILL", 10: "SIGUSR1", 12: "SIGUSR2",
+	14: "SIGALRM", 15: "SIGTERM", 17: "SIGCHLD",
+}
+
+var futexOps = []string{
+	"FUTEX_WAIT", "FUTEX_WAKE", "FUTEX_FD", "FUTEX_REQUEUE", "FUTEX_CMP_REQUEUE",
+	"FUTEX_WAKE_OP", "FUTEX_LOCK_PI", "FUTEX_UNLOCK_PI", "FUTEX_TRYLOCK_PI",
+	"FUTEX_WAIT_BITSET", "FUTEX_WAKE_BITSET", "FUTEX_WAIT_REQUEUE_PI",
+	"FUTEX_CMP_REQUEUE_PI", "FUTEX_LOCK_PI2",
+}
+
+const (
+	futexPrivateFlag   = 128
+	futexClockRealtime = 256
+)
+
+// ioctlRequests names the tty and file ioctls common to x86_64 and arm64.
+var ioctlRequests = map[uint64]string{
+	0x5401: "TCGETS",
+	0x5402: "TCSETS",
+	0x5403: "TCSETSW",
+	0x5404: "TCSETSF",
+	0x540f: "TIOCGPGRP",
+	0x5410: "TIOCSPGRP",
+	0x5413: "TIOCGWINSZ",
+	0x5414: "TIOCSWINSZ",
+	0x541b: "FIONREAD",
+	0x5421: "FIONBIO",
+	0x5450: "FIONCLEX",
+	0x5451: "FIOCLEX",
+	0x5452: "FIOASYNC",
+}
+
+var ioctlDirs = []string{"_IOC_NONE", "_IOC_WRITE", "_IOC_READ", "_IOC_READ|_IOC_WRITE"}
+
+// syscallArgDecoder turns raw syscall arguments into named, typed values.
+type syscallArgDecoder struct {
+	openFlags []syscallFlag
+	oTmpfile  uint64
+	mmapFlags []syscallFlag
+	// cloneTLSFirst is set where clone takes tls before child_tid (arm64).
+	cloneTLSFirst bool
+}
+
+// newSyscallArgDecoder creates a decoder for the flag values and argument
+// order of arch.
+func newSyscallArgDecoder(arch string) (*syscallArgDecoder, error) {
+	arch, err := normalizeSyscallArch(arch)
+	if err != nil {
+		return nil, err
+	}
+	if arch == "arm64" {
+		return &syscallArgDecoder{
+			openFlags:     openFlagsArm64,
+			oTmpfile:      0o20040000,
+			mmapFlags:     mmapFlagsGeneric,
+			cloneTLSFirst: true,
+		}, nil
+	}
+	return &syscallArgDecoder{
+		openFlags: openFlagsX86_64,
+		oTmpfile:  0o20200000,
+		mmapFlags: mmapFlagsX86_64,
+	}, nil
+}
+
+// syscallArgDecoderFromFlags creates a decoder for the architecture selected by
+// the flag added by syscallArchFlag. It returns nil, which decodes nothing, when
+// the flag is unset and the host architecture is not supported.
+func syscallArgDecoderFromFlags(command *cli.Command) (*syscallArgDecoder, error) {
+	arch, err := syscallArch(command)
+	if err != nil || arch == "" {
+		return nil, err
+	}
+	return newSyscallArgDecoder(arch)
+}
+
+// Decode returns the decoded arguments of the named syscall, or nil when the
+// syscall has no decoder.
+func (d *syscallArgDecoder) Decode(name string, args [6]uint64) syscallArgs {
+	if d == nil {
+		return nil
+	}
+	switch name {
+	case "openat":
+		return append(syscallArgs{
+			{"dfd", argDirFd(args[0])},
+			{"filename", argPointer(args[1])},
+		}, d.openFlagsAndMode(args[2], args[3])...)
+	case "open":
+		return append(syscallArgs{
+			{"filename", argPointer(args[0])},
+		}, d.openFlagsAndMode(args[1], args[2])...)
+	case "read", "write":
+		return syscallArgs{
+			{"fd", argFd(args[0])},
+			{"buf", argPointer(args[1])},
+			{"count", args[2]},
+		}
+	case "pread64", "pwrite64":
+		return syscallArgs{
+			{"fd", argFd(args[0])},
+			{"buf", argPointer(args[1])},
+			{"count", args[2]},
+			{"pos", int64(args[3])},
+		}
+	case "close":
+		return syscallArgs{{"fd", argFd(args[0])}}
+	case "mmap":
+		return syscallArgs{
+			{"addr", argPointer(args[0])},
+			{"length", args[1]},
+			{"prot", d.mmapProt(args[2])},
+			{"flags", formatFlags(args[3], d.mmapFlags)},
+			{"fd", argFd(args[4])},
+			{"offset", args[5]},
+		}
+	case "clone":
+		decoded := syscallArgs{
+			{"flags", cloneFlagsString(args[0])},
+			{"newsp", argPointer(args[1])},
+			{"parent_tid", argPointer(args[2])},
+		}
+		if d.cloneTLSFirst {
+			return append(decoded, syscallArg{"tls", argPointer(args[3])}, syscallArg{"child_tid", argPointer(args[4])})
+		}
+		return append(decoded, syscallArg{"child_tid", argPointer(args[3])}, syscallArg{"tls", argPointer(args[4])})
+	case "futex":
+		return syscallArgs{
+			{"uaddr", argPointer(args[0])},
+			{"op", futexOpString(args[1])},
+			{"val", int64(int32(args[2]))},
+			{"timeout", argPointer(args[3])},
+			{"uaddr2", argPointer(args[4])},
+			{"val3", int64(int32(args[5]))},
+		}
+	case "ioctl":
+		return syscallArgs{
+			{"fd", argFd(args[0])},
+			{"request", ioctlRequestString(args[1])},
+			{"arg", argPointer(args[2])},
+		}
+	}
+	return nil
+}
+
+// openFlagsAndMode decodes open flags and, when a file may be created, the mode.
+func (d *syscallArgDecoder) openFlagsAndMode(flags, mode uint64) syscallArgs {
+	decoded := syscallArgs{{"flags", d.openFlagsString(flags)}}
+	if flags&oCreat != 0 || flags&d.oTmpfile == d.oTmpfile {
+		decoded = append(decoded, syscallArg{"mode", fmt.Sprintf("%#o", mode)})
+	}
+	return decoded
+}
+
+func (d *syscallArgDecoder) openFlagsString(v uint64) string {
+	s := openAccessModes[v&oAccmode]
+	if rest := v &^ oAccmode; rest != 0 {
+		s += "|" + formatFlags(rest, d.openFlags)
+	}
+	return s
+}
+
+func (d *syscallArgDecoder) mmapProt(v uint64) string {
+	if v == 0 {
+		return "PROT_NONE"
+	}
+	return formatFlags(v, mmapProtFlags)
+}
+
+func cloneFlagsString(v uint64) string {
+	sig := v & 0xff
+	var parts []string
+	if rest := v &^ 0xff; rest != 0 {
+		parts = append(parts, formatFlags(rest, cloneFlags))
+	}
+	if sig != 0 {
+		name, ok := signalNames[sig]
+		if !ok {
+			name = strconv.FormatUint(sig, 10)
+		}
+		parts = append(parts, name)
+	}
+	if len(parts) == 0 {
+		return "0"
+	}
+	return strings.Join(parts, "|")
+}
+
+func futexOpString(v uint64) string {
+	cmd := v &^ (futexPrivateFlag | futexClockRealtime)
+	var s string
+	if cmd < uint64(len(futexOps)) {
+		s = futexOps[cmd]
+	} else {
+		s = strconv.FormatUint(cmd, 10)
+	}
+	if v&futexPrivateFlag != 0 {
+		s += "_PRIVATE"
+	}
+	if v&futexClockRealtime != 0 {
+		s += "|FUTEX_CLOCK_REALTIME"
+	}
+	return s
+}
+
+// ioctlRequestString names well-known requests and otherwise splits the
+// request into its _IOC(dir, type, nr, size) fields.
+func ioctlRequestString(v uint64) string {
+	v &= 0xffffffff
+	if name, ok := ioctlRequests[v]; ok {
+		return name
+	}
+	dir := v >> 30
+	size := (v >> 16) & 0x3fff
+	typ := (v >> 8) & 0xff
+	nr := v & 0xff
+	return fmt.Sprintf("_IOC(%s, %#x, %#x, %#x)", ioctlDirs[dir], typ, nr, size)
+}
+
+// argFd returns a file descriptor argument as a signed int.
+func argFd(v uint64) int64 {
+	return int64(int32(v))
+}
+
+// argDirFd is argFd with AT_FDCWD spelled out.
+func argDirFd(v uint64) any {
+	if argFd(v) == atFdcwd {
+		return "AT_FDCWD"
+	}
+	return argFd(v)
+}
+
+func argPointer(v uint64) string {
+	if v == 0 {
+		return "NULL"
+	}
+	return "0x" + strconv.FormatUint(v, 16)
+}
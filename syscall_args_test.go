@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestSyscallArgDecoder tests decoding common syscall arguments on both architectures
+func TestSyscallArgDecoder(t *testing.T) {
+	x86, err := newSyscallArgDecoder("x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm, err := newSyscallArgDecoder("aarch64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		decoder *syscallArgDecoder
+		syscall string
+		args    [6]uint64
+		want    string
+	}{
+		{"openat cwd", x86, "openat", [6]uint64{^uint64(99), 0x7ffd1000, 0o2000000}, "AT_FDCWD, 0x7ffd1000, O_RDONLY|O_CLOEXEC"},
+		{"openat create", x86, "openat", [6]uint64{3, 0x1000, 0o1101, 0o644}, "3, 0x1000, O_WRONLY|O_CREAT|O_TRUNC, 0644"},
+		{"openat directory x86_64", x86, "openat", [6]uint64{3, 0x1000, 0o200000}, "3, 0x1000, O_RDONLY|O_DIRECTORY"},
+		{"openat directory arm64", arm, "openat", [6]uint64{3, 0x1000, 0o40000}, "3, 0x1000, O_RDONLY|O_DIRECTORY"},
+		{"openat sync", x86, "openat", [6]uint64{3, 0x1000, 0o4010002}, "3, 0x1000, O_RDWR|O_SYNC"},
+		{"read", x86, "read", [6]uint64{5, 0x2000, 4096}, "5, 0x2000, 4096"},
+		{"mmap anon", x86, "mmap", [6]uint64{0, 8192, 3, 0x22, 0xffffffff, 0}, "NULL, 8192, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS, -1, 0"},
+		{"mmap none", arm, "mmap", [6]uint64{0, 4096, 0, 0x40022, 0xffffffff, 0}, "NULL, 4096, PROT_NONE, MAP_PRIVATE|MAP_ANONYMOUS|MAP_HUGETLB, -1, 0"},
+		{"clone thread", x86, "clone", [6]uint64{0x3d0f00, 0x7f00, 0x7f10, 0x7f10, 0x7f20}, "CLONE_VM|CLONE_FS|CLONE_FILES|CLONE_SIGHAND|CLONE_THREAD|CLONE_SYSVSEM|CLONE_SETTLS|CLONE_PARENT_SETTID|CLONE_CHILD_CLEARTID, 0x7f00, 0x7f10, 0x7f10, 0x7f20"},
+		{"clone fork", arm, "clone", [6]uint64{0x1200011}, "CLONE_CHILD_CLEARTID|CLONE_CHILD_SETTID|SIGCHLD, NULL, NULL, NULL, NULL"},
+		{"futex", x86, "futex", [6]uint64{0x5000, 0x80, 2}, "0x5000, FUTEX_WAIT_PRIVATE, 2, NULL, NULL, 0"},
+		{"ioctl named", x86, "ioctl", [6]uint64{1, 0x5413, 0x7ff0}, "1, TIOCGWINSZ, 0x7ff0"},
+		{"ioctl encoded", x86, "ioctl", [6]uint64{3, 0x80081272, 0x7ff0}, "3, _IOC(_IOC_READ, 0x12, 0x72, 0x8), 0x7ff0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.decoder.Decode(tt.syscall, tt.args).String(); got != tt.want {
+				t.Errorf("Decode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if args := x86.Decode("getpid", [6]uint64{}); args != nil {
+		t.Errorf("expected no decoding for getpid, got %v", args)
+	}
+}
+
+// TestSyscallArgsJSON tests that decoded arguments marshal in call order
+func TestSyscallArgsJSON(t *testing.T) {
+	args := syscallArgs{{"fd", int64(-1)}, {"count", uint64(10)}, {"buf", "NULL"}}
+	data, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fd":-1,"count":10,"buf":"NULL"}`
+	if string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+}
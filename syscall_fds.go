@@ -0,0 +1,258 @@
+package main
+
+import (
+	"strconv"
+)
+
+// fdTarget is the file or socket behind a file descriptor: the open file
+// description, shared by the fds that dup or inherit it.
+type fdTarget struct {
+	// Kind is "file" or "socket".
+	Kind string
+	Path string
+	// Proto, Local and Remote describe sockets. Endpoints are empty until a
+	// connect or accept names them.
+	Proto  string
+	Local  string
+	Remote string
+}
+
+// fdSlot is one entry of an fd table. The close-on-exec flag belongs to the
+// fd, not to the shared target.
+type fdSlot struct {
+	target  *fdTarget
+	cloexec bool
+}
+
+type fdTable map[int64]fdSlot
+
+// String renders the target for the FdTarget column: the path of a file, or
+// the protocol and 4-tuple of a socket.
+func (t *fdTarget) String() string {
+	if t.Kind == "file" {
+		if t.Path == "" {
+			return "file"
+		}
+		return t.Path
+	}
+	s := t.Proto + " socket"
+	if t.Remote != "" {
+		local := t.Local
+		if local == "" {
+			local = "?"
+		}
+		s = t.Proto + " " + local + "->" + t.Remote
+	}
+	return s
+}
+
+const (
+	afUnix        = 1
+	afInet        = 2
+	afInet6       = 10
+	oCloexec      = 0o2000000 // also SOCK_CLOEXEC
+	fDupfd        = 0
+	fSetfd        = 2
+	fDupfdCloexec = 1030
+	fdCloexec     = 1
+	cloneFiles    = 0x400
+	cloneThread   = 0x10000
+)
+
+var socketTypes = map[uint64]string{1: "tcp", 2: "udp", 3: "raw", 5: "seqpacket"}
+
+// syscallFdArgs gives the argument index that holds the fd of syscalls
+// operating on one.
+var syscallFdArgs = map[string]int{
+	"read": 0, "write": 0, "pread64": 0, "pwrite64": 0,
+	"readv": 0, "writev": 0, "preadv": 0, "pwritev": 0, "preadv2": 0, "pwritev2": 0,
+	"close": 0, "fsync": 0, "fdatasync": 0, "sync_file_range": 0,
+	"fstat": 0, "fstatfs": 0, "lseek": 0, "ftruncate": 0, "fallocate": 0, "fadvise64": 0,
+	"getdents64": 0, "ioctl": 0, "fcntl": 0, "flock": 0, "fchmod": 0, "fchown": 0,
+	"sendto": 0, "recvfrom": 0, "sendmsg": 0, "recvmsg": 0, "sendmmsg": 0, "recvmmsg": 0,
+	"connect": 0, "accept": 0, "accept4": 0, "bind": 0, "listen": 0, "shutdown": 0,
+	"getsockname": 0, "getpeername": 0, "setsockopt": 0, "getsockopt": 0,
+	"sendfile": 0, "splice": 0,
+	"mmap": 4,
+}
+
+// fdTracker replays the syscalls that create, duplicate and close file
+// descriptors into per-process fd tables.
+type fdTracker struct {
+	procs map[uint64]fdTable
+	// Unknown counts lookups of fds opened before the capture started.
+	Unknown int64
+}
+
+func newFdTracker() *fdTracker {
+	return &fdTracker{procs: make(map[uint64]fdTable)}
+}
+
+func (t *fdTracker) table(pid uint64) fdTable {
+	fds, ok := t.procs[pid]
+	if !ok {
+		fds = make(fdTable)
+		t.procs[pid] = fds
+	}
+	return fds
+}
+
+// Lookup returns the target of fd in process pid, or nil when it is unknown.
+func (t *fdTracker) Lookup(pid uint64, fd int64) *fdTarget {
+	slot, ok := t.procs[pid][fd]
+	if !ok {
+		t.Unknown++
+	}
+	return slot.target
+}
+
+// Handle resolves the fd of the row that completes c. c is nil for entry rows
+// still waiting for their exit, which are left unresolved so that every call is
+// looked up once. The fd is the argument of calls that use one, or the return
+// value of calls that create one. Completed calls are applied to the fd tables.
+func (t *fdTracker) Handle(c *syscallCall) (fd int64, target *fdTarget, ok bool) {
+	if c == nil || !c.HasArgs {
+		return 0, nil, false
+	}
+	pid := fdOwner(c.Pid, c.Tid)
+	if i, has := syscallFdArgs[c.Name]; has {
+		fd = argFd(c.Args[i])
+		if fd >= 0 {
+			target, ok = t.Lookup(pid, fd), true
+		}
+	}
+	if c.HasRet {
+		if created := t.apply(pid, c); created != nil {
+			fd, target, ok = c.Ret, created, true
+		}
+	}
+	return fd, target, ok
+}
+
+// fdOwner keys fd tables by process, falling back to the thread for scripts
+// that do not print a pid.
+func fdOwner(pid, tid uint64) uint64 {
+	if pid == 0 {
+		return tid
+	}
+	return pid
+}
+
+// apply updates the fd tables after a completed call and returns the target
+// of a newly created fd.
+func (t *fdTracker) apply(pid uint64, c *syscallCall) *fdTarget {
+	// A non-blocking connect fails with EINPROGRESS but still names the socket.
+	if c.Ret < 0 && !(c.Name == "connect" && c.Errno == "EINPROGRESS") {
+		return nil
+	}
+	fds := t.table(pid)
+	switch c.Name {
+	case "open":
+		return fds.set(c.Ret, &fdTarget{Kind: "file", Path: c.Path}, c.Args[1]&oCloexec != 0)
+	case "openat":
+		return fds.set(c.Ret, &fdTarget{Kind: "file", Path: c.Path}, c.Args[2]&oCloexec != 0)
+	case "openat2", "creat":
+		return fds.set(c.Ret, &fdTarget{Kind: "file", Path: c.Path}, false)
+	case "socket":
+		return fds.set(c.Ret, &fdTarget{Kind: "socket", Proto: socketProto(c.Args[0], c.Args[1])},
+			c.Args[1]&oCloexec != 0)
+	case "connect":
+		if slot, ok := fds[argFd(c.Args[0])]; ok {
+			slot.target.connect(c)
+		}
+	case "accept", "accept4":
+		accepted := &fdTarget{Kind: "socket", Proto: "tcp"}
+		if listener, ok := fds[argFd(c.Args[0])]; ok {
+			accepted.Proto = listener.target.Proto
+		}
+		accepted.connect(c)
+		return fds.set(c.Ret, accepted, c.Name == "accept4" && c.Args[3]&oCloexec != 0)
+	case "dup", "dup2":
+		return fds.dup(argFd(c.Args[0]), c.Ret, false)
+	case "dup3":
+		return fds.dup(argFd(c.Args[0]), c.Ret, c.Args[2]&oCloexec != 0)
+	case "fcntl":
+		switch c.Args[1] {
+		case fDupfd, fDupfdCloexec:
+			return fds.dup(argFd(c.Args[0]), c.Ret, c.Args[1] == fDupfdCloexec)
+		case fSetfd:
+			fd := argFd(c.Args[0])
+			if slot, ok := fds[fd]; ok {
+				slot.cloexec = c.Args[2]&fdCloexec != 0
+				fds[fd] = slot
+			}
+		}
+	case "close":
+		delete(fds, argFd(c.Args[0]))
+	case "fork", "vfork", "clone", "clone3":
+		if c.Name == "clone" && c.Args[0]&cloneThread != 0 || c.Ret <= 0 {
+			return nil
+		}
+		// CLONE_FILES shares the table, so later changes are seen by both processes.
+		// clone3 passes its flags in memory, so its children get a copy like fork.
+		if c.Name == "clone" && c.Args[0]&cloneFiles != 0 {
+			t.procs[uint64(c.Ret)] = fds
+		} else {
+			t.procs[uint64(c.Ret)] = fds.copy()
+		}
+	case "execve", "execveat":
+		// exec unshares a table shared with CLONE_FILES before closing fds.
+		fds = fds.copy()
+		for fd, slot := range fds {
+			if slot.cloexec {
+				delete(fds, fd)
+			}
+		}
+		t.procs[pid] = fds
+	}
+	return nil
+}
+
+// copy returns a copy of an fd table, as inherited by a forked child. The
+// targets are shared, so a connect in either process names the socket in both.
+func (fds fdTable) copy() fdTable {
+	copied := make(fdTable, len(fds))
+	for fd, slot := range fds {
+		copied[fd] = slot
+	}
+	return copied
+}
+
+func (fds fdTable) set(fd int64, target *fdTarget, cloexec bool) *fdTarget {
+	fds[fd] = fdSlot{target: target, cloexec: cloexec}
+	return target
+}
+
+// dup points newFd at the target of oldFd. The copy is unknown when oldFd is.
+func (fds fdTable) dup(oldFd, newFd int64, cloexec bool) *fdTarget {
+	old, ok := fds[oldFd]
+	if !ok {
+		delete(fds, newFd)
+		return nil
+	}
+	return fds.set(newFd, old.target, cloexec)
+}
+
+// connect names the endpoints of a socket from the optional address fields.
+// saddr/sport is the local end and daddr/dport the remote end.
+func (t *fdTarget) connect(c *syscallCall) {
+	if c.SrcAddr.IsValid() {
+		t.Local = netEndpoint(c.SrcAddr, c.SrcPort)
+	}
+	if c.DstAddr.IsValid() {
+		t.Remote = netEndpoint(c.DstAddr, c.DstPort)
+	}
+}
+
+// socketProto names a socket from its socket(2) domain and type.
+func socketProto(domain, typ uint64) string {
+	switch domain {
+	case afUnix:
+		return "unix"
+	case afInet, afInet6:
+		if name, ok := socketTypes[typ&0xf]; ok {
+			return name
+		}
+	}
+	return "af" + strconv.FormatUint(domain, 10)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestFdTracker tests attributing fd-based syscalls to files and sockets
+func TestFdTracker(t *testing.T) {
+	type row struct {
+		fd     any
+		target any
+	}
+	events := []syscallRawEvent{
+		// openat("/etc/hosts", O_RDONLY|O_CLOEXEC) = 3, as entry and exit
+		{Probe: "tracepoint:syscalls:sys_enter_openat", Pid: 100, Tid: 100, Arg0: ^uint64(99), Arg2: 0o2000000, Path: "/etc/hosts"},
+		{Probe: "tracepoint:syscalls:sys_exit_openat", Pid: 100, Tid: 100, ReturnValue: 3},
+		// read(3) as a single event
+		{SyscallName: "read", Pid: 100, Tid: 100, Arg0: 3, ReturnValue: 10},
+		// socket(AF_INET, SOCK_STREAM) = 4, connect(4) to 10.0.0.2:443 with EINPROGRESS
+		{SyscallName: "socket", Pid: 100, Tid: 100, Arg0: 2, Arg1: 1, ReturnValue: 4},
+		{SyscallName: "connect", Pid: 100, Tid: 100, Arg0: 4, ReturnValue: -115, DstAddr: netip.MustParseAddr("10.0.0.2"), DstPort: 443},
+		{SyscallName: "write", Pid: 100, Tid: 101, Arg0: 4, ReturnValue: 5},
+		// dup2(3, 7) = 7
+		{SyscallName: "dup2", Pid: 100, Tid: 100, Arg0: 3, Arg1: 7, ReturnValue: 7},
+		// fork = 200; the child inherits the table, exec drops O_CLOEXEC fds
+		{SyscallName: "fork", Pid: 100, Tid: 100, ReturnValue: 200},
+		{SyscallName: "execve", Pid: 200, Tid: 200},
+		{SyscallName: "read", Pid: 200, Tid: 200, Arg0: 3},
+		{SyscallName: "read", Pid: 200, Tid: 200, Arg0: 7},
+		// close(3) shows the closed file, later reads are unknown
+		{SyscallName: "close", Pid: 100, Tid: 100, Arg0: 3},
+		{SyscallName: "read", Pid: 100, Tid: 100, Arg0: 3},
+		// fds opened before the capture are unknown, looked up once per call
+		{SyscallName: "write", Pid: 100, Tid: 100, Arg0: 1, ReturnValue: 1},
+		{Probe: "tracepoint:syscalls:sys_enter_read", Pid: 100, Tid: 100, Arg0: 0},
+		{Probe: "tracepoint:syscalls:sys_exit_read", Pid: 100, Tid: 100, ReturnValue: 1},
+		// clone(CLONE_FILES) = 300 shares the table: the child's close is seen by the parent
+		{SyscallName: "clone", Pid: 100, Tid: 100, Arg0: 0x400, ReturnValue: 300},
+		{SyscallName: "close", Pid: 300, Tid: 300, Arg0: 7},
+		{SyscallName: "read", Pid: 100, Tid: 100, Arg0: 7},
+		// dup(5) = 6 shares the socket: connecting fd 5 names fd 6 too, also in a clone3 child
+		{SyscallName: "socket", Pid: 100, Tid: 100, Arg0: 2, Arg1: 1, ReturnValue: 5},
+		{SyscallName: "dup", Pid: 100, Tid: 100, Arg0: 5, ReturnValue: 6},
+		{SyscallName: "clone3", Pid: 100, Tid: 100, ReturnValue: 400},
+		{SyscallName: "connect", Pid: 100, Tid: 100, Arg0: 5, DstAddr: netip.MustParseAddr("10.0.0.3"), DstPort: 80},
+		{SyscallName: "write", Pid: 100, Tid: 100, Arg0: 6, ReturnValue: 1},
+		{SyscallName: "write", Pid: 400, Tid: 400, Arg0: 6, ReturnValue: 1},
+	}
+	expected := []row{
+		{nil, nil},
+		{int64(3), "/etc/hosts"},
+		{int64(3), "/etc/hosts"},
+		{int64(4), "tcp socket"},
+		{int64(4), "tcp ?->10.0.0.2:443"},
+		{int64(4), "tcp ?->10.0.0.2:443"},
+		{int64(7), "/etc/hosts"},
+		{nil, nil},
+		{nil, nil},
+		{int64(3), nil},
+		{int64(7), "/etc/hosts"},
+		{int64(3), "/etc/hosts"},
+		{int64(3), nil},
+		{int64(1), nil},
+		{nil, nil},
+		{int64(0), nil},
+		{nil, nil},
+		{int64(7), "/etc/hosts"},
+		{int64(7), nil},
+		{int64(5), "tcp socket"},
+		{int64(6), "tcp socket"},
+		{nil, nil},
+		{int64(5), "tcp ?->10.0.0.3:80"},
+		{int64(6), "tcp ?->10.0.0.3:80"},
+		{int64(6), "tcp ?->10.0.0.3:80"},
+	}
+
+	fds := newFdTracker()
+	pairer := newSyscallPairer()
+	for i := range events {
+		e := &events[i]
+		e.resolve(syscallNamesX86_64)
+		got := row{}
+		c, _ := pairer.Handle(e)
+		if fd, target, ok := fds.Handle(c); ok {
+			got.fd = fd
+			if target != nil {
+				got.target = target.String()
+			}
+		}
+		if got != expected[i] {
+			t.Errorf("event %d (%s): got %v, want %v", i, e.SyscallName, got, expected[i])
+		}
+	}
+	if fds.Unknown != 5 {
+		t.Errorf("Unknown = %d, want 5", fds.Unknown)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+//go:generate go run gen_syscall_table.go
+
+// maxErrno is the largest errno a syscall can return as -errno.
+const maxErrno = 4095
+
+// syscallTable maps syscall numbers to names for one architecture.
+type syscallTable map[uint64]string
+
+// normalizeSyscallArch maps a Go or uname architecture name to x86_64 or arm64.
+func normalizeSyscallArch(arch string) (string, error) {
+	switch arch {
+	case "amd64", "x86_64":
+		return "x86_64", nil
+	case "arm64", "aarch64":
+		return "arm64", nil
+	default:
+		return "", fmt.Errorf("unsupported syscall arch %q (expected x86_64 or arm64)", arch)
+	}
+}
+
+// lookupSyscallTable returns the syscall table of arch.
+func lookupSyscallTable(arch string) (syscallTable, error) {
+	arch, err := normalizeSyscallArch(arch)
+	if err != nil {
+		return nil, err
+	}
+	if arch == "arm64" {
+		return syscallNamesArm64, nil
+	}
+	return syscallNamesX86_64, nil
+}
+
+// Name returns the syscall name of nr, or "" when nr is unknown.
+func (t syscallTable) Name(nr uint64) string {
+	return t[nr]
+}
+
+// RenameKeys replaces count keys that are bare syscall numbers with their names.
+func (t syscallTable) RenameKeys(counts map[string]int64) map[string]int64 {
+	renamed := make(map[string]int64, len(counts))
+	for k, v := range counts {
+		if nr, err := strconv.ParseUint(k, 10, 64); err == nil {
+			if name := t.Name(nr); name != "" {
+				k = name
+			}
+		}
+		renamed[k] += v
+	}
+	return renamed
+}
+
+// errnoName returns the errno name of a negative syscall return value, or ""
+// when ret is not an error or the errno is unknown.
+func errnoName(ret int64) string {
+	if ret >= 0 || ret < -maxErrno {
+		return ""
+	}
+	return errnoNames[uint64(-ret)]
+}
+
+func syscallArchFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "arch",
+		Usage: "architecture of the traced host for syscall numbers: x86_64, arm64 (default: this host, when supported)",
+	}
+}
+
+// syscallArch returns the architecture selected by the flag added by
+// syscallArchFlag. Without --arch it is the host architecture, or "" when the
+// host is neither x86_64 nor arm64.
+func syscallArch(command *cli.Command) (string, error) {
+	if arch := command.String("arch"); arch != "" {
+		return normalizeSyscallArch(arch)
+	}
+	arch, err := normalizeSyscallArch(runtime.GOARCH)
+	if err != nil {
+		return "", nil
+	}
+	return arch, nil
+}
+
+// syscallTableFromFlags returns the syscall table selected by the flag added by
+// syscallArchFlag. It returns a nil table, which resolves no numbers, when the
+// flag is unset and the host architecture is not supported.
+func syscallTableFromFlags(command *cli.Command) (syscallTable, error) {
+	arch, err := syscallArch(command)
+	if err != nil {
+		return nil, err
+	}
+	if arch == "" {
+		log.Warn().Str("arch", runtime.GOARCH).Msg("No syscall table for this host, pass --arch to resolve syscall numbers")
+		return nil, nil
+	}
+	return lookupSyscallTable(arch)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+// TestSyscallTables tests syscall number lookups on both architectures
+func TestSyscallTables(t *testing.T) {
+	tests := []struct {
+		arch string
+		nr   uint64
+		want string
+	}{
+		{"x86_64", 0, "read"},
+		{"amd64", 257, "openat"},
+		{"x86_64", 59, "execve"},
+		{"arm64", 63, "read"},
+		{"aarch64", 56, "openat"},
+		{"arm64", 221, "execve"},
+		{"x86_64", 100000, ""},
+	}
+	for _, tt := range tests {
+		table, err := lookupSyscallTable(tt.arch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := table.Name(tt.nr); got != tt.want {
+			t.Errorf("%s nr %d = %q, want %q", tt.arch, tt.nr, got, tt.want)
+		}
+	}
+	if _, err := lookupSyscallTable("mips"); err == nil {
+		t.Error("expected error for unsupported arch")
+	}
+}
+
+// TestSyscallArchFlag tests that only an explicit unsupported --arch is an error
+func TestSyscallArchFlag(t *testing.T) {
+	run := func(args ...string) (syscallTable, *syscallArgDecoder, error) {
+		var table syscallTable
+		var decoder *syscallArgDecoder
+		cmd := &cli.Command{
+			Name:  "test",
+			Flags: []cli.Flag{syscallArchFlag()},
+			Action: func(ctx context.Context, command *cli.Command) error {
+				var err error
+				if table, err = syscallTableFromFlags(command); err != nil {
+					return err
+				}
+				decoder, err = syscallArgDecoderFromFlags(command)
+				return err
+			},
+		}
+		err := cmd.Run(context.Background(), append([]string{"test"}, args...))
+		return table, decoder, err
+	}
+
+	if _, _, err := run("--arch", "mips"); err == nil {
+		t.Error("expected error for an explicit unsupported arch")
+	}
+	table, decoder, err := run("--arch", "arm64")
+	if err != nil || table.Name(63) != "read" || decoder == nil || !decoder.cloneTLSFirst {
+		t.Errorf("--arch arm64: table=%v decoder=%+v err=%v", table != nil, decoder, err)
+	}
+	if _, _, err := run(); err != nil {
+		t.Errorf("host default: %v", err)
+	}
+
+	var none syscallTable
+	var noDecoder *syscallArgDecoder
+	if none.Name(0) != "" || noDecoder.Decode("openat", [6]uint64{}) != nil {
+		t.Error("nil table and decoder should resolve nothing")
+	}
+}
+
+// TestSyscallRawEventResolve tests filling in names and errno from raw values
+func TestSyscallRawEventResolve(t *testing.T) {
+	tests := []struct {
+		event     syscallRawEvent
+		wantName  string
+		wantErrno string
+	}{
+		{syscallRawEvent{SyscallNr: 257, hasNr: true, ReturnValue: -2}, "openat", "ENOENT"},
+		{syscallRawEvent{SyscallNr: 0, hasNr: true, ReturnValue: -11}, "read", "EAGAIN"},
+		{syscallRawEvent{SyscallNr: 0, hasNr: true, SyscallName: "pread", ReturnValue: 4096}, "pread", ""},
+		{syscallRawEvent{SyscallNr: 9, hasNr: true, ReturnValue: -8192}, "mmap", ""},
+	}
+	for _, tt := range tests {
+		e := tt.event
+		e.resolve(syscallNamesX86_64)
+		if e.SyscallName != tt.wantName || e.Errno != tt.wantErrno {
+			t.Errorf("resolve(%d, %d) = %q %q, want %q %q", tt.event.SyscallNr, tt.event.ReturnValue,
+				e.SyscallName, e.Errno, tt.wantName, tt.wantErrno)
+		}
+	}
+
+	counts := syscallNamesX86_64.RenameKeys(map[string]int64{"0": 3, "read": 2, "1": 5, "999999": 1})
+	if counts["read"] != 5 || counts["write"] != 5 || counts["999999"] != 1 {
+		t.Errorf("RenameKeys = %v", counts)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// syscallCall is a single syscall with its entry and exit events merged.
+type syscallCall struct {
+	Probe string // probe of the entry, or of the exit when unmatched
+	Tid   uint64
+	Pid   uint64
+	Comm  string
+	Nr    uint64
+	Name  string
+	Args  [6]uint64
+	Ret   int64
+	Errno string
+	Start uint64
+	End   uint64
+
+	Path    string
+	SrcAddr netip.Addr
+	SrcPort uint16
+	DstAddr netip.Addr
+	DstPort uint16
+
+	// HasArgs is false for exits without a matching entry, HasRet is false
+	// for entries that never saw their exit.
+	HasArgs bool
+	HasRet  bool
+
+	hasNr bool
+}
+
+// Duration returns the time between entry and exit.
+func (c *syscallCall) Duration() time.Duration {
+	if c.End < c.Start {
+		return 0
+	}
+	return time.Duration(c.End - c.Start)
+}
+
+// Timed reports whether the call was seen entering and exiting, so that its
+// duration is known.
+func (c *syscallCall) Timed() bool {
+	return c.HasArgs && c.HasRet && c.End != c.Start
+}
+
+// syscallProbeKind returns the syscall named by a probe such as
+// "tracepoint:syscalls:sys_enter_openat" or "kretprobe:__x64_sys_openat", and
+// whether it is an entry or an exit probe. raw_syscalls probes name no
+// syscall. Events without a probe carry both the arguments and the return value.
+func syscallProbeKind(probe string) (name string, isEntry bool, isExit bool) {
+	if probe == "" {
+		return "", false, false
+	}
+	fn := probe[strings.LastIndexByte(probe, ':')+1:]
+	switch {
+	case strings.HasPrefix(fn, "sys_enter"):
+		return strings.TrimPrefix(strings.TrimPrefix(fn, "sys_enter"), "_"), true, false
+	case strings.HasPrefix(fn, "sys_exit"):
+		return strings.TrimPrefix(strings.TrimPrefix(fn, "sys_exit"), "_"), false, true
+	}
+	if i := strings.Index(fn, "sys_"); i >= 0 {
+		name = fn[i+len("sys_"):]
+	}
+	_, isEntry, isExit = vfsProbeOp(probe)
+	return name, isEntry, isExit
+}
+
+// syscallPairer merges entry and exit events of the same thread into syscallCalls.
+// A thread is in at most one syscall at a time, so events are keyed by tid.
+type syscallPairer struct {
+	pending map[uint64]*syscallCall
+}
+
+func newSyscallPairer() *syscallPairer {
+	return &syscallPairer{pending: make(map[uint64]*syscallCall)}
+}
+
+// Handle consumes a resolved event and returns the call it completes, or nil
+// while waiting for the exit. Exits without a matching entry are reported
+// without arguments. stale is an earlier entry of the thread that will not see
+// its exit, because the thread entered another syscall or exited a different
+// one; it is reported without a return value.
+func (p *syscallPairer) Handle(e *syscallRawEvent) (c, stale *syscallCall) {
+	_, isEntry, isExit := syscallProbeKind(e.Probe)
+	switch {
+	case isEntry:
+		stale = p.pending[e.Tid]
+		c = &syscallCall{
+			Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm,
+			Nr: e.SyscallNr, Name: e.SyscallName, Args: e.args(),
+			Start: e.Timestamp, HasArgs: true, hasNr: e.hasNr,
+		}
+		c.addTarget(e)
+		p.pending[e.Tid] = c
+		return nil, stale
+
+	case isExit:
+		var ok bool
+		c, ok = p.pending[e.Tid]
+		if ok {
+			delete(p.pending, e.Tid)
+			if !c.matches(e) {
+				stale, ok = c, false
+			}
+		}
+		if !ok {
+			c = &syscallCall{
+				Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm,
+				Nr: e.SyscallNr, Start: e.Timestamp, hasNr: e.hasNr,
+			}
+		}
+		if c.Name == "" {
+			c.Name = e.SyscallName
+		}
+		c.Ret = e.ReturnValue
+		c.Errno = e.Errno
+		c.End = e.Timestamp
+		c.HasRet = true
+		c.addTarget(e)
+		return c, stale
+
+	default:
+		c = &syscallCall{
+			Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm,
+			Nr: e.SyscallNr, Name: e.SyscallName, Args: e.args(),
+			Ret: e.ReturnValue, Errno: e.Errno,
+			Start: e.Timestamp, End: e.Timestamp,
+			HasArgs: true, HasRet: true, hasNr: e.hasNr,
+		}
+		c.addTarget(e)
+		return c, nil
+	}
+}
+
+// matches reports whether the exit event e belongs to the pending call c.
+// Names are compared when both are known, numbers otherwise.
+func (c *syscallCall) matches(e *syscallRawEvent) bool {
+	if c.Name != "" && e.SyscallName != "" {
+		return c.Name == e.SyscallName
+	}
+	return !c.hasNr || !e.hasNr || c.Nr == e.SyscallNr
+}
+
+// addTarget copies the optional path and socket fields of an event, which
+// scripts may print on either the entry or the exit.
+func (c *syscallCall) addTarget(e *syscallRawEvent) {
+	if e.Path != "" {
+		c.Path = e.Path
+	}
+	if e.SrcAddr.IsValid() {
+		c.SrcAddr, c.SrcPort = e.SrcAddr, e.SrcPort
+	}
+	if e.DstAddr.IsValid() {
+		c.DstAddr, c.DstPort = e.DstAddr, e.DstPort
+	}
+}
+
+// Pending returns the calls still waiting for their exit, oldest first.
+func (p *syscallPairer) Pending() []*syscallCall {
+	calls := make([]*syscallCall, 0, len(p.pending))
+	for _, c := range p.pending {
+		calls = append(calls, c)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Start < calls[j].Start
+	})
+	return calls
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+// TestSyscallPairerStale tests that entries replaced by another entry or ended by
+// the exit of another syscall are reported as unfinished
+func TestSyscallPairerStale(t *testing.T) {
+	events := []syscallRawEvent{
+		{Timestamp: 100, Probe: "tracepoint:syscalls:sys_enter_exit_group", Tid: 1},
+		{Timestamp: 200, Probe: "tracepoint:syscalls:sys_enter_read", Tid: 1},
+		{Timestamp: 300, Probe: "tracepoint:syscalls:sys_exit_read", Tid: 1, ReturnValue: 5},
+		{Timestamp: 400, Probe: "tracepoint:raw_syscalls:sys_enter", Tid: 2, SyscallNr: 0, hasNr: true},
+		{Timestamp: 500, Probe: "tracepoint:raw_syscalls:sys_exit", Tid: 2, SyscallNr: 1, hasNr: true, ReturnValue: 3},
+		{Timestamp: 600, Probe: "tracepoint:syscalls:sys_enter_write", Tid: 3},
+		{Timestamp: 700, Probe: "tracepoint:raw_syscalls:sys_exit", Tid: 3, SyscallNr: 1, hasNr: true, ReturnValue: 1},
+	}
+	type result struct {
+		name    string
+		start   uint64
+		hasArgs bool
+		hasRet  bool
+	}
+	var calls, stales []result
+	pairer := newSyscallPairer()
+	for i := range events {
+		e := &events[i]
+		e.resolve(syscallNamesX86_64)
+		c, stale := pairer.Handle(e)
+		if c != nil {
+			calls = append(calls, result{c.Name, c.Start, c.HasArgs, c.HasRet})
+		}
+		if stale != nil {
+			stales = append(stales, result{stale.Name, stale.Start, stale.HasArgs, stale.HasRet})
+		}
+	}
+
+	wantCalls := []result{
+		{"read", 200, true, true},
+		{"write", 500, false, true},
+		{"write", 600, true, true},
+	}
+	wantStales := []result{
+		{"exit_group", 100, true, false},
+		{"read", 400, true, false},
+	}
+	if len(calls) != len(wantCalls) || len(stales) != len(wantStales) {
+		t.Fatalf("calls = %+v, stale = %+v", calls, stales)
+	}
+	for i := range wantCalls {
+		if calls[i] != wantCalls[i] {
+			t.Errorf("call %d = %+v, want %+v", i, calls[i], wantCalls[i])
+		}
+	}
+	for i := range wantStales {
+		if stales[i] != wantStales[i] {
+			t.Errorf("stale %d = %+v, want %+v", i, stales[i], wantStales[i])
+		}
+	}
+	if len(pairer.Pending()) != 0 {
+		t.Errorf("unexpected pending calls: %+v", pairer.Pending())
+	}
+}
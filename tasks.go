@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kr/logfmt"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// taskInfo describes the process a thread belongs to.
+type taskInfo struct {
+	Pid     uint64
+	Comm    string
+	Cmdline string
+}
+
+// taskTable maps thread ids to the process they belong to. It is fed from proc raw
+// events, either interleaved in the stream being imported or from a separate capture,
+// and from a /proc snapshot. Events of a separate capture are replayed in timestamp
+// order as the imported stream reaches them, so a thread that execs or a reused tid
+// is attributed to the process it belonged to at the time.
+type taskTable struct {
+	tasks  map[uint64]taskInfo
+	replay []procRawEvent
+}
+
+func newTaskTable() *taskTable {
+	return &taskTable{tasks: make(map[uint64]taskInfo)}
+}
+
+// Len returns the number of known threads.
+func (t *taskTable) Len() int {
+	return len(t.tasks)
+}
+
+// Lookup returns the process of a thread.
+func (t *taskTable) Lookup(tid uint64) (taskInfo, bool) {
+	info, ok := t.tasks[tid]
+	return info, ok
+}
+
+// LookupAt returns the process of a thread at time ts, after replaying the
+// captured proc events up to ts.
+func (t *taskTable) LookupAt(tid, ts uint64) (taskInfo, bool) {
+	n := 0
+	for n < len(t.replay) && t.replay[n].Timestamp <= ts {
+		t.Observe(&t.replay[n])
+		n++
+	}
+	t.replay = t.replay[n:]
+	return t.Lookup(tid)
+}
+
+// Observe updates the table from a proc raw event.
+func (t *taskTable) Observe(e *procRawEvent) {
+	switch classifyProcProbe(e.Probe) {
+	case procEventFork:
+		// A forked child inherits comm and cmdline from its parent
+		info := taskInfo{Pid: e.Pid, Comm: e.Comm}
+		if parent, ok := t.tasks[e.Ppid]; ok {
+			info.Cmdline = parent.Cmdline
+			if info.Comm == "" {
+				info.Comm = parent.Comm
+			}
+		}
+		t.tasks[e.Pid] = info
+
+	case procEventExec:
+		cmdline := e.Cmdline
+		if cmdline == "" {
+			cmdline = e.Comm
+		}
+		info := taskInfo{Pid: e.Pid, Comm: e.Comm, Cmdline: cmdline}
+		t.tasks[e.Pid] = info
+		if e.Tid != 0 {
+			t.tasks[e.Tid] = info
+		}
+
+	default:
+		if e.Tid == 0 || e.Pid == 0 {
+			return
+		}
+		info := t.tasks[e.Pid]
+		info.Pid = e.Pid
+		if e.Comm != "" {
+			info.Comm = e.Comm
+		}
+		if e.Cmdline != "" {
+			info.Cmdline = e.Cmdline
+		}
+		t.tasks[e.Tid] = info
+	}
+}
+
+// LoadProcCapture queues the events of a recorded proc raw capture, to be
+// replayed by LookupAt.
+func (t *taskTable) LoadProcCapture(r io.Reader) error {
+	err := procJSONParseThenAppend(r, func(e *procRawEvent) error {
+		t.replay = append(t.replay, *e)
+		return nil
+	})
+	sort.SliceStable(t.replay, func(i, j int) bool {
+		return t.replay[i].Timestamp < t.replay[j].Timestamp
+	})
+	return err
+}
+
+// LoadProcfs takes a snapshot of all threads under a procfs mount such as /proc.
+// Processes that exit while the snapshot is taken are skipped.
+func (t *taskTable) LoadProcfs(root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("read procfs: %w", err)
+	}
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		info := taskInfo{Pid: pid}
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			info.Comm = strings.TrimSpace(string(comm))
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			info.Cmdline = strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+		}
+		t.tasks[pid] = info
+
+		threads, err := os.ReadDir(filepath.Join(dir, "task"))
+		if err != nil {
+			continue
+		}
+		for _, thread := range threads {
+			tid, err := strconv.ParseUint(thread.Name(), 10, 64)
+			if err != nil {
+				continue
+			}
+			t.tasks[tid] = info
+		}
+	}
+	return nil
+}
+
+// printfField returns the raw value of a logfmt key in a printf payload.
+func printfField(buf []byte, key string) []byte {
+	for len(buf) > 0 {
+		buf = bytes.TrimLeft(buf, " ")
+		end := bytes.IndexByte(buf, ' ')
+		field := buf
+		if end >= 0 {
+			field = buf[:end]
+			buf = buf[end+1:]
+		} else {
+			buf = nil
+		}
+		if len(field) > len(key) && field[len(key)] == '=' && string(field[:len(key)]) == key {
+			return field[len(key)+1:]
+		}
+	}
+	return nil
+}
+
+// Intercept is a printfHook that consumes proc events interleaved in another stream,
+// such as a combined vfs and proc script, and feeds them into the table.
+func (t *taskTable) Intercept(buf []byte) (bool, error) {
+	fn := printfField(buf, "fn")
+	if fn == nil || classifyProcProbe(string(fn)) == procEventOther {
+		return false, nil
+	}
+	var e procRawEvent
+	if err := logfmt.Unmarshal(buf, &e); err != nil {
+		return false, fmt.Errorf("failed to unmarshal proc event: %w", err)
+	}
+	t.Observe(&e)
+	return true, nil
+}
+
+// taskFlags returns the flags used to seed a taskTable for raw imports.
+func taskFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "proc-input",
+			Usage: "proc raw capture used to attribute threads to processes, replayed by timestamp (record it during the same boot)",
+		},
+		&cli.BoolFlag{
+			Name:  "proc-snapshot",
+			Usage: "snapshot /proc at start to attribute threads to processes",
+		},
+	}
+}
+
+// newTaskTableFromFlags builds a taskTable from the flags added by taskFlags.
+func newTaskTableFromFlags(command *cli.Command) (*taskTable, error) {
+	tasks := newTaskTable()
+	if command.Bool("proc-snapshot") {
+		if err := tasks.LoadProcfs("/proc"); err != nil {
+			return nil, err
+		}
+	}
+	if input := command.String("proc-input"); input != "" {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, fmt.Errorf("open proc input: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		if err := tasks.LoadProcCapture(f); err != nil {
+			return nil, fmt.Errorf("load proc input: %w", err)
+		}
+	}
+	log.Debug().Int("tasks", tasks.Len()).Int("proc_events", len(tasks.replay)).Msg("Task table loaded")
+	return tasks, nil
+}
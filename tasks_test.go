@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTaskTableMixedStream tests that proc events interleaved in a vfs stream enrich later rows
+func TestTaskTableMixedStream(t *testing.T) {
+	testData := `{"type": "attached_probes", "data": {"probes": 8}}
+{"type": "printf", "data": "ts=1 fn=sched_process_exec pid=42 ppid=1 tid=42 comm='postgres' cmdline=\"postgres -D /data\""}
+{"type": "printf", "data": "ts=2 fn=vfs_write tid=42 rc=100 path='wal' inode=7 offset=0 len=100"}
+{"type": "printf", "data": "ts=3 fn=vfs_read tid=99 rc=10 path='other' inode=8 offset=0 len=10"}
+`
+	tasks := newTaskTable()
+	var events []vfsEvent
+	err := jsonParseThenAppend(strings.NewReader(testData), func(e *vfsEvent) error {
+		e.enrich(tasks)
+		events = append(events, *e)
+		return nil
+	}, tasks.Intercept)
+	if isErrorUnsupportedPlatform(err) {
+		t.Skip()
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 vfs events, got %d", len(events))
+	}
+	if events[0].Pid != 42 || events[0].Comm != "postgres" {
+		t.Errorf("event for tid 42 not enriched: pid=%d comm=%q", events[0].Pid, events[0].Comm)
+	}
+	if events[1].Pid != 0 || events[1].Comm != "" {
+		t.Errorf("event for unknown tid should not be enriched: pid=%d comm=%q", events[1].Pid, events[1].Comm)
+	}
+}
+
+// TestTaskTableProcCapture tests that a separate proc capture is replayed by timestamp
+func TestTaskTableProcCapture(t *testing.T) {
+	capture := `{"type": "attached_probes", "data": {"probes": 4}}
+{"type": "printf", "data": "ts=1 fn=sched_process_exec pid=42 ppid=1 tid=42 comm='sh'"}
+{"type": "printf", "data": "ts=5 fn=sched_process_exec pid=42 ppid=1 tid=42 comm='postgres'"}
+`
+	tasks := newTaskTable()
+	if err := tasks.LoadProcCapture(strings.NewReader(capture)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tasks.LookupAt(42, 0); ok {
+		t.Error("tid 42 should be unknown before its exec")
+	}
+	var comms []string
+	for _, ts := range []uint64{2, 5, 10} {
+		e := vfsEvent{Timestamp: ts, Tid: 42}
+		e.enrich(tasks)
+		comms = append(comms, e.Comm)
+	}
+	if strings.Join(comms, ",") != "sh,postgres,postgres" {
+		t.Errorf("comms = %v, want sh before the exec at ts=5 and postgres after", comms)
+	}
+}
+
+// TestTaskTableLoadProcfs tests reading threads from a procfs-like directory
+func TestTaskTableLoadProcfs(t *testing.T) {
+	root := t.TempDir()
+	pidDir := filepath.Join(root, "100")
+	for _, dir := range []string{"task/100", "task/101"} {
+		if err := os.MkdirAll(filepath.Join(pidDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(pidDir, "comm"), []byte("nginx\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte("nginx\x00-g\x00daemon off;\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := newTaskTable()
+	if err := tasks.LoadProcfs(root); err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := tasks.Lookup(101)
+	if !ok {
+		t.Fatal("thread 101 not found")
+	}
+	if info.Pid != 100 || info.Comm != "nginx" || info.Cmdline != "nginx -g daemon off;" {
+		t.Errorf("unexpected task info: %+v", info)
+	}
+}
+
+// TestPrintfField tests extracting a raw logfmt value
+func TestPrintfField(t *testing.T) {
+	buf := []byte("ts=1 fn=vfs_read tid=2")
+	if got := string(printfField(buf, "fn")); got != "vfs_read" {
+		t.Errorf("printfField(fn) = %q, want vfs_read", got)
+	}
+	if got := printfField(buf, "f"); got != nil {
+		t.Errorf("printfField(f) = %q, want nil", got)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// traceEvent is one event of the Chrome trace-event format, which Perfetto and
+// chrome://tracing open. Timestamps and durations are in microseconds.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Ph    string         `json:"ph"`
+	Ts    float64        `json:"ts"`
+	Dur   float64        `json:"dur,omitempty"`
+	Pid   uint64         `json:"pid"`
+	Tid   uint64         `json:"tid"`
+	Scope string         `json:"s,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// traceWriter streams trace events as a JSON object with a traceEvents array,
+// so large captures are never held in memory.
+type traceWriter struct {
+	w       *bufio.Writer
+	count   int
+	threads map[uint64]bool
+}
+
+func newTraceWriter(w io.Writer) *traceWriter {
+	return &traceWriter{w: bufio.NewWriter(w), threads: make(map[uint64]bool)}
+}
+
+func (t *traceWriter) write(e *traceEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if t.count == 0 {
+		_, err = t.w.WriteString(`{"displayTimeUnit":"ns","traceEvents":[` + "\n")
+	} else {
+		_, err = t.w.WriteString(",\n")
+	}
+	if err != nil {
+		return err
+	}
+	t.count++
+	_, err = t.w.Write(data)
+	return err
+}
+
+// nameThread emits a thread_name metadata event the first time tid is seen.
+func (t *traceWriter) nameThread(pid, tid uint64, comm string) error {
+	if t.threads[tid] || comm == "" {
+		return nil
+	}
+	t.threads[tid] = true
+	return t.write(&traceEvent{
+		Name: "thread_name", Ph: "M", Pid: pid, Tid: tid,
+		Args: map[string]any{"name": comm},
+	})
+}
+
+// Complete writes a slice from startNs lasting durNs.
+func (t *traceWriter) Complete(name, cat string, pid, tid uint64, comm string, startNs, durNs uint64, args map[string]any) error {
+	if err := t.nameThread(pid, tid, comm); err != nil {
+		return err
+	}
+	return t.write(&traceEvent{
+		Name: name, Cat: cat, Ph: "X", Pid: pid, Tid: tid,
+		Ts: float64(startNs) / 1e3, Dur: float64(durNs) / 1e3, Args: args,
+	})
+}
+
+// Instant writes a thread-scoped instant event at tsNs.
+func (t *traceWriter) Instant(name, cat string, pid, tid uint64, comm string, tsNs uint64, args map[string]any) error {
+	if err := t.nameThread(pid, tid, comm); err != nil {
+		return err
+	}
+	return t.write(&traceEvent{
+		Name: name, Cat: cat, Ph: "i", Scope: "t", Pid: pid, Tid: tid,
+		Ts: float64(tsNs) / 1e3, Args: args,
+	})
+}
+
+// Close terminates the JSON document and flushes it.
+func (t *traceWriter) Close() error {
+	var err error
+	if t.count == 0 {
+		_, err = t.w.WriteString(`{"displayTimeUnit":"ns","traceEvents":[]}` + "\n")
+	} else {
+		_, err = t.w.WriteString("\n]}\n")
+	}
+	if err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// Span writes a call seen from startNs to endNs as a slice, or as an instant
+// when only one end of it was seen.
+func (t *traceWriter) Span(name, cat string, pid, tid uint64, comm string, startNs, endNs uint64, args map[string]any) error {
+	if endNs > startNs {
+		return t.Complete(name, cat, pid, tid, comm, startNs, endNs-startNs, args)
+	}
+	return t.Instant(name, cat, pid, tid, comm, startNs, args)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+type tidPath struct {
+	tid  uint64
+	path string
+}
+
+// inodeResolver fills in inode numbers for vfs events. vfs-raw.bt only records the
+// inode on vfs_open, so the resolver learns (tid, basename) -> inode from open events
+// and applies it to later read, write and fsync events of the same thread and file.
+// With byNameAll set, basenames seen with a single inode are also resolved for other
+// threads, which is wrong when a thread uses a file it opened before the capture.
+// Optionally it maps inodes to full paths from a `find -printf '%i %p\n'` dump.
+type inodeResolver struct {
+	byFile    map[tidPath]uint64
+	byName    map[string]uint64
+	ambiguous map[string]bool
+	paths     map[uint64]string
+	byNameAll bool
+}
+
+func newInodeResolver() *inodeResolver {
+	return &inodeResolver{
+		byFile:    make(map[tidPath]uint64),
+		byName:    make(map[string]uint64),
+		ambiguous: make(map[string]bool),
+		paths:     make(map[uint64]string),
+	}
+}
+
+// inodePathsFlag returns the flag used to load a find dump into an inodeResolver.
+func inodePathsFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "inode-paths",
+		Usage: "file of '<inode> <path>' lines, as printed by find -printf '%i %p\\n', to resolve full paths",
+	}
+}
+
+// inodeByNameFlag returns the flag that lets an inodeResolver match basenames across threads.
+func inodeByNameFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "inode-by-name",
+		Usage: "also fill in inodes of threads that did not open the file, when its basename maps to a single inode",
+	}
+}
+
+// newInodeResolverFromFlags builds an inodeResolver from the flags added by
+// inodePathsFlag and inodeByNameFlag.
+func newInodeResolverFromFlags(command *cli.Command) (*inodeResolver, error) {
+	inodes := newInodeResolver()
+	inodes.byNameAll = command.Bool("inode-by-name")
+	inodePaths := command.String("inode-paths")
+	if inodePaths == "" {
+		return inodes, nil
+	}
+	f, err := os.Open(inodePaths)
+	if err != nil {
+		return nil, fmt.Errorf("open inode paths: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := inodes.LoadFindDump(f); err != nil {
+		return nil, fmt.Errorf("load inode paths: %w", err)
+	}
+	return inodes, nil
+}
+
+// LoadFindDump reads lines of "<inode> <path>" as printed by `find -printf '%i %p\n'`.
+func (r *inodeResolver) LoadFindDump(rd io.Reader) error {
+	scanner := bufio.NewScanner(rd)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		inodeStr, path, ok := strings.Cut(text, " ")
+		if !ok {
+			return fmt.Errorf("invalid find dump line %d: %q", line, text)
+		}
+		inode, err := strconv.ParseUint(inodeStr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid inode on find dump line %d: %w", line, err)
+		}
+		r.paths[inode] = path
+	}
+	return scanner.Err()
+}
+
+// Resolve learns from or fills in the inode and full path of an event.
+func (r *inodeResolver) Resolve(e *vfsEvent) {
+	if e.Path == "" {
+		return
+	}
+	key := tidPath{tid: e.Tid, path: e.Path}
+	if e.Inode != 0 {
+		r.byFile[key] = e.Inode
+		if inode, ok := r.byName[e.Path]; ok && inode != e.Inode {
+			r.ambiguous[e.Path] = true
+		}
+		r.byName[e.Path] = e.Inode
+	} else if inode, ok := r.byFile[key]; ok {
+		e.Inode = inode
+	} else if inode, ok := r.byName[e.Path]; ok && r.byNameAll && !r.ambiguous[e.Path] {
+		e.Inode = inode
+	}
+	if e.Inode != 0 && e.FullPath == "" {
+		e.FullPath = r.paths[e.Inode]
+	}
+}
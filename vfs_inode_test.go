@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestInodeResolver tests learning inodes from open events and resolving full paths
+func TestInodeResolver(t *testing.T) {
+	r := newInodeResolver()
+	r.byNameAll = true
+	err := r.LoadFindDump(strings.NewReader("7 /data/a/data.log\n8 /data/b/data.log\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []vfsEvent{
+		{Probe: "vfs_open", Tid: 1, Path: "data.log", Inode: 7},
+		{Probe: "vfs_write", Tid: 1, Path: "data.log"},
+		{Probe: "vfs_write", Tid: 2, Path: "data.log"},
+		{Probe: "vfs_open", Tid: 3, Path: "data.log", Inode: 8},
+		{Probe: "vfs_write", Tid: 3, Path: "data.log"},
+		{Probe: "vfs_write", Tid: 4, Path: "data.log"},
+		{Probe: "vfs_write", Tid: 1, ReturnValue: 10},
+	}
+	for i := range events {
+		r.Resolve(&events[i])
+	}
+
+	want := []struct {
+		inode    uint64
+		fullPath string
+	}{
+		{7, "/data/a/data.log"},
+		{7, "/data/a/data.log"},
+		{7, "/data/a/data.log"},
+		{8, "/data/b/data.log"},
+		{8, "/data/b/data.log"},
+		{0, ""},
+		{0, ""},
+	}
+	for i, w := range want {
+		if events[i].Inode != w.inode || events[i].FullPath != w.fullPath {
+			t.Errorf("event %d: inode=%d path=%q, want inode=%d path=%q",
+				i, events[i].Inode, events[i].FullPath, w.inode, w.fullPath)
+		}
+	}
+}
+
+// TestInodeResolverSameBasename tests that another thread's file with the same basename
+// is not resolved to the inode opened by the first thread
+func TestInodeResolverSameBasename(t *testing.T) {
+	r := newInodeResolver()
+	events := []vfsEvent{
+		{Probe: "vfs_open", Tid: 1, Path: "app.log", Inode: 7},
+		{Probe: "vfs_write", Tid: 1, Path: "app.log"},
+		{Probe: "vfs_write", Tid: 2, Path: "app.log"},
+		{Probe: "vfs_open", Tid: 2, Path: "app.log", Inode: 9},
+		{Probe: "vfs_write", Tid: 2, Path: "app.log"},
+		{Probe: "vfs_write", Tid: 1, Path: "app.log"},
+	}
+	for i := range events {
+		r.Resolve(&events[i])
+	}
+	for i, want := range []uint64{7, 7, 0, 9, 9, 7} {
+		if events[i].Inode != want {
+			t.Errorf("event %d: inode=%d, want %d", i, events[i].Inode, want)
+		}
+	}
+}
+
+// TestInodeResolverInvalidDump tests that malformed find dumps are rejected
+func TestInodeResolverInvalidDump(t *testing.T) {
+	r := newInodeResolver()
+	if err := r.LoadFindDump(strings.NewReader("notanumber /x\n")); err == nil {
+		t.Error("expected error for invalid inode")
+	}
+	if err := r.LoadFindDump(strings.NewReader("12345\n")); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
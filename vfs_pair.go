@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// vfsCall is a single vfs operation with its entry and return events merged.
+type vfsCall struct {
+	Op       string
+	Probe    string // probe of the entry, or of the return when unmatched
+	Tid      uint64
+	Pid      uint64
+	Comm     string
+	Path     string
+	FullPath string
+	Inode    uint64
+	Offset   uint64
+	Length   uint64
+	RC       int64
+	Start    uint64
+	End      uint64
+	paired   bool
+}
+
+// Timed reports whether both the entry and the return were seen, so that the
+// duration is known.
+func (c *vfsCall) Timed() bool {
+	return c.paired
+}
+
+// Duration returns the time between entry and return.
+func (c *vfsCall) Duration() time.Duration {
+	if c.End < c.Start {
+		return 0
+	}
+	return time.Duration(c.End - c.Start)
+}
+
+// Name returns the most specific name known for the file.
+func (c *vfsCall) Name() string {
+	if c.FullPath != "" {
+		return c.FullPath
+	}
+	return c.Path
+}
+
+// vfsReturnPrefixes are the probe types that fire when a function returns.
+var vfsReturnPrefixes = []string{"kretfunc", "fexit", "kretprobe", "uretprobe"}
+
+// vfsProbeOp splits a probe such as "kretfunc:vmlinux:vfs_read" into the operation
+// name and whether it is a return probe. Probes without a prefix are treated as
+// events that carry both the arguments and the return value.
+func vfsProbeOp(probe string) (op string, isEntry bool, isReturn bool) {
+	i := strings.LastIndexByte(probe, ':')
+	if i < 0 {
+		return probe, false, false
+	}
+	op = probe[i+1:]
+	prefix := probe
+	if j := strings.IndexByte(probe, ':'); j >= 0 {
+		prefix = probe[:j]
+	}
+	if slices.Contains(vfsReturnPrefixes, prefix) {
+		return op, false, true
+	}
+	return op, true, false
+}
+
+type tidOp struct {
+	tid uint64
+	op  string
+}
+
+// vfsPairer merges entry and return events of the same thread and operation into vfsCalls.
+type vfsPairer struct {
+	pending map[tidOp]*vfsCall
+}
+
+func newVfsPairer() *vfsPairer {
+	return &vfsPairer{pending: make(map[tidOp]*vfsCall)}
+}
+
+// Handle consumes an event and returns a completed call, or nil while waiting for the return.
+// Returns without a matching entry are reported with only the return value set.
+func (p *vfsPairer) Handle(e *vfsEvent) *vfsCall {
+	op, isEntry, isReturn := vfsProbeOp(e.Probe)
+	key := tidOp{tid: e.Tid, op: op}
+	switch {
+	case isEntry:
+		p.pending[key] = &vfsCall{
+			Op: op, Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm,
+			Path: e.Path, FullPath: e.FullPath, Inode: e.Inode,
+			Offset: e.Offset, Length: e.Length,
+			Start: e.Timestamp,
+		}
+		return nil
+
+	case isReturn:
+		c, ok := p.pending[key]
+		if !ok {
+			c = &vfsCall{Op: op, Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm, Start: e.Timestamp}
+		}
+		delete(p.pending, key)
+		c.paired = ok
+		c.RC = e.ReturnValue
+		c.End = e.Timestamp
+		return c
+
+	default:
+		return &vfsCall{
+			Op: op, Probe: e.Probe, Tid: e.Tid, Pid: e.Pid, Comm: e.Comm,
+			Path: e.Path, FullPath: e.FullPath, Inode: e.Inode,
+			Offset: e.Offset, Length: e.Length, RC: e.ReturnValue,
+			Start: e.Timestamp, End: e.Timestamp,
+		}
+	}
+}
+
+// Pending returns the calls whose return was not seen, oldest first.
+func (p *vfsPairer) Pending() []*vfsCall {
+	calls := make([]*vfsCall, 0, len(p.pending))
+	for _, c := range p.pending {
+		calls = append(calls, c)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Start < calls[j].Start
+	})
+	return calls
+}
+
+// vfsCallsFromStream parses vfs raw NDJSON, resolves inodes and calls fn for each paired call.
+// Events are attributed to their process from tasks when it is not nil. Calls still waiting
+// for their return at the end of the input are passed last, without an end.
+func vfsCallsFromStream(r io.Reader, inodes *inodeResolver, tasks *taskTable, fn func(c *vfsCall) error) error {
+	pairer := newVfsPairer()
+	var hooks []printfHook
+	if tasks != nil {
+		hooks = append(hooks, tasks.Intercept)
+	}
+	err := jsonParseThenAppend(r, func(e *vfsEvent) error {
+		if tasks != nil {
+			e.enrich(tasks)
+		}
+		inodes.Resolve(e)
+		if c := pairer.Handle(e); c != nil {
+			return fn(c)
+		}
+		return nil
+	}, hooks...)
+	if err != nil {
+		return err
+	}
+	for _, c := range pairer.Pending() {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}